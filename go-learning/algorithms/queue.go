@@ -427,3 +427,130 @@ func (mpq *MaxPriorityQueue) siftDown(idx int) {
 		idx = largest
 	}
 }
+
+// ----------------------------------------------------------------------------
+// PriorityQueueFunc - Heap-based priority queue of any type
+// ----------------------------------------------------------------------------
+
+// PriorityQueueFunc is PriorityQueue for any element type: less reports
+// whether a has a higher priority than b.
+type PriorityQueueFunc[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+// NewPriorityQueueFunc creates an empty priority queue ordered by less.
+func NewPriorityQueueFunc[T any](less func(a, b T) bool) *PriorityQueueFunc[T] {
+	return &PriorityQueueFunc[T]{less: less}
+}
+
+// Push adds an element to the priority queue.
+// Time Complexity: O(log n)
+func (pq *PriorityQueueFunc[T]) Push(val T) {
+	pq.items = append(pq.items, val)
+	pq.siftUp(len(pq.items) - 1)
+}
+
+// Pop removes and returns the highest priority element.
+// Time Complexity: O(log n)
+func (pq *PriorityQueueFunc[T]) Pop() (T, bool) {
+	var zero T
+	if len(pq.items) == 0 {
+		return zero, false
+	}
+	return pq.removeAt(0), true
+}
+
+// Peek returns the highest priority element without removing it.
+// Time Complexity: O(1)
+func (pq *PriorityQueueFunc[T]) Peek() (T, bool) {
+	if len(pq.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.items[0], true
+}
+
+// RemoveFunc removes the first element for which match returns true.
+// Time Complexity: O(n)
+func (pq *PriorityQueueFunc[T]) RemoveFunc(match func(T) bool) (T, bool) {
+	for i, item := range pq.items {
+		if match(item) {
+			return pq.removeAt(i), true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Sorted returns the elements in priority order without changing the queue.
+// Time Complexity: O(n log n)
+func (pq *PriorityQueueFunc[T]) Sorted() []T {
+	clone := &PriorityQueueFunc[T]{items: append([]T(nil), pq.items...), less: pq.less}
+	sorted := make([]T, 0, len(pq.items))
+	for !clone.IsEmpty() {
+		val, _ := clone.Pop()
+		sorted = append(sorted, val)
+	}
+	return sorted
+}
+
+// IsEmpty returns true if the priority queue is empty.
+func (pq *PriorityQueueFunc[T]) IsEmpty() bool {
+	return len(pq.items) == 0
+}
+
+// Size returns the number of elements.
+func (pq *PriorityQueueFunc[T]) Size() int {
+	return len(pq.items)
+}
+
+// removeAt removes the element at idx and restores the heap property.
+func (pq *PriorityQueueFunc[T]) removeAt(idx int) T {
+	val := pq.items[idx]
+	lastIdx := len(pq.items) - 1
+	pq.items[idx] = pq.items[lastIdx]
+	var zero T
+	pq.items[lastIdx] = zero
+	pq.items = pq.items[:lastIdx]
+
+	if idx < len(pq.items) {
+		pq.siftDown(idx)
+		pq.siftUp(idx)
+	}
+	return val
+}
+
+func (pq *PriorityQueueFunc[T]) siftUp(idx int) {
+	for idx > 0 {
+		parent := (idx - 1) / 2
+		if !pq.less(pq.items[idx], pq.items[parent]) {
+			break
+		}
+		pq.items[idx], pq.items[parent] = pq.items[parent], pq.items[idx]
+		idx = parent
+	}
+}
+
+func (pq *PriorityQueueFunc[T]) siftDown(idx int) {
+	n := len(pq.items)
+	for {
+		smallest := idx
+		left := 2*idx + 1
+		right := 2*idx + 2
+
+		if left < n && pq.less(pq.items[left], pq.items[smallest]) {
+			smallest = left
+		}
+		if right < n && pq.less(pq.items[right], pq.items[smallest]) {
+			smallest = right
+		}
+
+		if smallest == idx {
+			break
+		}
+
+		pq.items[idx], pq.items[smallest] = pq.items[smallest], pq.items[idx]
+		idx = smallest
+	}
+}
@@ -1,38 +1,256 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"go-learning/ginapp"
+	"go-learning/golden"
+	"go-learning/nethttp"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Graceful shutdown: after SIGTERM readiness fails for drainDelay so
+// Kubernetes removes the pod from its Service before connections close,
+// then in-flight requests get shutdownTimeout to finish
+const (
+	drainDelay      = 5 * time.Second
+	shutdownTimeout = 20 * time.Second
 )
 
 func main() {
+	config := ginapp.DefaultServerConfig
+	flag.StringVar(&config.Addr, "addr", config.Addr, "plain HTTP address, empty to disable")
+	flag.StringVar(&config.TLSAddr, "tls-addr", "", "HTTPS address, e.g. :8443")
+	flag.StringVar(&config.CertFile, "tls-cert", "", "TLS certificate file (PEM)")
+	flag.StringVar(&config.KeyFile, "tls-key", "", "TLS private key file (PEM)")
+	flag.BoolVar(&config.HTTP3, "http3", false, "also serve HTTP/3 over QUIC on the -tls-addr port (UDP)")
+	flag.StringVar(&config.ClientCAFile, "client-ca", "", "CA for client certificates; admin routes then require one")
+	flagsFile := flag.String("flags", "", "YAML or JSON file of feature flags, replacing the defaults")
+	devTLS := flag.String("dev-tls", "", "directory for a development CA and certificates (creates them if needed)")
+	record := flag.String("record", "", "write every request and response to this golden file on shutdown")
+	jwtSecret := flag.String("tenant-jwt-secret", os.Getenv("TENANT_JWT_SECRET"), "HMAC key of JWTs whose tenant_id claim selects a tenant (default $TENANT_JWT_SECRET)")
+	flag.BoolVar(&ginapp.AllowDemoTokens, "demo-tokens", false, "accept any unknown Bearer token as "+ginapp.DemoUser+" (local demos only)")
+	seed := flag.String("seed", "", "load a fixture set ("+strings.Join(ginapp.FixtureSets(), ", ")+") or a .yaml/.json fixture file at startup")
+	flag.Parse()
+	ginapp.TenantJWTSecret = []byte(*jwtSecret)
+
+	if *devTLS != "" {
+		certs, err := nethttp.EnsureDevCertificates(*devTLS)
+		if err != nil {
+			fmt.Printf("Development certificates: %v\n", err)
+			return
+		}
+		if config.TLSAddr == "" {
+			config.TLSAddr = ":8443"
+		}
+		if config.CertFile == "" {
+			config.CertFile, config.KeyFile = certs.CertFile, certs.KeyFile
+		}
+		if config.ClientCAFile == "" {
+			config.ClientCAFile = certs.CAFile
+		}
+		fmt.Printf("Development CA: %s (client certificate: %s)\n", certs.CAFile, certs.ClientCertFile)
+	}
+	ginapp.RequireAdminClientCert = config.ClientCAFile != ""
+
 	fmt.Println("=== GIN Web Application ===")
 	fmt.Println()
 
+	if *flagsFile != "" {
+		if err := ginapp.LoadFeatureFlagsFile(*flagsFile); err != nil {
+			fmt.Printf("Feature flags: %v\n", err)
+			return
+		}
+	}
+
 	router := ginapp.SetupRouter()
 
+	if *seed != "" {
+		summary, err := ginapp.LoadFixture(context.Background(), *seed)
+		if err != nil {
+			fmt.Printf("Seed error: %v\n", err)
+			return
+		}
+		fmt.Printf("Seeded %s: %s\n\n", *seed, summary)
+	}
+
 	fmt.Println("Available endpoints:")
 	fmt.Println("  GET  /                     - Welcome message")
 	fmt.Println("  GET  /health               - Health check")
+	fmt.Println("  GET  /livez                - Liveness probe (?verbose)")
+	fmt.Println("  GET  /readyz               - Readiness probe, fails while starting and shutting down")
+	fmt.Println("  GET  /startupz             - Startup probe")
 	fmt.Println("  GET  /api/v1/formats       - Response formats (?format=json|xml|yaml)")
-	fmt.Println("  GET  /api/v1/books         - List all books")
+	fmt.Println("  GET  /api/v1/books         - List all books (?sort=id|rating)")
 	fmt.Println("  GET  /api/v1/books/:id     - Get book by ID")
-	fmt.Println("  GET  /api/v1/books/search  - Search (?author=...&year=...&limit=10)")
+	fmt.Println("       List, get and search take ?fields=title,year, ?include=reviews,authors,stats and ?format=json|xml|yaml (or Accept)")
+	fmt.Println("  GET  /api/v1/books/search  - Search (?author=...&year=...&filter=...&limit=10&sort=rating)")
 	fmt.Println("  POST /api/v1/books         - Create book (JSON body)")
 	fmt.Println("  PUT  /api/v1/books/:id     - Update book (JSON body)")
-	fmt.Println("  DELETE /api/v1/books/:id   - Delete book")
-	fmt.Println("  GET  /api/v1/admin/stats   - Stats (requires: Authorization: Bearer <token>)")
+	fmt.Println("  DELETE /api/v1/books/:id   - Delete book (and its reviews and cover)")
+	fmt.Println("  GET  /api/v1/books/:id/similar - Similar books")
+	fmt.Println("  GET  /api/v1/books/:id/reviews - List reviews")
+	fmt.Println("  POST /api/v1/books/:id/reviews - Review a book (JSON body: {\"rating\":5,\"comment\":\"...\"}, requires auth)")
+	fmt.Println("  PUT  /api/v1/books/:id/reviews/:reviewId - Update own review (requires auth)")
+	fmt.Println("  DELETE /api/v1/books/:id/reviews/:reviewId - Delete own review (requires auth)")
+	fmt.Println("  PUT  /api/v1/books/:id/cover - Upload cover (multipart field \"cover\" or raw image body)")
+	fmt.Println("  GET  /api/v1/books/:id/cover[/small|medium|large] - Cover image or thumbnail")
+	fmt.Println("  DELETE /api/v1/books/:id/cover - Remove cover")
+	fmt.Println("  PUT  /api/v1/books/:id/copies - Set copies owned (JSON body: {\"copies\":3})")
+	fmt.Println("  GET  /api/v1/books/:id/availability - Copies on loan, reserved and free")
+	fmt.Println("  POST /api/v1/books/:id/checkout - Borrow a copy, due in 14 days (requires auth)")
+	fmt.Println("  POST /api/v1/books/:id/return - Return a copy; it is reserved for the next in line (requires auth)")
+	fmt.Println("  GET  /api/v1/books/:id/waitlist - Reservations and queue")
+	fmt.Println("  POST /api/v1/books/:id/waitlist - Join the waitlist (optional JSON body: {\"priority\":0-10} from admins, requires auth)")
+	fmt.Println("  DELETE /api/v1/books/:id/waitlist - Leave the waitlist (requires auth)")
+	fmt.Println("  GET  /api/v1/loans         - Own loans (?status=active|returned|overdue, requires auth)")
+	fmt.Println("  GET  /api/v1/shelves       - Own shelves (?owner=ann for ann's public shelves, requires auth)")
+	fmt.Println("  POST /api/v1/shelves       - Create shelf (JSON body: {\"name\":\"To read\",\"visibility\":\"private|public\"}, requires auth)")
+	fmt.Println("  GET  /api/v1/shelves/:shelfId - Shelf with its books (public shelves need no auth)")
+	fmt.Println("  PUT  /api/v1/shelves/:shelfId - Update name, description or visibility (owner)")
+	fmt.Println("  DELETE /api/v1/shelves/:shelfId - Delete shelf (owner)")
+	fmt.Println("  POST /api/v1/shelves/:shelfId/books - Add book (JSON body: {\"book_id\":1,\"position\":1}, owner)")
+	fmt.Println("  PUT  /api/v1/shelves/:shelfId/books - Reorder (JSON body: {\"book_ids\":[3,1,2]}, owner)")
+	fmt.Println("  DELETE /api/v1/shelves/:shelfId/books/:id - Remove book from shelf (owner)")
+	fmt.Println("  POST /api/v1/shelves/:shelfId/share - Create sharing link; DELETE revokes it (owner)")
+	fmt.Println("  GET  /api/v1/shared/shelves/:token - Shelf behind a sharing link, no auth")
+	fmt.Println("  POST /api/v1/batch         - Atomic book operations (JSON body: {\"operations\":[{\"op\":\"create\",\"ref\":\"a\",\"body\":{...}},{\"op\":\"delete\",\"id\":\"$a\"}]})")
+	fmt.Println("  POST /api/v1/graphql       - GraphQL (JSON body: {\"query\":\"...\",\"variables\":{...}}, introspection enabled)")
+	fmt.Println("  POST /api/v1/auth/register - Create account (JSON body: {\"username\":\"ann\",\"password\":\"...\"})")
+	fmt.Println("  POST /api/v1/auth/login    - Log in, returns a session token")
+	fmt.Println("  POST /api/v1/auth/logout   - End the session")
+	fmt.Println("  GET  /api/v1/auth/me       - Current user")
+	fmt.Println("  GET  /api/v1/admin/stats   - Stats (requires an admin: Authorization: Bearer <token>)")
+	fmt.Println("  POST /api/v1/admin/tenants - Create tenant (JSON body: {\"id\":\"acme\",\"name\":\"Acme\",\"max_books\":100})")
+	fmt.Println("  GET  /api/v1/admin/tenants - List tenants")
+	fmt.Println("  DELETE /api/v1/admin/tenants/:tenant - Delete tenant")
+	fmt.Println("  GET  /api/v1/admin/loans   - Active loans of all users (?overdue=true)")
+	fmt.Println("  POST /api/v1/admin/backup  - Download a .tar.gz backup of the tenant's catalog")
+	fmt.Println("  POST /api/v1/admin/restore - Restore the tenant's catalog (body: backup archive)")
+	fmt.Println("  POST /api/v1/admin/api-keys - Mint API key (JSON body: {\"name\":\"nightly\",\"scopes\":[\"books:read\"]})")
+	fmt.Println("  GET  /api/v1/admin/api-keys - List API keys")
+	fmt.Println("  POST /api/v1/admin/api-keys/:keyId/rotate - Rotate API key")
+	fmt.Println("  DELETE /api/v1/admin/api-keys/:keyId - Revoke API key")
+	fmt.Println("  POST /api/v1/admin/webhooks - Subscribe to book events (JSON body: {\"url\":\"https://...\",\"events\":[\"book.created\"]})")
+	fmt.Println("  GET  /api/v1/admin/webhooks - List webhooks")
+	fmt.Println("  GET  /api/v1/admin/webhooks/:webhookId - Get webhook")
+	fmt.Println("  GET  /api/v1/admin/webhooks/:webhookId/deliveries - Delivery log with every attempt")
+	fmt.Println("  POST /api/v1/admin/webhooks/:webhookId/enable - Re-enable a failing webhook")
+	fmt.Println("  DELETE /api/v1/admin/webhooks/:webhookId - Delete webhook")
+	fmt.Println("  GET  /api/v1/admin/flags   - Feature flags and their rollout rules")
+	fmt.Println("  GET  /api/v1/admin/flags/:flag - Get flag")
+	fmt.Println("  PUT  /api/v1/admin/flags/:flag - Change flag (JSON body: {\"enabled\":true,\"users\":[\"ann\"],\"tenants\":[\"acme\"],\"percentage\":10}, or {\"clear_rules\":true})")
+	fmt.Println("  GET  /api/v1/admin/flags/:flag/check - Decision for ?user=ann&tenant=acme, with the reason")
+	fmt.Println("  GET  /api/v2/books         - List books, v2 shape (?tag=...&language=...)")
+	fmt.Println("  GET  /api/v2/books/:id     - Get book by ID, v2 shape")
+	fmt.Println("  POST /api/v2/books         - Create book (JSON body with \"authors\": [{\"name\": ...}])")
+	fmt.Println("  PUT  /api/v2/books/:id     - Update book, v2 shape")
+	fmt.Println("  DELETE /api/v2/books/:id   - Delete book")
+	fmt.Println()
+	fmt.Println("  /api/v1 is deprecated: responses carry Deprecation, Sunset and Link headers")
+	fmt.Println("  Bearer tokens come from /auth/login (-demo-tokens accepts any); /admin needs an admin account, e.g. ann with -seed demo")
+	fmt.Println("  Machine clients send X-API-Key: gak_...; scopes are books:read, books:write and admin")
+	fmt.Println("  Webhook deliveries are signed: X-Webhook-Signature: sha256=HMAC(secret, \"<X-Webhook-Timestamp>.<body>\")")
+	fmt.Println("  Routes behind a feature flag that is off answer 404; load flags with -flags flags.yaml")
+	fmt.Println("  Select a tenant with X-Tenant-ID, a signed JWT tenant_id claim (-tenant-jwt-secret) or http://<tenant>.localhost:8081")
+	fmt.Println("  Accounts and API keys only work with the tenant they belong to; admins work with every tenant")
 	fmt.Println()
 	fmt.Println("Example curl commands:")
 	fmt.Println("  curl http://localhost:8081/api/v1/books")
 	fmt.Println("  curl -X POST http://localhost:8081/api/v1/books -H 'Content-Type: application/json' -d '{\"title\":\"My Book\",\"author\":\"Me\",\"year\":2024}'")
-	fmt.Println("  curl http://localhost:8081/api/v1/admin/stats -H 'Authorization: Bearer mytoken'")
+	fmt.Println("  curl -X POST http://localhost:8081/api/v1/auth/register -H 'Content-Type: application/json' -d '{\"username\":\"ann\",\"password\":\"correct-horse-42\"}'")
+	fmt.Println("  curl -X POST http://localhost:8081/api/v1/auth/login -H 'Content-Type: application/json' -d '{\"username\":\"ann\",\"password\":\"correct-horse-42\"}'")
+	fmt.Println("  curl http://localhost:8081/api/v1/admin/stats -H 'Authorization: Bearer <token from login>'")
+	fmt.Println("  curl -X POST http://localhost:8081/api/v1/admin/backup -H 'Authorization: Bearer <admin token>' -o backup.tar.gz")
+	fmt.Println("  curl -X POST http://localhost:8081/api/v1/admin/restore -H 'Authorization: Bearer <admin token>' --data-binary @backup.tar.gz")
+	fmt.Println("  curl -X PUT http://localhost:8081/api/v1/books/1/cover -F cover=@cover.jpg")
+	fmt.Println("  curl -G http://localhost:8081/api/v1/books/search --data-urlencode 'filter=year >= 2015 AND (author ~ \"Kernighan\" OR title startswith \"Go\")'")
+	fmt.Println("  curl http://localhost:8081/api/v1/graphql -H 'Content-Type: application/json' -d '{\"query\":\"{ books(sort: \\\"rating\\\", limit: 5) { id title authors { name } } }\"}'")
+	fmt.Println()
+	fmt.Println("Seed data from a built-in fixture set or your own file:")
+	fmt.Println("  go run ./cmd/ginapp -seed demo      (users ann and bob, ann is an admin; three books, reviews and shelves)")
+	fmt.Println("  go run ./cmd/ginapp -seed library   (more books, copies and an acme tenant with user dave)")
+	fmt.Println("  go run ./cmd/ginapp -seed ./my-fixture.yaml")
+	fmt.Println()
+	fmt.Println("Record traffic into a golden file for contract tests (written on Ctrl+C):")
+	fmt.Println("  go run ./cmd/ginapp -seed demo -record ginapp/testdata/golden/session.json")
 	fmt.Println()
-	fmt.Println("Starting server on http://localhost:8081")
+	fmt.Println("Load test (closed model with 16 workers, or open model at a fixed rate):")
+	fmt.Println("  go run ./cmd/loadgen -target http://localhost:8081 -mix read=80,create=10,search=10")
+	fmt.Println("  go run ./cmd/loadgen -model open -rate 500 -duration 30s -json")
+	fmt.Println()
+	fmt.Println("Serving HTTPS and HTTP/3 (Alt-Svc is announced over TCP):")
+	fmt.Println("  go run ./cmd/ginapp -tls-addr :8443 -tls-cert cert.pem -tls-key key.pem -http3")
+	fmt.Println("  curl --http3-only --cacert cert.pem https://localhost:8443/api/v1/books")
+	fmt.Println()
+	fmt.Println("Local TLS with a development CA; admin routes then require the client certificate:")
+	fmt.Println("  go run ./cmd/ginapp -dev-tls .certs -http3")
+	fmt.Println("  curl --cacert .certs/ca.pem --cert .certs/client.pem --key .certs/client-key.pem https://localhost:8443/api/v1/admin/stats -H 'Authorization: Bearer <token>'")
+	fmt.Println("  Certificate files are reloaded when they change")
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := os.MkdirAll(ginapp.CoverStorageDir, 0o755); err != nil {
+		fmt.Printf("Cover storage error: %v\n", err)
+		return
+	}
+	ginapp.StartBackgroundWorkers(ctx)
+
+	var handler http.Handler = router
+	var recorder *golden.Recorder
+	if *record != "" {
+		recorder = golden.NewRecorder(router)
+		handler = recorder
+	}
+
+	server, err := ginapp.NewServer(handler, config)
+	if err != nil {
+		fmt.Printf("Server error: %v\n", err)
+		return
+	}
+	if addr := server.Addr(); addr != nil {
+		fmt.Printf("Listening on http://%s\n", addr)
+	}
+	if addr := server.TLSAddr(); addr != nil {
+		fmt.Printf("Listening on https://%s (HTTP/3: %v)\n", addr, config.HTTP3)
+	}
 	fmt.Println("Press Ctrl+C to stop")
 	fmt.Println()
 
-	if err := router.Run(":8081"); err != nil {
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.Serve() }()
+	ginapp.MarkStarted()
+
+	select {
+	case err := <-serverErr:
 		fmt.Printf("Server error: %v\n", err)
+		return
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down: readiness now fails, draining connections")
+	ginapp.BeginShutdown()
+	time.Sleep(drainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Shutdown error: %v\n", err)
+	}
+
+	if recorder != nil {
+		if err := recorder.Save(*record); err != nil {
+			fmt.Printf("Recording error: %v\n", err)
+			return
+		}
+		fmt.Printf("Recorded %d exchanges to %s\n", len(recorder.Recording().Exchanges), *record)
 	}
 }
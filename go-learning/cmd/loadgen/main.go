@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-learning/loadgen"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	target := flag.String("target", "http://localhost:8081", "base URL of the server")
+	api := flag.String("api", "ginapp", "server API: "+strings.Join(apiNames(), " or "))
+	mix := flag.String("mix", "", "weighted operations, e.g. read=80,create=20 (default depends on -api)")
+	model := flag.String("model", string(loadgen.Closed), "workload model: closed (fixed concurrency) or open (fixed arrival rate)")
+	rate := flag.Float64("rate", 100, "open model: requests per second")
+	poisson := flag.Bool("poisson", false, "open model: random arrivals instead of evenly spaced ones")
+	concurrency := flag.Int("concurrency", 16, "closed model: workers; open model: most requests in flight")
+	duration := flag.Duration("duration", 10*time.Second, "how long to measure")
+	warmup := flag.Duration("warmup", 2*time.Second, "load before measuring")
+	think := flag.Duration("think", 0, "closed model: pause between requests per worker")
+	timeout := flag.Duration("timeout", 5*time.Second, "per-request timeout")
+	token := flag.String("token", "mytoken", "Bearer token sent with ginapp requests (a session token, or anything with ginapp -demo-tokens)")
+	prefill := flag.Int("prefill", 100, "ginapp: create books first until there are this many")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	driver, ok := loadgen.APIs[*api]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown API %q (use %s)\n", *api, strings.Join(apiNames(), " or "))
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Enough idle connections that workers reuse them instead of
+	// exhausting ephemeral ports
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = *concurrency * 2
+	transport.MaxIdleConnsPerHost = *concurrency * 2
+	client := &http.Client{Transport: transport, Timeout: *timeout}
+
+	ops, err := driver.Prepare(ctx, loadgen.Env{Client: client, BaseURL: *target, Token: *token, Prefill: *prefill})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Preparing %s at %s: %v\n", *api, *target, err)
+		os.Exit(1)
+	}
+	if *mix == "" {
+		*mix = driver.DefaultMix()
+	}
+	parsed, err := loadgen.ParseMix(*mix, ops)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Mix: %v\n", err)
+		os.Exit(2)
+	}
+
+	fmt.Fprintf(os.Stderr, "Load testing %s (%s) with %s for %s after %s warmup; Ctrl+C stops early\n",
+		*target, *api, *mix, *duration, *warmup)
+	report, err := loadgen.Run(ctx, loadgen.Config{
+		Mix:         parsed,
+		Model:       loadgen.Model(*model),
+		Rate:        *rate,
+		Poisson:     *poisson,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Warmup:      *warmup,
+		ThinkTime:   *think,
+		Client:      client,
+		Seed:        time.Now().UnixNano(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load test: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Writing report: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func apiNames() []string {
+	names := make([]string, 0, len(loadgen.APIs))
+	for name := range loadgen.APIs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,19 +1,71 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"go-learning/golden"
 	"go-learning/nethttp"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
 func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	certFile := flag.String("tls-cert", "", "TLS certificate file (PEM); serves HTTPS when set")
+	keyFile := flag.String("tls-key", "", "TLS private key file (PEM)")
+	clientCA := flag.String("client-ca", "", "CA for client certificates, shown by /info")
+	devTLS := flag.String("dev-tls", "", "directory for a development CA and certificates (creates them if needed)")
+	record := flag.String("record", "", "write every request and response to this golden file on shutdown")
+	seed := flag.String("seed", "", "load users from a fixture set ("+strings.Join(nethttp.FixtureSets(), ", ")+") or a .yaml/.json file")
+	flag.Parse()
+
 	fmt.Println("=== NET/HTTP Server ===")
 	fmt.Println()
 
-	addr := ":8080"
-	server := nethttp.CreateServer(addr)
+	if *seed != "" {
+		n, err := nethttp.LoadFixture(*seed)
+		if err != nil {
+			fmt.Printf("Seed error: %v\n", err)
+			return
+		}
+		fmt.Printf("Seeded %s: %d users\n", *seed, n)
+	}
+
+	if *devTLS != "" {
+		certs, err := nethttp.EnsureDevCertificates(*devTLS)
+		if err != nil {
+			fmt.Printf("Development certificates: %v\n", err)
+			return
+		}
+		if *certFile == "" {
+			*certFile, *keyFile = certs.CertFile, certs.KeyFile
+		}
+		if *clientCA == "" {
+			*clientCA = certs.CAFile
+		}
+		fmt.Printf("Development CA: %s (client certificate: %s)\n", certs.CAFile, certs.ClientCertFile)
+	}
+
+	server := nethttp.CreateServer(*addr)
+	var recorder *golden.Recorder
+	if *record != "" {
+		recorder = golden.NewRecorder(server.Handler)
+		server.Handler = recorder
+	}
+	scheme := "http"
+	if *certFile != "" {
+		tlsConfig, certs, err := nethttp.NewTLSConfig(*certFile, *keyFile, *clientCA)
+		if err != nil {
+			fmt.Printf("TLS error: %v\n", err)
+			return
+		}
+		server.TLSConfig = tlsConfig
+		go certs.Watch(context.Background(), nethttp.CertReloadInterval)
+		scheme = "https"
+	}
 
 	// Graceful shutdown
 	go func() {
@@ -21,10 +73,17 @@ func main() {
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 		fmt.Println("\nShutting down server...")
+		if recorder != nil {
+			if err := recorder.Save(*record); err != nil {
+				fmt.Printf("Recording error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Recorded %d exchanges to %s\n", len(recorder.Recording().Exchanges), *record)
+		}
 		os.Exit(0)
 	}()
 
-	fmt.Printf("Server starting on http://localhost%s\n", addr)
+	fmt.Printf("Server starting on %s://localhost%s\n", scheme, *addr)
 	fmt.Println()
 	fmt.Println("Available endpoints:")
 	fmt.Println("  GET  /           - Hello World")
@@ -34,10 +93,27 @@ func main() {
 	fmt.Println("  GET  /api/users  - List all users (JSON)")
 	fmt.Println("  POST /api/users  - Create user (JSON body: {\"name\":\"...\",\"email\":\"...\"})")
 	fmt.Println()
+	fmt.Println("Seed users from a fixture set or file:")
+	fmt.Println("  go run ./cmd/nethttp -seed demo")
+	fmt.Println("  go run ./cmd/nethttp -seed demo -record nethttp/testdata/golden/session.json   (golden file for contract tests)")
+	fmt.Println()
+	fmt.Println("Load test:")
+	fmt.Println("  go run ./cmd/loadgen -api nethttp -target http://localhost:8080")
+	fmt.Println()
+	fmt.Println("TLS with a development CA (certificates are reloaded when they change):")
+	fmt.Println("  go run ./cmd/nethttp -dev-tls .certs")
+	fmt.Println("  curl --cacert .certs/ca.pem --cert .certs/client.pem --key .certs/client-key.pem https://localhost:8080/info")
+	fmt.Println()
 	fmt.Println("Press Ctrl+C to stop")
 	fmt.Println()
 
-	if err := server.ListenAndServe(); err != nil {
+	var err error
+	if scheme == "https" {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil {
 		fmt.Printf("Server error: %v\n", err)
 	}
 }
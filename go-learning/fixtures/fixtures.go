@@ -0,0 +1,122 @@
+// Package fixtures reads seed data for the demo servers and tests.
+//
+// A fixture is a YAML or JSON document decoded into a struct the caller
+// defines; fields are matched by their json tags and unknown fields are
+// errors, so typos do not silently drop data. A source is either a file
+// path (anything ending in .yaml, .yml or .json) or the name of a set
+// shipped with the program, e.g. "demo".
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Extensions a fixture file may have, in the order sets are looked up
+var Extensions = []string{".yaml", ".yml", ".json"}
+
+// ErrUnknownSet is returned by Load for a set name not in the sets
+var ErrUnknownSet = errors.New("unknown fixture set")
+
+// IsFile reports whether source names a file rather than a set
+func IsFile(source string) bool {
+	ext := strings.ToLower(filepath.Ext(source))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Load decodes the fixture source into v. Files are read from disk;
+// set names are looked up as <name>.yaml, .yml or .json in sets.
+func Load(sets fs.FS, source string, v interface{}) error {
+	if IsFile(source) {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		return Decode(source, data, v)
+	}
+
+	for _, ext := range Extensions {
+		name := source + ext
+		data, err := fs.ReadFile(sets, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return Decode(name, data, v)
+	}
+	return fmt.Errorf("%w %q (available: %s)", ErrUnknownSet, source, strings.Join(Sets(sets), ", "))
+}
+
+// Decode parses data as JSON if name ends in .json and as YAML
+// otherwise. Unknown fields are errors.
+func Decode(name string, data []byte, v interface{}) error {
+	var err error
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v)
+	} else {
+		err = yaml.UnmarshalWithOptions(data, v, yaml.DisallowUnknownField())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Sets lists the names of the sets in sets
+func Sets(sets fs.FS) []string {
+	entries, _ := fs.ReadDir(sets, ".")
+	var names []string
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if !entry.IsDir() && IsFile(entry.Name()) {
+			names = append(names, strings.TrimSuffix(entry.Name(), ext))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Problems collects validation errors with the path of the entry at
+// fault, e.g. "books[2].year: must be at least 1000".
+type Problems []string
+
+// Add records a problem at path
+func (p *Problems) Add(path string, format string, args ...interface{}) {
+	*p = append(*p, path+": "+fmt.Sprintf(format, args...))
+}
+
+// Err returns the problems as one error, or nil if there are none
+func (p Problems) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: p}
+}
+
+// ValidationError is a fixture that breaks the rules of the API
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid fixture:\n  " + strings.Join(e.Problems, "\n  ")
+}
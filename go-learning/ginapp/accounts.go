@@ -0,0 +1,487 @@
+package ginapp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ============================================================
+// USER ACCOUNTS AND SESSIONS
+// ============================================================
+// /api/v1/auth/register and /login create accounts and sessions:
+//
+//   1. register stores a bcrypt hash of the password, never the password
+//   2. login checks the hash and returns a random session token
+//   3. clients send "Authorization: Bearer <token>"; AuthMiddleware
+//      looks the token up and sets "user" in the context
+//   4. logout deletes the session
+//
+// Only a SHA-256 of each session token is kept, so a dump of the store
+// cannot be replayed. After MaxFailedLogins wrong passwords in a row an
+// account is locked for LockoutDuration.
+//
+// Administration under /api/v1/admin takes an account with the admin
+// role. Nobody gets it by registering: it is granted with SetAdmin,
+// e.g. by a fixture (see fixtures.go).
+//
+// Each account belongs to the tenant it registered with, and its
+// sessions only work there (see authenticate). Admins can work with
+// every tenant, since they create and back them up.
+// ============================================================
+
+// Account and session settings
+var (
+	BcryptCost       = bcrypt.DefaultCost
+	SessionTTL       = 24 * time.Hour
+	MaxFailedLogins  = 5
+	LockoutDuration  = 15 * time.Minute
+	MinPasswordChars = 10
+
+	// AllowDemoTokens brings back the original demo behaviour: a Bearer
+	// token that is not a known session authenticates as DemoUser. Anyone
+	// can then act as that user, so it is for tests and local demos only
+	// (-demo-tokens on cmd/ginapp).
+	AllowDemoTokens = false
+)
+
+// DemoUser is who demo tokens authenticate as. The name is reserved so
+// no account can be registered under it.
+const DemoUser = "demo_user"
+
+// bcrypt ignores everything after 72 bytes, so longer passwords are refused
+const maxPasswordBytes = 72
+
+// User - public view of an account
+type User struct {
+	Username    string     `json:"username"`
+	Tenant      string     `json:"tenant"`
+	Admin       bool       `json:"admin,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// CredentialsInput - body of register and login requests
+type CredentialsInput struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// account is a stored user with its password hash and lockout state
+type account struct {
+	User
+	passwordHash []byte
+	failedLogins int
+	lockedUntil  time.Time
+}
+
+// session is a logged-in user, stored under the hash of its token
+type session struct {
+	username  string
+	expiresAt time.Time
+}
+
+// Account and session stores
+var (
+	accounts   = make(map[string]*account) // by lowercase username
+	sessions   = make(map[string]session)  // by token hash
+	accountsMu sync.Mutex
+)
+
+// usernamePattern - 3-32 letters, digits, dots, dashes or underscores
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+// commonPasswords are refused even if they pass the other rules
+var commonPasswords = map[string]bool{
+	"password123":  true,
+	"password1234": true,
+	"qwerty12345":  true,
+	"1234567890a":  true,
+	"letmein12345": true,
+	"welcome12345": true,
+}
+
+var (
+	errUsernameTaken      = errors.New("Username is already taken")
+	errInvalidCredentials = errors.New("Invalid username or password")
+	errSessionExpired     = errors.New("Session expired")
+	errUnknownSession     = errors.New("Invalid session token")
+	errAccountNotFound    = errors.New("Account not found")
+	errUsernameReserved   = errors.New("Username is reserved")
+)
+
+// accountLockedError reports a locked account and when it unlocks
+type accountLockedError struct {
+	until time.Time
+}
+
+func (e *accountLockedError) Error() string {
+	return "Account is locked after too many failed logins"
+}
+
+// ============================================================
+// PASSWORD POLICY
+// ============================================================
+
+// passwordViolations lists every rule a password breaks, empty if none
+func passwordViolations(username, password string) []string {
+	var violations []string
+
+	if len([]rune(password)) < MinPasswordChars {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", MinPasswordChars))
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", maxPasswordBytes))
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		violations = append(violations, "must contain a letter and a digit")
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+	if commonPasswords[lower] {
+		violations = append(violations, "is too common")
+	}
+	return violations
+}
+
+// ============================================================
+// STORE
+// ============================================================
+
+// register creates an account of a tenant with a hashed password
+func register(username, password, tenant string) (User, error) {
+	key := strings.ToLower(username)
+	if key == DemoUser {
+		return User{}, errUsernameReserved
+	}
+
+	// Hash before taking the lock; bcrypt is deliberately slow
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
+	if err != nil {
+		return User{}, err
+	}
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	if _, exists := accounts[key]; exists {
+		return User{}, errUsernameTaken
+	}
+
+	acc := &account{
+		User:         User{Username: username, Tenant: tenant, CreatedAt: time.Now()},
+		passwordHash: hash,
+	}
+	accounts[key] = acc
+	return acc.User, nil
+}
+
+// Dummy hash compared against for unknown usernames, so a login takes
+// as long whether or not the account exists
+var (
+	dummyHash   []byte
+	dummyHashMu sync.Mutex
+)
+
+func dummyPasswordHash() []byte {
+	dummyHashMu.Lock()
+	defer dummyHashMu.Unlock()
+
+	if cost, err := bcrypt.Cost(dummyHash); err != nil || cost != BcryptCost {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password 0"), BcryptCost)
+	}
+	return dummyHash
+}
+
+// login verifies credentials, applying the lockout rules, and starts a
+// session. It returns the session token, which is not stored anywhere.
+func login(username, password string) (string, session, User, error) {
+	key := strings.ToLower(username)
+	now := time.Now()
+
+	accountsMu.Lock()
+	acc, exists := accounts[key]
+	var hash []byte
+	if exists {
+		if now.Before(acc.lockedUntil) {
+			accountsMu.Unlock()
+			return "", session{}, User{}, &accountLockedError{until: acc.lockedUntil}
+		}
+		hash = acc.passwordHash
+	}
+	accountsMu.Unlock()
+
+	// Compare outside the lock so one slow login does not block others
+	if !exists {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return "", session{}, User{}, errInvalidCredentials
+	}
+	matched := bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	// A parallel attempt may have locked the account during the compare;
+	// its result then counts for nothing, right or wrong
+	if time.Now().Before(acc.lockedUntil) {
+		return "", session{}, User{}, &accountLockedError{until: acc.lockedUntil}
+	}
+
+	if !matched {
+		acc.failedLogins++
+		if acc.failedLogins >= MaxFailedLogins {
+			acc.failedLogins = 0
+			acc.lockedUntil = now.Add(LockoutDuration)
+			return "", session{}, User{}, &accountLockedError{until: acc.lockedUntil}
+		}
+		return "", session{}, User{}, errInvalidCredentials
+	}
+
+	acc.failedLogins = 0
+	acc.LastLoginAt = &now
+
+	token, err := newSessionToken()
+	if err != nil {
+		return "", session{}, User{}, err
+	}
+	sess := session{username: acc.Username, expiresAt: now.Add(SessionTTL)}
+	pruneSessions(now)
+	sessions[hashToken(token)] = sess
+	return token, sess, acc.User, nil
+}
+
+// lookupSession returns the user a session token belongs to
+func lookupSession(token string) (string, error) {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	key := hashToken(token)
+	sess, exists := sessions[key]
+	if !exists {
+		return "", errUnknownSession
+	}
+	if time.Now().After(sess.expiresAt) {
+		delete(sessions, key)
+		return "", errSessionExpired
+	}
+	return sess.username, nil
+}
+
+// logout ends a session, reporting whether it existed
+func logout(token string) bool {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	key := hashToken(token)
+	_, exists := sessions[key]
+	delete(sessions, key)
+	return exists
+}
+
+// findAccount returns the public view of an account
+func findAccount(username string) (User, bool) {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	acc, exists := accounts[strings.ToLower(username)]
+	if !exists {
+		return User{}, false
+	}
+	return acc.User, true
+}
+
+// SetAdmin grants or revokes the admin role of an account
+func SetAdmin(username string, admin bool) error {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	acc, exists := accounts[strings.ToLower(username)]
+	if !exists {
+		return errAccountNotFound
+	}
+	acc.Admin = admin
+	return nil
+}
+
+// isAdmin reports whether a user has an account with the admin role.
+// Demo-token users have no account, so they never do.
+func isAdmin(username string) bool {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	acc, exists := accounts[strings.ToLower(username)]
+	return exists && acc.Admin
+}
+
+// userInTenant reports whether a user may work with a tenant: admins
+// everywhere, other accounts only in their own tenant. Demo-token users
+// have no account and are not bound to a tenant.
+func userInTenant(username, tenant string) bool {
+	if username == DemoUser {
+		return true
+	}
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	acc, exists := accounts[strings.ToLower(username)]
+	return exists && (acc.Admin || acc.Tenant == tenant)
+}
+
+// deleteTenantAccounts removes the accounts registered with a deleted
+// tenant and ends their sessions, so a tenant created later under the
+// same ID does not inherit them. Admins are not bound to a tenant and
+// keep their accounts.
+func deleteTenantAccounts(tenantID string) {
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+
+	deleted := make(map[string]bool)
+	for name, acc := range accounts {
+		if acc.Tenant == tenantID && !acc.Admin {
+			delete(accounts, name)
+			deleted[name] = true
+		}
+	}
+	for key, sess := range sessions {
+		if deleted[strings.ToLower(sess.username)] {
+			delete(sessions, key)
+		}
+	}
+}
+
+// pruneSessions drops expired sessions. Callers must hold accountsMu.
+func pruneSessions(now time.Time) {
+	for key, sess := range sessions {
+		if now.After(sess.expiresAt) {
+			delete(sessions, key)
+		}
+	}
+}
+
+// newSessionToken returns 256 random bits, base64url-encoded
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is the key a token is stored under
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+// Register - POST /api/v1/auth/register
+func Register(c *gin.Context) {
+	var input CredentialsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if !usernamePattern.MatchString(input.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be 3-32 letters, digits, dots, dashes or underscores"})
+		return
+	}
+	if violations := passwordViolations(input.Username, input.Password); len(violations) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Password does not meet the policy",
+			"violations": violations,
+		})
+		return
+	}
+
+	user, err := register(input.Username, input.Password, tenantFrom(c).ID)
+	switch {
+	case errors.Is(err, errUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errUsernameReserved):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": user})
+}
+
+// Login - POST /api/v1/auth/login
+func Login(c *gin.Context) {
+	var input CredentialsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	token, sess, user, err := login(input.Username, input.Password)
+	if err != nil {
+		var locked *accountLockedError
+		switch {
+		case errors.As(err, &locked):
+			retryAfter := int(time.Until(locked.until).Seconds()) + 1
+			c.Header("Retry-After", fmt.Sprintf("%d", retryAfter))
+			c.JSON(http.StatusLocked, gin.H{"error": err.Error(), "retry_after": retryAfter})
+		case errors.Is(err, errInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": sess.expiresAt,
+		"user":       user,
+	}})
+}
+
+// Logout - POST /api/v1/auth/logout (requires auth)
+func Logout(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	logout(token)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// Me - GET /api/v1/auth/me (requires auth)
+func Me(c *gin.Context) {
+	username := c.GetString("user")
+
+	// Demo tokens have no account behind them
+	user, exists := findAccount(username)
+	if !exists {
+		user = User{Username: username}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
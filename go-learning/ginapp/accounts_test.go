@@ -0,0 +1,304 @@
+package ginapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// resetAccounts clears accounts and sessions and makes hashing cheap
+func resetAccounts(t *testing.T) {
+	t.Helper()
+
+	accountsMu.Lock()
+	accounts = make(map[string]*account)
+	sessions = make(map[string]session)
+	accountsMu.Unlock()
+
+	oldCost := BcryptCost
+	BcryptCost = bcrypt.MinCost
+	t.Cleanup(func() { BcryptCost = oldCost })
+}
+
+// Account with the admin role used by adminToken
+const testAdmin, testAdminPassword = "root_admin", "admin-pass-2024"
+
+// adminToken returns a session token of an account with the admin role,
+// creating the account again if a test reset the accounts
+func adminToken(t *testing.T) string {
+	t.Helper()
+
+	if _, exists := findAccount(testAdmin); !exists {
+		oldCost := BcryptCost
+		BcryptCost = bcrypt.MinCost
+		_, err := register(testAdmin, testAdminPassword, DefaultTenantID)
+		BcryptCost = oldCost
+		if err != nil {
+			t.Fatalf("Registering the admin failed: %v", err)
+		}
+		SetAdmin(testAdmin, true)
+	}
+	token, _, _, err := login(testAdmin, testAdminPassword)
+	if err != nil {
+		t.Fatalf("Admin login failed: %v", err)
+	}
+	return token
+}
+
+// postCredentials sends a register or login request
+func postCredentials(router http.Handler, path, username, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(CredentialsInput{Username: username, Password: password})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// loginToken registers a user and returns a fresh session token
+func loginToken(t *testing.T, router http.Handler, username, password string) string {
+	t.Helper()
+
+	if w := postCredentials(router, "/api/v1/auth/register", username, password); w.Code != http.StatusCreated {
+		t.Fatalf("Register failed: %d: %s", w.Code, w.Body.String())
+	}
+	w := postCredentials(router, "/api/v1/auth/login", username, password)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response["data"]["token"].(string)
+}
+
+// getMe calls /api/v1/auth/me with the given token
+func getMe(router http.Handler, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRegisterLoginMe(t *testing.T) {
+	router := setupTestRouter()
+	resetAccounts(t)
+
+	token := loginToken(t, router, "Ann", "correct-horse-42")
+
+	w := getMe(router, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"]["username"] != "Ann" || response["data"]["last_login_at"] == nil {
+		t.Errorf("Unexpected user: %v", response["data"])
+	}
+
+	// The session user is what handlers see, once granted the admin role
+	adminStats := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := adminStats(); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d before the admin role, got %d", http.StatusForbidden, w.Code)
+	}
+	SetAdmin("ann", true)
+	if w := adminStats(); !strings.Contains(w.Body.String(), `"user":"Ann"`) {
+		t.Errorf("Expected stats for Ann, got %s", w.Body.String())
+	}
+
+	// Passwords are never stored in clear
+	if acc := accounts["ann"]; bytes.Contains(acc.passwordHash, []byte("correct-horse-42")) {
+		t.Error("Password stored in clear")
+	}
+}
+
+func TestRegister_Validation(t *testing.T) {
+	router := setupTestRouter()
+	resetAccounts(t)
+	postCredentials(router, "/api/v1/auth/register", "taken", "correct-horse-42")
+
+	tests := []struct {
+		name           string
+		username       string
+		password       string
+		expectedStatus int
+	}{
+		{name: "too short", username: "bob", password: "abc123", expectedStatus: http.StatusBadRequest},
+		{name: "no digit", username: "bob", password: "correct-horse", expectedStatus: http.StatusBadRequest},
+		{name: "contains username", username: "bobby", password: "bobby-12345", expectedStatus: http.StatusBadRequest},
+		{name: "common", username: "bob", password: "Password123", expectedStatus: http.StatusBadRequest},
+		{name: "too long", username: "bob", password: strings.Repeat("a1", 37), expectedStatus: http.StatusBadRequest},
+		{name: "bad username", username: "b b", password: "correct-horse-42", expectedStatus: http.StatusBadRequest},
+		{name: "duplicate, any case", username: "TAKEN", password: "correct-horse-42", expectedStatus: http.StatusConflict},
+		{name: "demo user", username: "Demo_User", password: "correct-horse-42", expectedStatus: http.StatusBadRequest},
+		{name: "valid", username: "bob", password: "correct-horse-42", expectedStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postCredentials(router, "/api/v1/auth/register", tt.username, tt.password)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPasswordViolations(t *testing.T) {
+	violations := passwordViolations("ann", "ann")
+	if len(violations) != 3 {
+		t.Errorf("Expected length, letter/digit and username violations, got %v", violations)
+	}
+	if violations := passwordViolations("ann", "correct-horse-42"); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+}
+
+func TestLogin_WrongPassword(t *testing.T) {
+	router := setupTestRouter()
+	resetAccounts(t)
+	postCredentials(router, "/api/v1/auth/register", "ann", "correct-horse-42")
+
+	// Unknown users and wrong passwords look the same
+	for _, username := range []string{"ann", "nobody"} {
+		w := postCredentials(router, "/api/v1/auth/login", username, "wrong-password-1")
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Invalid username or password") {
+			t.Errorf("%s: expected generic 401, got %d: %s", username, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestLogin_Lockout(t *testing.T) {
+	router := setupTestRouter()
+	resetAccounts(t)
+	postCredentials(router, "/api/v1/auth/register", "ann", "correct-horse-42")
+
+	for i := 1; i < MaxFailedLogins; i++ {
+		if w := postCredentials(router, "/api/v1/auth/login", "ann", "wrong-password-1"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected status %d, got %d", i, http.StatusUnauthorized, w.Code)
+		}
+	}
+	w := postCredentials(router, "/api/v1/auth/login", "ann", "wrong-password-1")
+	if w.Code != http.StatusLocked || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected status %d with Retry-After, got %d", http.StatusLocked, w.Code)
+	}
+
+	// Even the right password is refused while locked
+	if w := postCredentials(router, "/api/v1/auth/login", "ann", "correct-horse-42"); w.Code != http.StatusLocked {
+		t.Errorf("Expected status %d, got %d", http.StatusLocked, w.Code)
+	}
+
+	// Once the lock expires the right password works again
+	accountsMu.Lock()
+	accounts["ann"].lockedUntil = time.Now().Add(-time.Second)
+	accountsMu.Unlock()
+	if w := postCredentials(router, "/api/v1/auth/login", "ann", "correct-horse-42"); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestLogin_LockoutParallel(t *testing.T) {
+	resetAccounts(t)
+	// Slow enough hashing that the attempts overlap
+	BcryptCost = bcrypt.MinCost + 4
+	register("ann", "correct-horse-42", DefaultTenantID)
+
+	// Attempts that were comparing when the lock hit count for nothing,
+	// so parallel guessing gets no more tries than sequential guessing
+	attempts := 4 * MaxFailedLogins
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _, err := login("ann", "wrong-password-1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	invalid := 0
+	for err := range errs {
+		if errors.Is(err, errInvalidCredentials) {
+			invalid++
+		}
+	}
+	if invalid != MaxFailedLogins-1 {
+		t.Errorf("Expected %d wrong-password answers before the lock, got %d", MaxFailedLogins-1, invalid)
+	}
+	if _, _, _, err := login("ann", "correct-horse-42"); err == nil {
+		t.Error("Expected the account to stay locked")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	router := setupTestRouter()
+	resetAccounts(t)
+	defer func(old bool) { AllowDemoTokens = old }(AllowDemoTokens)
+	AllowDemoTokens = false
+
+	token := loginToken(t, router, "ann", "correct-horse-42")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if w := getMe(router, token); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d after logout, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAuthMiddleware_Sessions(t *testing.T) {
+	router := setupTestRouter()
+	resetAccounts(t)
+	token := loginToken(t, router, "ann", "correct-horse-42")
+
+	// Demo tokens are off unless enabled (the tests enable them in TestMain)
+	defer func(old bool) { AllowDemoTokens = old }(AllowDemoTokens)
+	AllowDemoTokens = false
+	if w := getMe(router, "anything"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for unknown token, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	AllowDemoTokens = true
+	if w := getMe(router, "anything"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), DemoUser) {
+		t.Errorf("Expected %s, got %d: %s", DemoUser, w.Code, w.Body.String())
+	}
+
+	// Expired sessions are rejected even though demo tokens are allowed
+	accountsMu.Lock()
+	sess := sessions[hashToken(token)]
+	sess.expiresAt = time.Now().Add(-time.Second)
+	sessions[hashToken(token)] = sess
+	accountsMu.Unlock()
+
+	w := getMe(router, token)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Session expired") {
+		t.Errorf("Expected expired session to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
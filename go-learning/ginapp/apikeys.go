@@ -0,0 +1,486 @@
+package ginapp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// API KEYS
+// ============================================================
+// Machine clients such as batch jobs authenticate with an API key in
+// the X-API-Key header instead of a user session. Admins mint, list,
+// rotate and revoke keys under /api/v1/admin/api-keys.
+//
+//   - the key itself is shown once, when minted or rotated; only its
+//     SHA-256 is stored
+//   - each key carries scopes; a request made with a key is limited to
+//     them. Bearer-authenticated users have every scope but admin,
+//     which needs an account with the admin role (see SetAdmin).
+//   - keys may expire and may be restricted to IPs or CIDR ranges
+//   - a key belongs to the tenant it was minted for and is refused
+//     with any other
+//   - every successful use records the time and address
+// ============================================================
+
+// API key scopes
+const (
+	ScopeBooksRead  = "books:read"
+	ScopeBooksWrite = "books:write"
+	ScopeAdmin      = "admin"
+)
+
+// apiKeyPrefix marks our keys so they are easy to spot in logs and scanners
+const apiKeyPrefix = "gak_"
+
+// APIKey - public view of a key; the secret is never part of it
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // first characters of the key, for identification
+	Scopes     []string   `json:"scopes"`
+	Tenant     string     `json:"tenant"`
+	AllowedIPs []string   `json:"allowed_ips,omitempty"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+}
+
+// CreateAPIKeyInput - input for minting a key
+type CreateAPIKeyInput struct {
+	Name       string     `json:"name" binding:"required,min=1,max=100"`
+	Scopes     []string   `json:"scopes" binding:"required,min=1,dive,oneof=books:read books:write admin"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	AllowedIPs []string   `json:"allowed_ips" binding:"omitempty,dive,required"`
+}
+
+// storedKey is an APIKey with its hash and parsed allowlist
+type storedKey struct {
+	APIKey
+	hash     string
+	prefixes []netip.Prefix
+}
+
+// API key store
+var (
+	apiKeys       = make(map[string]*storedKey) // by ID
+	apiKeysByHash = make(map[string]string)     // key hash -> ID
+	apiKeysMu     sync.Mutex
+)
+
+var (
+	errAPIKeyNotFound = errors.New("API key not found")
+	errAPIKeyRevoked  = errors.New("API key is revoked")
+	errAPIKeyInvalid  = errors.New("Invalid API key")
+	errAPIKeyExpired  = errors.New("API key expired")
+	errAPIKeyIP       = errors.New("API key is not allowed from this address")
+)
+
+// parseAllowedIPs accepts single addresses and CIDR ranges
+func parseAllowedIPs(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// newAPIKeySecret returns a fresh key and its display prefix
+func newAPIKeySecret() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return secret, secret[:len(apiKeyPrefix)+8], nil
+}
+
+// newAPIKeyID returns a short random identifier
+func newAPIKeyID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "key_" + hex.EncodeToString(b), nil
+}
+
+// ============================================================
+// STORE
+// ============================================================
+
+// mintAPIKey stores a new key of a tenant and returns it with its secret
+func mintAPIKey(input CreateAPIKeyInput, createdBy, tenant string) (APIKey, string, error) {
+	prefixes, err := parseAllowedIPs(input.AllowedIPs)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	id, err := newAPIKeyID()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	secret, display, err := newAPIKeySecret()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	key := &storedKey{
+		APIKey: APIKey{
+			ID:         id,
+			Name:       input.Name,
+			Prefix:     display,
+			Scopes:     normalizeScopes(input.Scopes),
+			Tenant:     tenant,
+			AllowedIPs: input.AllowedIPs,
+			CreatedBy:  createdBy,
+			CreatedAt:  time.Now(),
+			ExpiresAt:  input.ExpiresAt,
+		},
+		hash:     hashToken(secret),
+		prefixes: prefixes,
+	}
+
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	apiKeys[id] = key
+	apiKeysByHash[key.hash] = id
+	return key.APIKey, secret, nil
+}
+
+// normalizeScopes sorts scopes and drops duplicates
+func normalizeScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// listAPIKeys returns every key, revoked ones included, oldest first
+func listAPIKeys() []APIKey {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	list := make([]APIKey, 0, len(apiKeys))
+	for _, key := range apiKeys {
+		list = append(list, key.APIKey)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// rotateAPIKey replaces the secret of a key; the old secret stops
+// working immediately
+func rotateAPIKey(id string) (APIKey, string, error) {
+	secret, display, err := newAPIKeySecret()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	key, exists := apiKeys[id]
+	if !exists {
+		return APIKey{}, "", errAPIKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		return APIKey{}, "", errAPIKeyRevoked
+	}
+
+	now := time.Now()
+	delete(apiKeysByHash, key.hash)
+	key.hash = hashToken(secret)
+	key.Prefix = display
+	key.RotatedAt = &now
+	apiKeysByHash[key.hash] = id
+	return key.APIKey, secret, nil
+}
+
+// revokeAPIKey disables a key for good. The record is kept for auditing.
+func revokeAPIKey(id string) error {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	key, exists := apiKeys[id]
+	if !exists {
+		return errAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		delete(apiKeysByHash, key.hash)
+	}
+	return nil
+}
+
+// revokeTenantAPIKeys revokes every key minted for a deleted tenant, so
+// none works again if a tenant with the same ID is created later
+func revokeTenantAPIKeys(tenantID string) {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	now := time.Now()
+	for _, key := range apiKeys {
+		if key.Tenant == tenantID && key.RevokedAt == nil {
+			key.RevokedAt = &now
+			delete(apiKeysByHash, key.hash)
+		}
+	}
+}
+
+// verifyAPIKey checks a presented key from a peer address and records
+// its use
+func verifyAPIKey(secret, remoteIP string) (APIKey, error) {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	id, exists := apiKeysByHash[hashToken(secret)]
+	if !exists {
+		return APIKey{}, errAPIKeyInvalid
+	}
+	key := apiKeys[id]
+
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return APIKey{}, errAPIKeyExpired
+	}
+	if len(key.prefixes) > 0 && !ipAllowed(key.prefixes, remoteIP) {
+		return APIKey{}, errAPIKeyIP
+	}
+
+	key.LastUsedAt = &now
+	key.LastUsedIP = remoteIP
+	return key.APIKey, nil
+}
+
+// ipAllowed reports whether an address falls in one of the prefixes
+func ipAllowed(prefixes []netip.Prefix, remoteIP string) bool {
+	ip, err := netip.ParseAddr(remoteIP)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasScope reports whether a key grants a scope
+func (k APIKey) hasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================
+// MIDDLEWARE
+// ============================================================
+
+// APIKeyMiddleware - authenticates requests carrying X-API-Key. Requests
+// without the header pass through untouched. A valid key sets "user" to
+// "apikey:<name>" and "api_key" to the key, which AuthMiddleware accepts.
+// A key of another tenant is refused with 403.
+func APIKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader("X-API-Key")
+		if secret == "" {
+			c.Next()
+			return
+		}
+
+		// The direct peer address: X-Forwarded-For is not trusted here
+		key, err := verifyAPIKey(secret, c.RemoteIP())
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, errAPIKeyIP) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if key.Tenant != tenantFrom(c).ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": errWrongTenant.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("user", "apikey:"+key.Name)
+		c.Set("api_key", key)
+		c.Next()
+	}
+}
+
+// apiKeyFrom returns the key a request was authenticated with, if any
+func apiKeyFrom(c *gin.Context) (APIKey, bool) {
+	value, exists := c.Get("api_key")
+	if !exists {
+		return APIKey{}, false
+	}
+	return value.(APIKey), true
+}
+
+// scopeAllowed reports whether a request may use a scope. Keys are
+// limited to their scopes; users have all of them except admin, which
+// takes the admin role. The user is taken from the context, or from the
+// Authorization header on routes without AuthMiddleware.
+func scopeAllowed(c *gin.Context, scope string) bool {
+	if key, isKey := apiKeyFrom(c); isKey {
+		return key.hasScope(scope)
+	}
+	if scope != ScopeAdmin {
+		return true
+	}
+	user := c.GetString("user")
+	if user == "" {
+		user, _ = authenticate(c)
+	}
+	return isAdmin(user)
+}
+
+// RequireScope - rejects API-key requests whose key lacks scope, and
+// users without the admin role where scope is admin
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !scopeAllowed(c, scope) {
+			message := "Admin role required"
+			if _, isKey := apiKeyFrom(c); isKey {
+				message = "API key lacks scope " + scope
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": message})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireMethodScope - like RequireScope, using read for GET and HEAD
+// requests and write for everything else
+func RequireMethodScope(read, write string) gin.HandlerFunc {
+	readCheck, writeCheck := RequireScope(read), RequireScope(write)
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			readCheck(c)
+			return
+		}
+		writeCheck(c)
+	}
+}
+
+// ============================================================
+// ADMIN HANDLERS
+// ============================================================
+
+// CreateAPIKey - POST /api/v1/admin/api-keys (the key belongs to the
+// request's tenant)
+func CreateAPIKey(c *gin.Context) {
+	var input CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+	if _, err := parseAllowedIPs(input.AllowedIPs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, secret, err := mintAPIKey(input, c.GetString("user"), tenantFrom(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    key,
+		"key":     secret,
+		"message": "Store this key now, it will not be shown again",
+	})
+}
+
+// ListAPIKeys - GET /api/v1/admin/api-keys
+func ListAPIKeys(c *gin.Context) {
+	list := listAPIKeys()
+	c.JSON(http.StatusOK, gin.H{
+		"data":  list,
+		"count": len(list),
+	})
+}
+
+// RotateAPIKey - POST /api/v1/admin/api-keys/:keyId/rotate
+func RotateAPIKey(c *gin.Context) {
+	key, secret, err := rotateAPIKey(c.Param("keyId"))
+	if err != nil {
+		apiKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    key,
+		"key":     secret,
+		"message": "Store this key now, it will not be shown again",
+	})
+}
+
+// RevokeAPIKey - DELETE /api/v1/admin/api-keys/:keyId
+func RevokeAPIKey(c *gin.Context) {
+	if err := revokeAPIKey(c.Param("keyId")); err != nil {
+		apiKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// apiKeyError maps store errors to responses
+func apiKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errAPIKeyRevoked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update API key"})
+	}
+}
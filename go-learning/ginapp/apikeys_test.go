@@ -0,0 +1,302 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// resetAPIKeys clears the key store
+func resetAPIKeys() {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	apiKeys = make(map[string]*storedKey)
+	apiKeysByHash = make(map[string]string)
+}
+
+// mintTestKey creates a key through the admin API and returns its ID and secret
+func mintTestKey(t *testing.T, router http.Handler, input CreateAPIKeyInput) (string, string) {
+	t.Helper()
+
+	body, _ := json.Marshal(input)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/api-keys", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data APIKey `json:"data"`
+		Key  string `json:"key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response.Data.ID, response.Key
+}
+
+func TestAPIKey_Scopes(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAPIKeys()
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+
+	_, reader := mintTestKey(t, router, CreateAPIKeyInput{Name: "reader", Scopes: []string{ScopeBooksRead}})
+	_, writer := mintTestKey(t, router, CreateAPIKeyInput{Name: "writer", Scopes: []string{ScopeBooksRead, ScopeBooksWrite}})
+
+	newBook := `{"title":"New","author":"B","year":2021}`
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		key            string
+		body           string
+		expectedStatus int
+	}{
+		{name: "read with read scope", method: "GET", path: "/api/v1/books", key: reader, expectedStatus: http.StatusOK},
+		{name: "v2 read with read scope", method: "GET", path: "/api/v2/books/1", key: reader, expectedStatus: http.StatusOK},
+		{name: "write without write scope", method: "POST", path: "/api/v1/books", key: reader, body: newBook, expectedStatus: http.StatusForbidden},
+		{name: "write with write scope", method: "POST", path: "/api/v1/books", key: writer, body: newBook, expectedStatus: http.StatusCreated},
+		{name: "admin without admin scope", method: "GET", path: "/api/v1/admin/stats", key: writer, expectedStatus: http.StatusForbidden},
+		{name: "graphql query", method: "POST", path: "/api/v1/graphql", key: reader, body: `{"query":"{ books { id } }"}`, expectedStatus: http.StatusOK},
+		{name: "graphql mutation", method: "POST", path: "/api/v1/graphql", key: reader, body: `{"query":"mutation { deleteBook(id: 1) }"}`, expectedStatus: http.StatusForbidden},
+		{name: "unknown key", method: "GET", path: "/api/v1/books", key: "gak_nope", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := apiRequest(router, tt.method, tt.path, tt.body, "X-API-Key", tt.key)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIKey_AdminScope(t *testing.T) {
+	router := setupTestRouter()
+	resetAPIKeys()
+
+	_, admin := mintTestKey(t, router, CreateAPIKeyInput{Name: "ops", Scopes: []string{ScopeAdmin}})
+
+	w := apiRequest(router, "GET", "/api/v1/admin/stats", "", "X-API-Key", admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["user"] != "apikey:ops" {
+		t.Errorf("Expected user apikey:ops, got %v", response["user"])
+	}
+}
+
+func TestAPIKey_ListHidesSecretsAndTracksUse(t *testing.T) {
+	router := setupTestRouter()
+	resetAPIKeys()
+
+	id, secret := mintTestKey(t, router, CreateAPIKeyInput{Name: "nightly", Scopes: []string{ScopeBooksRead, ScopeBooksRead}})
+	apiRequest(router, "GET", "/api/v1/books", "", "X-API-Key", secret)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/admin/api-keys", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+
+	if bytes.Contains(w.Body.Bytes(), []byte(secret)) {
+		t.Fatal("Listing must not reveal key secrets")
+	}
+
+	var response struct {
+		Data []APIKey `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].ID != id {
+		t.Fatalf("Unexpected keys: %+v", response.Data)
+	}
+	key := response.Data[0]
+	if key.LastUsedAt == nil || key.LastUsedIP != "192.0.2.1" {
+		t.Errorf("Expected last use to be recorded, got %v from %q", key.LastUsedAt, key.LastUsedIP)
+	}
+	if len(key.Scopes) != 1 || key.CreatedBy != testAdmin || key.Prefix != secret[:12] {
+		t.Errorf("Unexpected key view: %+v", key)
+	}
+}
+
+func TestAPIKey_RotateAndRevoke(t *testing.T) {
+	router := setupTestRouter()
+	resetAPIKeys()
+
+	id, oldSecret := mintTestKey(t, router, CreateAPIKeyInput{Name: "job", Scopes: []string{ScopeBooksRead}})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/api-keys/"+id+"/rotate", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var rotated struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &rotated)
+
+	if w := apiRequest(router, "GET", "/api/v1/books", "", "X-API-Key", oldSecret); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected old secret to stop working, got %d", w.Code)
+	}
+	if w := apiRequest(router, "GET", "/api/v1/books", "", "X-API-Key", rotated.Key); w.Code != http.StatusOK {
+		t.Errorf("Expected new secret to work, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/v1/admin/api-keys/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if w := apiRequest(router, "GET", "/api/v1/books", "", "X-API-Key", rotated.Key); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked key to be rejected, got %d", w.Code)
+	}
+
+	// Revoked keys cannot be brought back by rotating
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/v1/admin/api-keys/"+id+"/rotate", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestAPIKey_ExpiryAndAllowlist(t *testing.T) {
+	router := setupTestRouter()
+	resetAPIKeys()
+
+	soon := time.Now().Add(time.Hour)
+	id, expiring := mintTestKey(t, router, CreateAPIKeyInput{Name: "temp", Scopes: []string{ScopeBooksRead}, ExpiresAt: &soon})
+	_, office := mintTestKey(t, router, CreateAPIKeyInput{Name: "office", Scopes: []string{ScopeBooksRead}, AllowedIPs: []string{"10.0.0.0/8", "2001:db8::1"}})
+
+	if w := apiRequest(router, "GET", "/api/v1/books", "", "X-API-Key", expiring); w.Code != http.StatusOK {
+		t.Errorf("Expected unexpired key to work, got %d", w.Code)
+	}
+	apiKeysMu.Lock()
+	past := time.Now().Add(-time.Second)
+	apiKeys[id].ExpiresAt = &past
+	apiKeysMu.Unlock()
+	if w := apiRequest(router, "GET", "/api/v1/books", "", "X-API-Key", expiring); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected expired key to be rejected, got %d", w.Code)
+	}
+
+	tests := []struct {
+		remoteAddr     string
+		forwardedFor   string
+		expectedStatus int
+	}{
+		{remoteAddr: "10.1.2.3:5000", expectedStatus: http.StatusOK},
+		{remoteAddr: "[2001:db8::1]:5000", expectedStatus: http.StatusOK},
+		{remoteAddr: "192.0.2.1:5000", expectedStatus: http.StatusForbidden},
+		{remoteAddr: "192.0.2.1:5000", forwardedFor: "10.1.2.3", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/books", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-API-Key", office)
+		if tt.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s (XFF %q): expected status %d, got %d", tt.remoteAddr, tt.forwardedFor, tt.expectedStatus, w.Code)
+		}
+	}
+}
+
+func TestCreateAPIKey_Validation(t *testing.T) {
+	router := setupTestRouter()
+	resetAPIKeys()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "no scopes", body: `{"name":"x","scopes":[]}`},
+		{name: "unknown scope", body: `{"name":"x","scopes":["books:delete"]}`},
+		{name: "bad allowlist", body: `{"name":"x","scopes":["books:read"],"allowed_ips":["10.0.0.300"]}`},
+		{name: "expired already", body: `{"name":"x","scopes":["books:read"],"expires_at":"2000-01-01T00:00:00Z"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/admin/api-keys", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+adminToken(t))
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminRoutes_RequireAdminRole(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	resetAPIKeys()
+
+	user := loginToken(t, router, "ann", "correct-horse-42")
+	admin := adminToken(t)
+
+	paths := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: "GET", path: "/api/v1/admin/stats"},
+		{method: "POST", path: "/api/v1/admin/api-keys", body: `{"name": "x", "scopes": ["admin"]}`},
+		{method: "POST", path: "/api/v1/admin/backup"},
+		{method: "DELETE", path: "/api/v1/admin/tenants/acme"},
+		{method: "PUT", path: "/api/v1/admin/flags/graphql", body: `{"enabled": false}`},
+	}
+	for _, p := range paths {
+		for _, token := range []string{user, "mytoken"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(p.method, p.path, bytes.NewBufferString(p.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("%s %s with %s: expected status %d, got %d: %s", p.method, p.path, token, http.StatusForbidden, w.Code, w.Body.String())
+			}
+		}
+	}
+
+	// The admin role opens them
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/admin/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected admin to get stats, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,662 @@
+package ginapp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"go-learning/algorithms"
+)
+
+// ============================================================
+// BACKUP AND RESTORE
+// ============================================================
+// POST /api/v1/admin/backup returns the requesting tenant's catalog as a
+// gzip-compressed tar archive:
+//
+//   manifest.json - format, version, source tenant, counts and the
+//                   SHA-256 of catalog.json
+//   catalog.json  - books, reviews, copies, loans, holds, shelves and
+//                   their ID counters
+//
+// POST /api/v1/admin/restore takes such an archive as the request body,
+// verifies and validates it completely, then swaps it in under one write
+// lock: readers see either the old catalog or the new one, never a mix.
+//
+// The snapshot is copied under the read lock and encoded after it is
+// released, so writers are only blocked for the duration of a map copy.
+// Cover images are files on disk and are not part of the archive; covers
+// of books that exist in the restored catalog are kept, others deleted.
+//
+// Version 1 archives hold only books and reviews. Restoring one keeps
+// the current lending state and shelves of the books it brings back.
+// ============================================================
+
+// BackupFormatVersion is written to every manifest. Restore accepts
+// archives of this version or older.
+const BackupFormatVersion = 2
+
+// backupFormat identifies our archives
+const backupFormat = "ginapp-backup"
+
+// MaxBackupSize limits an uploaded archive and each file inside it
+var MaxBackupSize int64 = 64 << 20 // 64 MiB
+
+// backupManifest - contents of manifest.json
+type backupManifest struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	Tenant    string    `json:"tenant"`
+	CreatedAt time.Time `json:"created_at"`
+	Books     int       `json:"books"`
+	Reviews   int       `json:"reviews"`
+	Loans     int       `json:"loans"`   // since version 2
+	Holds     int       `json:"holds"`   // since version 2
+	Shelves   int       `json:"shelves"` // since version 2
+	SHA256    string    `json:"sha256"`
+}
+
+// backupBook - every stored field of a Book. Book itself hides the v2
+// fields from JSON and carries computed fields that are not stored.
+type backupBook struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author"`
+	Authors   []Author  `json:"authors,omitempty"`
+	Year      int       `json:"year"`
+	ISBN      string    `json:"isbn,omitempty"`
+	Publisher string    `json:"publisher,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupShelf - a Shelf with its sharing token, without the response
+// fields
+type backupShelf struct {
+	ID          int       `json:"id"`
+	Owner       string    `json:"owner"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Visibility  string    `json:"visibility"`
+	BookIDs     []int     `json:"book_ids"`
+	ShareToken  string    `json:"share_token,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// catalogSnapshot - contents of catalog.json
+type catalogSnapshot struct {
+	NextBookID   int          `json:"next_book_id"`
+	NextReviewID int          `json:"next_review_id"`
+	Books        []backupBook `json:"books"`
+	Reviews      []Review     `json:"reviews"`
+
+	// Since version 2
+	NextLoanID  int           `json:"next_loan_id"`
+	NextHoldID  int           `json:"next_hold_id"`
+	NextShelfID int           `json:"next_shelf_id"`
+	Copies      map[int]int   `json:"copies,omitempty"` // by book ID, if not DefaultCopies
+	Loans       []Loan        `json:"loans"`
+	Holds       []Hold        `json:"holds"`
+	Shelves     []backupShelf `json:"shelves"`
+
+	// legacy is set for version 1 archives, which carry no lending
+	// state or shelves
+	legacy bool
+}
+
+// errInvalidBackup wraps every reason an archive is rejected
+var errInvalidBackup = errors.New("invalid backup")
+
+func invalidBackup(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errInvalidBackup, fmt.Sprintf(format, args...))
+}
+
+// ============================================================
+// CATALOG METHODS
+// ============================================================
+
+// snapshot returns a point-in-time copy of the catalog. Only the map
+// copy happens under the read lock; conversion and sorting do not.
+// Book and Review values are copied; their slices are shared, which is
+// safe because updates always replace slices rather than mutate them.
+// Shelves reorder their book IDs in place, so those are cloned.
+func (cat *catalog) snapshot() catalogSnapshot {
+	cat.booksMu.RLock()
+	// Writers hold booksMu, so the books match the reviews
+	books, _ := cat.books.snapshot(context.Background())
+	reviews := make([]Review, 0, len(cat.reviews))
+	for _, r := range cat.reviews {
+		reviews = append(reviews, r)
+	}
+	snap := catalogSnapshot{
+		NextBookID:   cat.books.peekID(),
+		NextReviewID: cat.reviewID,
+		NextLoanID:   cat.loanID,
+		NextHoldID:   cat.holdID,
+		NextShelfID:  cat.shelfID,
+		Copies:       maps.Clone(cat.copies),
+		Loans:        make([]Loan, 0, len(cat.loans)),
+		Holds:        make([]Hold, 0, len(cat.holds)),
+		Shelves:      make([]backupShelf, 0, len(cat.shelves)),
+	}
+	for _, l := range cat.loans {
+		snap.Loans = append(snap.Loans, *l)
+	}
+	for _, h := range cat.holds {
+		snap.Holds = append(snap.Holds, *h)
+	}
+	for _, shelf := range cat.shelves {
+		snap.Shelves = append(snap.Shelves, backupShelf{
+			ID:          shelf.ID,
+			Owner:       shelf.Owner,
+			Name:        shelf.Name,
+			Description: shelf.Description,
+			Visibility:  shelf.Visibility,
+			BookIDs:     slices.Clone(shelf.BookIDs),
+			ShareToken:  shelf.shareToken,
+			CreatedAt:   shelf.CreatedAt,
+			UpdatedAt:   shelf.UpdatedAt,
+		})
+	}
+	cat.booksMu.RUnlock()
+
+	snap.Books = make([]backupBook, len(books))
+	for i, b := range books {
+		snap.Books[i] = backupBook{
+			ID:        b.ID,
+			Title:     b.Title,
+			Author:    b.Author,
+			Authors:   b.Authors,
+			Year:      b.Year,
+			ISBN:      b.ISBN,
+			Publisher: b.Publisher,
+			Language:  b.Language,
+			Tags:      b.Tags,
+			CreatedAt: b.CreatedAt,
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].ID < reviews[j].ID })
+	snap.Reviews = reviews
+	sort.Slice(snap.Loans, func(i, j int) bool { return snap.Loans[i].ID < snap.Loans[j].ID })
+	sort.Slice(snap.Holds, func(i, j int) bool { return snap.Holds[i].ID < snap.Holds[j].ID })
+	sort.Slice(snap.Shelves, func(i, j int) bool { return snap.Shelves[i].ID < snap.Shelves[j].ID })
+	return snap
+}
+
+// restore replaces the whole catalog with a validated snapshot and
+// returns the IDs of books whose cover files must be deleted
+func (cat *catalog) restore(snap catalogSnapshot) ([]int, error) {
+	if err := snap.validate(); err != nil {
+		return nil, err
+	}
+	if cat.maxBooks > 0 && len(snap.Books) > cat.maxBooks {
+		return nil, errQuotaExceeded
+	}
+
+	// Build the new state before taking the lock
+	books := make([]Book, 0, len(snap.Books))
+	for _, b := range snap.Books {
+		books = append(books, Book{
+			ID:        b.ID,
+			Title:     b.Title,
+			Author:    b.Author,
+			Authors:   b.Authors,
+			Year:      b.Year,
+			ISBN:      b.ISBN,
+			Publisher: b.Publisher,
+			Language:  b.Language,
+			Tags:      b.Tags,
+			CreatedAt: b.CreatedAt,
+		})
+	}
+	reviews := make(map[int]Review, len(snap.Reviews))
+	ratings := make(map[int]ratingTotals)
+	for _, r := range snap.Reviews {
+		reviews[r.ID] = r
+		totals := ratings[r.BookID]
+		totals.sum += r.Rating
+		totals.count++
+		ratings[r.BookID] = totals
+	}
+
+	// The neighbor index compares every pair of books; build it unlocked too
+	index := &catalog{books: newBookStore(BookStoreShards)}
+	index.books.load(books, snap.NextBookID)
+	index.reindex(books)
+
+	// Lending state and shelves go into a scratch catalog, swapped in below
+	lending := newCatalog(0)
+	if !snap.legacy {
+		lending.loanID, lending.holdID, lending.shelfID = snap.NextLoanID, snap.NextHoldID, snap.NextShelfID
+		maps.Copy(lending.copies, snap.Copies)
+		for _, l := range snap.Loans {
+			loan := l
+			loan.Overdue = false
+			lending.loans[loan.ID] = &loan
+		}
+		for _, h := range snap.Holds {
+			hold := h
+			hold.Position = 0
+			lending.holds[hold.ID] = &hold
+			if hold.Status != HoldWaiting {
+				continue
+			}
+			queue := lending.waitlists[hold.BookID]
+			if queue == nil {
+				queue = algorithms.NewPriorityQueueFunc(holdBefore)
+				lending.waitlists[hold.BookID] = queue
+			}
+			queue.Push(&hold)
+		}
+		for _, s := range snap.Shelves {
+			lending.shelves[s.ID] = &Shelf{
+				ID:          s.ID,
+				Owner:       s.Owner,
+				Name:        s.Name,
+				Description: s.Description,
+				Visibility:  s.Visibility,
+				BookIDs:     s.BookIDs,
+				CreatedAt:   s.CreatedAt,
+				UpdatedAt:   s.UpdatedAt,
+				shareToken:  s.ShareToken,
+			}
+		}
+	}
+
+	cat.similarMu.Lock()
+	defer cat.similarMu.Unlock()
+	cat.booksMu.Lock()
+	defer cat.booksMu.Unlock()
+
+	var staleCovers []int
+	covers := make(map[int]coverInfo)
+	for id, info := range cat.covers {
+		if _, exists := index.books.get(id); exists {
+			covers[id] = info
+		} else {
+			staleCovers = append(staleCovers, id)
+		}
+	}
+
+	cat.reviews = reviews
+	cat.reviewID = snap.NextReviewID
+	cat.ratings = ratings
+	cat.covers = covers
+	for i := range books {
+		books[i] = cat.decorate(books[i])
+	}
+	cat.books.load(books, snap.NextBookID)
+	cat.similar = index.similar
+	cat.staleMu.Lock()
+	cat.stale = make(map[int]bool) // the new index covers every book
+	cat.staleMu.Unlock()
+	if snap.legacy {
+		cat.pruneLending()
+		cat.pruneShelves()
+	} else {
+		cat.copies, cat.waitlists = lending.copies, lending.waitlists
+		cat.loans, cat.loanID = lending.loans, lending.loanID
+		cat.holds, cat.holdID = lending.holds, lending.holdID
+		cat.shelves, cat.shelfID = lending.shelves, lending.shelfID
+	}
+	return staleCovers, nil
+}
+
+// validate checks that a snapshot describes a consistent catalog: unique
+// IDs below their counters, books that would pass CreateBook's
+// validation, and reviews, copies, holds and shelves that point at
+// existing books. Loans are history and may outlive their book.
+func (snap catalogSnapshot) validate() error {
+	if snap.NextBookID < 1 || snap.NextReviewID < 1 {
+		return invalidBackup("ID counters must be positive")
+	}
+
+	bookIDs := make(map[int]bool, len(snap.Books))
+	for _, b := range snap.Books {
+		if b.ID < 1 || b.ID >= snap.NextBookID {
+			return invalidBackup("book %d is outside the ID counter %d", b.ID, snap.NextBookID)
+		}
+		if bookIDs[b.ID] {
+			return invalidBackup("duplicate book ID %d", b.ID)
+		}
+		bookIDs[b.ID] = true
+
+		input := CreateBookInput{Title: b.Title, Author: b.Author, Year: b.Year, ISBN: b.ISBN}
+		if err := binding.Validator.ValidateStruct(&input); err != nil {
+			return invalidBackup("book %d: %v", b.ID, err)
+		}
+	}
+
+	reviewIDs := make(map[int]bool, len(snap.Reviews))
+	reviewers := make(map[string]bool, len(snap.Reviews))
+	for _, r := range snap.Reviews {
+		if r.ID < 1 || r.ID >= snap.NextReviewID {
+			return invalidBackup("review %d is outside the ID counter %d", r.ID, snap.NextReviewID)
+		}
+		if reviewIDs[r.ID] {
+			return invalidBackup("duplicate review ID %d", r.ID)
+		}
+		reviewIDs[r.ID] = true
+
+		if !bookIDs[r.BookID] {
+			return invalidBackup("review %d refers to missing book %d", r.ID, r.BookID)
+		}
+		if r.Rating < 1 || r.Rating > 5 {
+			return invalidBackup("review %d has rating %d", r.ID, r.Rating)
+		}
+		key := fmt.Sprintf("%d/%s", r.BookID, r.User)
+		if reviewers[key] {
+			return invalidBackup("user %q reviewed book %d twice", r.User, r.BookID)
+		}
+		reviewers[key] = true
+	}
+
+	if snap.legacy {
+		return nil
+	}
+	return snap.validateLending(bookIDs)
+}
+
+// validateLending checks the lending state and shelves of a snapshot
+// against its books
+func (snap catalogSnapshot) validateLending(bookIDs map[int]bool) error {
+	if snap.NextLoanID < 1 || snap.NextHoldID < 1 || snap.NextShelfID < 1 {
+		return invalidBackup("ID counters must be positive")
+	}
+
+	for id, copies := range snap.Copies {
+		if !bookIDs[id] {
+			return invalidBackup("copies refer to missing book %d", id)
+		}
+		if copies < 0 || copies > 1000 {
+			return invalidBackup("book %d has %d copies", id, copies)
+		}
+	}
+
+	loanIDs := make(map[int]bool, len(snap.Loans))
+	for _, l := range snap.Loans {
+		if l.ID < 1 || l.ID >= snap.NextLoanID {
+			return invalidBackup("loan %d is outside the ID counter %d", l.ID, snap.NextLoanID)
+		}
+		if loanIDs[l.ID] {
+			return invalidBackup("duplicate loan ID %d", l.ID)
+		}
+		loanIDs[l.ID] = true
+		if l.User == "" || l.DueAt.Before(l.CheckedOutAt) {
+			return invalidBackup("loan %d is incomplete", l.ID)
+		}
+	}
+
+	holdIDs := make(map[int]bool, len(snap.Holds))
+	holders := make(map[string]bool, len(snap.Holds))
+	for _, h := range snap.Holds {
+		if h.ID < 1 || h.ID >= snap.NextHoldID {
+			return invalidBackup("hold %d is outside the ID counter %d", h.ID, snap.NextHoldID)
+		}
+		if holdIDs[h.ID] {
+			return invalidBackup("duplicate hold ID %d", h.ID)
+		}
+		holdIDs[h.ID] = true
+
+		if !bookIDs[h.BookID] {
+			return invalidBackup("hold %d refers to missing book %d", h.ID, h.BookID)
+		}
+		if h.User == "" || h.Priority < 0 || h.Priority > 10 {
+			return invalidBackup("hold %d is incomplete", h.ID)
+		}
+		switch {
+		case h.Status == HoldWaiting && h.ReservedUntil == nil:
+		case h.Status == HoldReserved && h.ReservedUntil != nil:
+		default:
+			return invalidBackup("hold %d has status %q", h.ID, h.Status)
+		}
+		key := fmt.Sprintf("%d/%s", h.BookID, h.User)
+		if holders[key] {
+			return invalidBackup("user %q holds book %d twice", h.User, h.BookID)
+		}
+		holders[key] = true
+	}
+
+	shelfIDs := make(map[int]bool, len(snap.Shelves))
+	shelfNames := make(map[string]bool, len(snap.Shelves))
+	for _, s := range snap.Shelves {
+		if s.ID < 1 || s.ID >= snap.NextShelfID {
+			return invalidBackup("shelf %d is outside the ID counter %d", s.ID, snap.NextShelfID)
+		}
+		if shelfIDs[s.ID] {
+			return invalidBackup("duplicate shelf ID %d", s.ID)
+		}
+		shelfIDs[s.ID] = true
+
+		input := CreateShelfInput{Name: s.Name, Description: s.Description, Visibility: s.Visibility}
+		if err := binding.Validator.ValidateStruct(&input); err != nil || s.Owner == "" || s.Visibility == "" {
+			return invalidBackup("shelf %d is invalid", s.ID)
+		}
+		key := s.Owner + "/" + s.Name
+		if shelfNames[key] {
+			return invalidBackup("user %q has two shelves named %q", s.Owner, s.Name)
+		}
+		shelfNames[key] = true
+
+		shelved := make(map[int]bool, len(s.BookIDs))
+		for _, id := range s.BookIDs {
+			if !bookIDs[id] || shelved[id] {
+				return invalidBackup("shelf %d lists book %d more than once or without the book", s.ID, id)
+			}
+			shelved[id] = true
+		}
+	}
+	return nil
+}
+
+// ============================================================
+// ARCHIVE FORMAT
+// ============================================================
+
+// writeBackup encodes a snapshot as a tar.gz archive
+func writeBackup(w io.Writer, tenantID string, snap catalogSnapshot) (backupManifest, error) {
+	catalogJSON, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return backupManifest{}, err
+	}
+	sum := sha256.Sum256(catalogJSON)
+
+	manifest := backupManifest{
+		Format:    backupFormat,
+		Version:   BackupFormatVersion,
+		Tenant:    tenantID,
+		CreatedAt: time.Now().UTC(),
+		Books:     len(snap.Books),
+		Reviews:   len(snap.Reviews),
+		Loans:     len(snap.Loans),
+		Holds:     len(snap.Holds),
+		Shelves:   len(snap.Shelves),
+		SHA256:    hex.EncodeToString(sum[:]),
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return backupManifest{}, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{"manifest.json", manifestJSON},
+		{"catalog.json", catalogJSON},
+	} {
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0o644,
+			Size:    int64(len(file.data)),
+			ModTime: manifest.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return backupManifest{}, err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return backupManifest{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return backupManifest{}, err
+	}
+	return manifest, gz.Close()
+}
+
+// readBackup decodes and verifies an archive written by writeBackup.
+// Read errors are wrapped with %w so the caller can detect an oversized
+// body; everything else wraps errInvalidBackup.
+func readBackup(r io.Reader) (backupManifest, catalogSnapshot, error) {
+	var manifest backupManifest
+	var snap catalogSnapshot
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, snap, fmt.Errorf("%w: not a gzip archive: %w", errInvalidBackup, err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, snap, fmt.Errorf("%w: %w", errInvalidBackup, err)
+		}
+		if header.Name != "manifest.json" && header.Name != "catalog.json" {
+			return manifest, snap, invalidBackup("unexpected file %q", header.Name)
+		}
+		if _, seen := files[header.Name]; seen {
+			return manifest, snap, invalidBackup("duplicate file %q", header.Name)
+		}
+
+		// Guard against decompression bombs
+		data, err := io.ReadAll(io.LimitReader(tr, MaxBackupSize+1))
+		if err != nil {
+			return manifest, snap, fmt.Errorf("%w: %w", errInvalidBackup, err)
+		}
+		if int64(len(data)) > MaxBackupSize {
+			return manifest, snap, invalidBackup("%s is larger than %d bytes", header.Name, MaxBackupSize)
+		}
+		files[header.Name] = data
+	}
+
+	manifestJSON, catalogJSON := files["manifest.json"], files["catalog.json"]
+	if manifestJSON == nil || catalogJSON == nil {
+		return manifest, snap, invalidBackup("archive must contain manifest.json and catalog.json")
+	}
+
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return manifest, snap, invalidBackup("manifest.json: %v", err)
+	}
+	if manifest.Format != backupFormat {
+		return manifest, snap, invalidBackup("unknown format %q", manifest.Format)
+	}
+	if manifest.Version < 1 || manifest.Version > BackupFormatVersion {
+		return manifest, snap, invalidBackup("unsupported version %d (supported: 1-%d)", manifest.Version, BackupFormatVersion)
+	}
+
+	sum := sha256.Sum256(catalogJSON)
+	if hex.EncodeToString(sum[:]) != manifest.SHA256 {
+		return manifest, snap, invalidBackup("checksum mismatch")
+	}
+
+	if err := json.Unmarshal(catalogJSON, &snap); err != nil {
+		return manifest, snap, invalidBackup("catalog.json: %v", err)
+	}
+	if len(snap.Books) != manifest.Books || len(snap.Reviews) != manifest.Reviews ||
+		len(snap.Loans) != manifest.Loans || len(snap.Holds) != manifest.Holds || len(snap.Shelves) != manifest.Shelves {
+		return manifest, snap, invalidBackup("counts do not match the manifest")
+	}
+	snap.legacy = manifest.Version < 2
+	return manifest, snap, nil
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+// BackupCatalog - POST /api/v1/admin/backup
+func BackupCatalog(c *gin.Context) {
+	tenant := tenantFrom(c)
+
+	// Encode into memory first so a failure can still become a 500
+	var buf bytes.Buffer
+	manifest, err := writeBackup(&buf, tenant.ID, tenant.catalog.snapshot())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup"})
+		return
+	}
+
+	filename := fmt.Sprintf("ginapp-%s-%s.tar.gz", tenant.ID, manifest.CreatedAt.Format("20060102T150405Z"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("X-Backup-SHA256", manifest.SHA256)
+	c.Data(http.StatusOK, "application/gzip", buf.Bytes())
+}
+
+// RestoreCatalog - POST /api/v1/admin/restore (body: archive from BackupCatalog)
+func RestoreCatalog(c *gin.Context) {
+	tenant := tenantFrom(c)
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, MaxBackupSize)
+	manifest, snap, err := readBackup(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Backup archive is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	staleCovers, err := tenant.catalog.restore(snap)
+	if err != nil {
+		if errors.Is(err, errInvalidBackup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		storeError(c, err)
+		return
+	}
+	for _, id := range staleCovers {
+		removeCoverFiles(tenant.ID, id)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Catalog restored successfully",
+		"data": gin.H{
+			"tenant":            tenant.ID,
+			"source_tenant":     manifest.Tenant,
+			"backup_created_at": manifest.CreatedAt,
+			"books":             len(snap.Books),
+			"reviews":           len(snap.Reviews),
+			"loans":             len(snap.Loans),
+			"holds":             len(snap.Holds),
+			"shelves":           len(snap.Shelves),
+		},
+	})
+}
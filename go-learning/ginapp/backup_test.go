@@ -0,0 +1,330 @@
+package ginapp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// downloadBackup calls the backup endpoint and returns the archive
+func downloadBackup(t *testing.T, router http.Handler) []byte {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/backup", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/gzip" {
+		t.Errorf("Unexpected Content-Type %q", w.Header().Get("Content-Type"))
+	}
+	return w.Body.Bytes()
+}
+
+// uploadRestore posts an archive to the restore endpoint
+func uploadRestore(t *testing.T, router http.Handler, archive []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/restore", bytes.NewReader(archive))
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	req.Header.Set("Content-Type", "application/gzip")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// buildArchive writes a tar.gz with the given files, in order
+func buildArchive(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0o644, Size: int64(len(f[1]))})
+		tw.Write([]byte(f[1]))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// archiveFor builds a well-formed archive around a catalog.json body
+func archiveFor(t *testing.T, version int, catalogJSON string, books, reviews int) []byte {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(catalogJSON))
+	manifest, _ := json.Marshal(backupManifest{
+		Format:  backupFormat,
+		Version: version,
+		Tenant:  "other",
+		Books:   books,
+		Reviews: reviews,
+		SHA256:  hex.EncodeToString(sum[:]),
+	})
+	return buildArchive(t, [2]string{"manifest.json", string(manifest)}, [2]string{"catalog.json", catalogJSON})
+}
+
+func setupBackupTest(t *testing.T) http.Handler {
+	router := setupTestRouter()
+	resetBooks()
+	CoverStorageDir = t.TempDir()
+
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "Go", Author: "A & B", Authors: splitAuthors("A & B"), Year: 2020, Tags: []string{"go"}, Language: "en"})
+	cat.insert(context.Background(), Book{Title: "Rust", Author: "C", Year: 2021})
+	cat.insert(context.Background(), Book{Title: "Zig", Author: "D", Year: 2022})
+	cat.remove(context.Background(), 3) // leaves a gap so the counter matters
+	cat.addReview(context.Background(), Review{BookID: 1, User: "alice", Rating: 4})
+	return router
+}
+
+func TestBackupRestore_RoundTrip(t *testing.T) {
+	router := setupBackupTest(t)
+	archive := downloadBackup(t, router)
+
+	// Change everything after the backup was taken
+	cat := defaultTenant().catalog
+	cat.remove(context.Background(), 1)
+	cat.modify(context.Background(), 2, func(b *Book) { b.Title = "Changed" })
+	cat.insert(context.Background(), Book{Title: "New", Author: "E", Year: 2023})
+
+	w := uploadRestore(t, router, archive)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if cat.count() != 2 {
+		t.Fatalf("Expected 2 books after restore, got %d", cat.count())
+	}
+	book, _ := cat.find(context.Background(), 1)
+	if book.Title != "Go" || len(book.Authors) != 2 || book.Language != "en" || len(book.Tags) != 1 {
+		t.Errorf("Book 1 not restored with all fields: %+v", book)
+	}
+	if book.RatingCount != 1 || book.AverageRating != 4 {
+		t.Errorf("Expected ratings to be rebuilt, got %v/%d", book.AverageRating, book.RatingCount)
+	}
+	if book, _ := cat.find(context.Background(), 2); book.Title != "Rust" {
+		t.Errorf("Expected book 2 title to be restored, got %q", book.Title)
+	}
+
+	// The ID counter is restored too, so the deleted ID 3 is not reused
+	created, _ := cat.insert(context.Background(), Book{Title: "After", Author: "F", Year: 2024})
+	if created.ID != 4 {
+		t.Errorf("Expected next ID 4, got %d", created.ID)
+	}
+}
+
+func TestBackupRestore_LendingAndShelves(t *testing.T) {
+	router := setupBackupTest(t)
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	now := time.Now()
+
+	cat.setCopies(ctx, 1, 1, now)
+	cat.setCopies(ctx, 2, 3, now)
+	cat.checkout(ctx, 1, "ann", now)
+	cat.joinWaitlist(ctx, 1, "bob", 0, now)
+	cat.joinWaitlist(ctx, 1, "cat", 5, now.Add(time.Minute))
+	shelf, _ := cat.createShelf(ctx, Shelf{Owner: "ann", Name: "Favourites", Visibility: ShelfPublic})
+	cat.addToShelf(ctx, shelf.ID, "ann", 2, 0)
+	cat.addToShelf(ctx, shelf.ID, "ann", 1, 0)
+	cat.shareShelf(ctx, shelf.ID, "ann", "share-token")
+	archive := downloadBackup(t, router)
+
+	// Change the lending state and shelves after the backup was taken
+	cat.returnBook(ctx, 1, "ann", now)
+	cat.leaveWaitlist(ctx, 1, "cat", now)
+	cat.setCopies(ctx, 2, 0, now)
+	cat.createShelf(ctx, Shelf{Owner: "bob", Name: "Later", Visibility: ShelfPrivate})
+	cat.shareShelf(ctx, shelf.ID, "ann", "")
+	cat.reorderShelf(ctx, shelf.ID, "ann", []int{1, 2})
+
+	if w := uploadRestore(t, router, archive); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if a, _ := cat.availability(ctx, 1); a.Copies != 1 || a.OnLoan != 1 || a.Waiting != 2 {
+		t.Errorf("Unexpected availability of book 1: %+v", a)
+	}
+	if a, _ := cat.availability(ctx, 2); a.Copies != 3 || a.Available != 3 {
+		t.Errorf("Unexpected availability of book 2: %+v", a)
+	}
+	if loans, _ := cat.listLoans(ctx, now, func(l *Loan) bool { return l.active() }); len(loans) != 1 || loans[0].User != "ann" {
+		t.Errorf("Expected ann's loan back, got %+v", loans)
+	}
+	if got := waitlistUsers(t, cat, 1); got != "cat:waiting, bob:waiting" {
+		t.Errorf("Expected the waitlist in priority order, got %q", got)
+	}
+	restored, err := cat.sharedShelf(ctx, "share-token")
+	if err != nil || restored.Name != "Favourites" || !slices.Equal(restored.BookIDs, []int{2, 1}) {
+		t.Errorf("Expected the shared shelf back, got %+v, %v", restored, err)
+	}
+	if bobs, _ := cat.listShelves(ctx, "bob", "bob"); len(bobs) != 0 {
+		t.Error("Expected the shelf created after the backup to be gone")
+	}
+
+	// The counters are restored too, so IDs are not reused
+	next, _ := cat.createShelf(ctx, Shelf{Owner: "bob", Name: "Next", Visibility: ShelfPrivate})
+	if next.ID != shelf.ID+1 {
+		t.Errorf("Expected shelf ID %d, got %d", shelf.ID+1, next.ID)
+	}
+}
+
+func TestRestore_Version1KeepsLending(t *testing.T) {
+	router := setupBackupTest(t)
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.checkout(ctx, 1, "ann", time.Now())
+	mine, _ := cat.createShelf(ctx, Shelf{Owner: "ann", Name: "Mine", Visibility: ShelfPrivate})
+	cat.addToShelf(ctx, mine.ID, "ann", 1, 0)
+	cat.addToShelf(ctx, mine.ID, "ann", 2, 0)
+
+	// A version 1 archive brings back book 1 only
+	v1 := `{"next_book_id": 4, "next_review_id": 1, "books": [{"id": 1, "title": "Go", "author": "A", "year": 2020}], "reviews": []}`
+	if w := uploadRestore(t, router, archiveFor(t, 1, v1, 1, 0)); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if a, _ := cat.availability(ctx, 1); a.OnLoan != 1 {
+		t.Errorf("Expected the loan to be kept, got %+v", a)
+	}
+	if shelves, _ := cat.listShelves(ctx, "ann", "ann"); len(shelves) != 1 || !slices.Equal(shelves[0].BookIDs, []int{1}) {
+		t.Errorf("Expected the shelf to keep book 1 only, got %+v", shelves)
+	}
+}
+
+func TestBackup_Manifest(t *testing.T) {
+	router := setupBackupTest(t)
+	archive := downloadBackup(t, router)
+
+	manifest, snap, err := readBackup(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if manifest.Version != BackupFormatVersion || manifest.Tenant != DefaultTenantID {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if manifest.Books != 2 || manifest.Reviews != 1 || snap.NextBookID != 4 {
+		t.Errorf("Unexpected contents: %+v, next ID %d", manifest, snap.NextBookID)
+	}
+}
+
+func TestRestore_Rejected(t *testing.T) {
+	router := setupBackupTest(t)
+	valid := `{"next_book_id": 2, "next_review_id": 1, "books": [{"id": 1, "title": "T", "author": "A", "year": 2000}], "reviews": []}`
+
+	sum := sha256.Sum256([]byte(valid))
+	badSum, _ := json.Marshal(backupManifest{Format: backupFormat, Version: 1, Books: 1, SHA256: hex.EncodeToString(sum[:])})
+
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{name: "not gzip", archive: []byte("plain text")},
+		{name: "missing catalog", archive: buildArchive(t, [2]string{"manifest.json", "{}"})},
+		{name: "unexpected file", archive: buildArchive(t, [2]string{"../etc/passwd", "x"})},
+		{name: "future version", archive: archiveFor(t, BackupFormatVersion+1, valid, 1, 0)},
+		{name: "checksum mismatch", archive: buildArchive(t, [2]string{"manifest.json", string(badSum)}, [2]string{"catalog.json", valid + " "})},
+		{name: "count mismatch", archive: archiveFor(t, 1, valid, 2, 0)},
+		{name: "book beyond counter", archive: archiveFor(t, 1, `{"next_book_id": 1, "next_review_id": 1, "books": [{"id": 1, "title": "T", "author": "A", "year": 2000}]}`, 1, 0)},
+		{name: "invalid book", archive: archiveFor(t, 1, `{"next_book_id": 2, "next_review_id": 1, "books": [{"id": 1, "title": "", "author": "A", "year": 2000}]}`, 1, 0)},
+		{name: "orphan review", archive: archiveFor(t, 1, `{"next_book_id": 1, "next_review_id": 2, "reviews": [{"id": 1, "book_id": 7, "user": "u", "rating": 3}]}`, 0, 1)},
+		{name: "missing counters", archive: archiveFor(t, 2, valid, 1, 0)},
+		{name: "orphan hold", archive: archiveFor(t, 2, `{"next_book_id": 1, "next_review_id": 1, "next_loan_id": 1, "next_hold_id": 2, "next_shelf_id": 1, "holds": [{"id": 1, "book_id": 7, "user": "u", "status": "waiting"}]}`, 0, 0)},
+		{name: "orphan copies", archive: archiveFor(t, 2, `{"next_book_id": 1, "next_review_id": 1, "next_loan_id": 1, "next_hold_id": 1, "next_shelf_id": 1, "copies": {"7": 2}}`, 0, 0)},
+		{name: "shelf with missing book", archive: archiveFor(t, 2, `{"next_book_id": 1, "next_review_id": 1, "next_loan_id": 1, "next_hold_id": 1, "next_shelf_id": 2, "shelves": [{"id": 1, "owner": "u", "name": "S", "visibility": "private", "book_ids": [7]}]}`, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := uploadRestore(t, router, tt.archive)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Nothing was swapped in
+	if defaultTenant().catalog.count() != 2 {
+		t.Errorf("Expected the catalog to be untouched, got %d books", defaultTenant().catalog.count())
+	}
+
+	// Sanity check: the same catalog in a well-formed archive is accepted
+	if w := uploadRestore(t, router, archiveFor(t, 1, valid, 1, 0)); w.Code != http.StatusOK {
+		t.Errorf("Expected valid archive to restore, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRestore_Limits(t *testing.T) {
+	router := setupBackupTest(t)
+	archive := downloadBackup(t, router)
+
+	// Tenant quota applies to restores
+	createTestTenant(t, router, "tiny", 1)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/restore", bytes.NewReader(archive))
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	req.Header.Set("X-Tenant-ID", "tiny")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	defer func(old int64) { MaxBackupSize = old }(MaxBackupSize)
+	MaxBackupSize = 16
+	if w := uploadRestore(t, router, archive); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestBackupRestore_RequireAuth(t *testing.T) {
+	router := setupBackupTest(t)
+
+	for _, path := range []string{"/api/v1/admin/backup", "/api/v1/admin/restore"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusUnauthorized, w.Code)
+		}
+	}
+}
+
+func TestRestore_RemovesStaleCovers(t *testing.T) {
+	router := setupBackupTest(t)
+	archive := downloadBackup(t, router)
+
+	// Book 4 gets a cover but does not exist in the backup
+	cat := defaultTenant().catalog
+	book, _ := cat.insert(context.Background(), Book{Title: "Covered", Author: "G", Year: 2024})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/books/4/cover", bytes.NewReader(testPNG(t, 8, 8)))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Cover upload failed: %d", w.Code)
+	}
+
+	if w := uploadRestore(t, router, archive); w.Code != http.StatusOK {
+		t.Fatalf("Restore failed: %d: %s", w.Code, w.Body.String())
+	}
+	if _, exists := cat.cover(book.ID); exists {
+		t.Error("Expected stale cover metadata to be dropped")
+	}
+	if _, err := os.Stat(coverDir(DefaultTenantID, book.ID)); !os.IsNotExist(err) {
+		t.Errorf("Expected stale cover files to be removed, got %v", err)
+	}
+}
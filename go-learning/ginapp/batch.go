@@ -0,0 +1,372 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ============================================================
+// BATCH
+// ============================================================
+// POST /api/v1/batch applies an ordered list of book operations as one
+// transaction, e.g. to sync edits a client made while offline:
+//
+//   {"operations": [
+//     {"op": "create", "ref": "draft", "body": {"title": "...", "author": "...", "year": 2024}},
+//     {"op": "update", "id": "$draft", "body": {"isbn": "..."}},
+//     {"op": "delete", "id": 7}
+//   ]}
+//
+// Every operation is validated before anything changes. They then run
+// in order under the catalog's write lock, which readers wait for, so
+// nobody sees a batch half applied. If one fails, those before it are
+// undone and the response has the failing operation's status. An
+// id of "$name" refers to the book created earlier with "ref": "name".
+// ============================================================
+
+// MaxBatchOperations - most operations accepted in one batch
+var MaxBatchOperations = 100
+
+// BatchInput - body of POST /api/v1/batch
+type BatchInput struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// BatchOperation - one create, update or delete. Body is a
+// CreateBookInput or an UpdateBookInput.
+type BatchOperation struct {
+	Op   string          `json:"op" binding:"required,oneof=create update delete"`
+	Ref  string          `json:"ref,omitempty"`
+	ID   BatchID         `json:"id"`
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchID - a book ID (7) or a reference to a book created earlier in
+// the batch ("$draft")
+type BatchID struct {
+	ID  int
+	Ref string
+}
+
+// UnmarshalJSON accepts a number or a "$name" string
+func (b *BatchID) UnmarshalJSON(data []byte) error {
+	var ref string
+	if err := json.Unmarshal(data, &ref); err == nil {
+		name, found := strings.CutPrefix(ref, "$")
+		if !found || name == "" {
+			return fmt.Errorf("invalid id %q: use a number or \"$ref\"", ref)
+		}
+		b.Ref = name
+		return nil
+	}
+	return json.Unmarshal(data, &b.ID)
+}
+
+func (b BatchID) isSet() bool {
+	return b.ID != 0 || b.Ref != ""
+}
+
+// BatchResult - outcome of one operation, with the status the single
+// endpoint would have answered
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Ref    string `json:"ref,omitempty"`
+	ID     int    `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Data   *Book  `json:"data,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchStep is a validated operation
+type batchStep struct {
+	BatchOperation
+	create CreateBookInput
+	update UpdateBookInput
+}
+
+// prepareBatch validates all operations and their references
+func prepareBatch(ops []BatchOperation) ([]batchStep, error) {
+	if len(ops) > MaxBatchOperations {
+		return nil, fmt.Errorf("at most %d operations per batch", MaxBatchOperations)
+	}
+
+	refs := make(map[string]bool)
+	steps := make([]batchStep, len(ops))
+	for i, op := range ops {
+		step := batchStep{BatchOperation: op}
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("operation %d: %s", i, fmt.Sprintf(format, args...))
+		}
+
+		if op.Ref != "" && op.Op != "create" {
+			return nil, fail("only create can define a ref")
+		}
+		if op.Ref != "" && refs[op.Ref] {
+			return nil, fail("ref %q is already defined", op.Ref)
+		}
+		if op.Op == "create" && op.ID.isSet() {
+			return nil, fail("create cannot have an id")
+		}
+		if op.Op != "create" && !op.ID.isSet() {
+			return nil, fail("%s requires an id", op.Op)
+		}
+		if op.ID.ID < 0 {
+			return nil, fail("invalid id %d", op.ID.ID)
+		}
+		if op.ID.Ref != "" && !refs[op.ID.Ref] {
+			return nil, fail("ref %q is not created earlier in the batch", op.ID.Ref)
+		}
+
+		var body interface{}
+		switch op.Op {
+		case "create":
+			body = &step.create
+		case "update":
+			body = &step.update
+		}
+		if body != nil {
+			if len(op.Body) == 0 {
+				return nil, fail("%s requires a body", op.Op)
+			}
+			if err := json.Unmarshal(op.Body, body); err != nil {
+				return nil, fail("%v", err)
+			}
+			if err := binding.Validator.ValidateStruct(body); err != nil {
+				return nil, fail("%v", err)
+			}
+		}
+
+		if op.Ref != "" {
+			refs[op.Ref] = true
+		}
+		steps[i] = step
+	}
+	return steps, nil
+}
+
+// ============================================================
+// TRANSACTION
+// ============================================================
+
+// batchTx records how to undo the operations applied so far. It is
+// only used while holding the catalog's write lock.
+type batchTx struct {
+	cat     *catalog
+	refs    map[string]int
+	undo    []func()
+	deleted []int        // book IDs whose cover files go once committed
+	removed map[int]Book // deleted books by operation index
+}
+
+// batchError is the failed operation of a rolled back batch
+type batchError struct {
+	index int
+	err   error
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.index, e.err)
+}
+
+func (e *batchError) Unwrap() error { return e.err }
+
+// applyBatch runs the steps in one transaction. It returns the results
+// so far and a *batchError if an operation failed, or ctx's error if the
+// request ended; either way nothing was changed.
+func (cat *catalog) applyBatch(ctx context.Context, steps []batchStep) ([]BatchResult, []int, error) {
+	if err := cat.lock(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer cat.booksMu.Unlock()
+
+	tx := &batchTx{cat: cat, refs: make(map[string]int), removed: make(map[int]Book)}
+	results := make([]BatchResult, 0, len(steps))
+	for i, step := range steps {
+		if err := ctx.Err(); err != nil {
+			tx.rollback()
+			return nil, nil, err
+		}
+
+		result := tx.apply(i, step)
+		results = append(results, result)
+		if result.Error != "" {
+			tx.rollback()
+			return results, nil, &batchError{index: i, err: errors.New(result.Error)}
+		}
+	}
+
+	// Committed: only now are the changes announced
+	for _, result := range results {
+		switch result.Op {
+		case "create":
+			cat.changed(EventBookCreated, *result.Data)
+		case "update":
+			cat.changed(EventBookUpdated, *result.Data)
+		case "delete":
+			cat.changed(EventBookDeleted, tx.removed[result.Index])
+		}
+	}
+	return results, tx.deleted, nil
+}
+
+// apply runs one step and reports its outcome
+func (tx *batchTx) apply(index int, step batchStep) BatchResult {
+	result := BatchResult{Index: index, Op: step.Op, Ref: step.Ref, ID: step.ID.ID}
+	if step.ID.Ref != "" {
+		result.ID = tx.refs[step.ID.Ref]
+	}
+
+	var book Book
+	var err error
+	switch step.Op {
+	case "create":
+		book, err = tx.create(step.create)
+		result.Status = http.StatusCreated
+		if step.Ref != "" && err == nil {
+			tx.refs[step.Ref] = book.ID
+		}
+		result.ID = book.ID
+	case "update":
+		book, err = tx.modify(result.ID, step.update)
+		result.Status = http.StatusOK
+	case "delete":
+		book, err = tx.remove(result.ID)
+		tx.removed[index] = book
+		result.Status = http.StatusOK
+	}
+
+	if err != nil {
+		result.Status, result.Error = storeErrorStatus(err)
+		return result
+	}
+	if step.Op != "delete" {
+		result.Data = &book
+	}
+	return result
+}
+
+// create inserts a book. A rollback deletes it again but does not give
+// back its ID: the failure report shows it, so it is never reused.
+func (tx *batchTx) create(input CreateBookInput) (Book, error) {
+	book, err := tx.cat.insertLocked(input.book(), nil)
+	if err != nil {
+		return Book{}, err
+	}
+
+	tx.undo = append(tx.undo, func() {
+		tx.cat.books.delete(book.ID)
+	})
+	return tx.cat.decorate(book), nil
+}
+
+func (tx *batchTx) modify(id int, input UpdateBookInput) (Book, error) {
+	old, _ := tx.cat.books.get(id)
+	book, err := tx.cat.modifyLocked(id, input.apply, nil)
+	if err != nil {
+		return Book{}, err
+	}
+
+	tx.undo = append(tx.undo, func() {
+		tx.cat.books.put(old)
+	})
+	return book, nil
+}
+
+// remove deletes a book, keeping its reviews, ratings, cover metadata,
+// lending state and shelf positions to put back on rollback
+func (tx *batchTx) remove(id int) (Book, error) {
+	cat := tx.cat
+	book, _ := cat.books.get(id)
+	var reviews []Review
+	for _, r := range cat.reviews {
+		if r.BookID == id {
+			reviews = append(reviews, r)
+		}
+	}
+	ratings, hasRatings := cat.ratings[id]
+	cover, hasCover := cat.covers[id]
+	lending := cat.lendingOf(id)
+	shelved := cat.shelvedAt(id)
+
+	removed, err := cat.removeLocked(id)
+	if err != nil {
+		return Book{}, err
+	}
+
+	tx.deleted = append(tx.deleted, id)
+	tx.undo = append(tx.undo, func() {
+		cat.books.put(book)
+		for _, r := range reviews {
+			cat.reviews[r.ID] = r
+		}
+		if hasRatings {
+			cat.ratings[id] = ratings
+		}
+		if hasCover {
+			cat.covers[id] = cover
+		}
+		cat.restoreLending(id, lending)
+		cat.reshelve(id, shelved)
+		tx.deleted = tx.deleted[:len(tx.deleted)-1]
+	})
+	return removed, nil
+}
+
+// rollback undoes the applied operations, newest first
+func (tx *batchTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+// ============================================================
+// HANDLER
+// ============================================================
+
+// BatchBooks - POST /api/v1/batch
+func BatchBooks(c *gin.Context) {
+	var input BatchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	steps, err := prepareBatch(input.Operations)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant := tenantFrom(c)
+	results, deleted, err := tenant.catalog.applyBatch(c.Request.Context(), steps)
+	var failed *batchError
+	if errors.As(err, &failed) {
+		c.JSON(results[failed.index].Status, gin.H{
+			"error":   "Batch rolled back: " + failed.Error(),
+			"failed":  failed.index,
+			"results": results,
+		})
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	for _, id := range deleted {
+		removeCoverFiles(tenant.ID, id)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  results,
+		"count": len(results),
+	})
+}
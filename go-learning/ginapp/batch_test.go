@@ -0,0 +1,181 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBatchBooks(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.insert(ctx, Book{Title: "Old Book", Author: "Someone", Year: 1990})
+
+	w := apiRequest(router, "POST", "/api/v1/batch", `{"operations": [
+		{"op": "create", "ref": "go", "body": {"title": "Go Draft", "author": "Ann", "year": 2024}},
+		{"op": "update", "id": "$go", "body": {"title": "Go in Practice"}},
+		{"op": "create", "body": {"title": "Rust Draft", "author": "Bob", "year": 2023}},
+		{"op": "delete", "id": 1}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data  []BatchResult `json:"data"`
+		Count int           `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	var statuses []string
+	for _, r := range response.Data {
+		statuses = append(statuses, fmt.Sprintf("%s %d %d", r.Op, r.ID, r.Status))
+	}
+	if got := strings.Join(statuses, ", "); got != "create 2 201, update 2 200, create 3 201, delete 1 200" {
+		t.Errorf("Unexpected results: %s", got)
+	}
+	if response.Data[1].Data == nil || response.Data[1].Data.Title != "Go in Practice" {
+		t.Errorf("Expected updated book in result, got %+v", response.Data[1].Data)
+	}
+
+	books, _ := cat.list(ctx)
+	if len(books) != 2 || books[0].Title != "Go in Practice" || books[1].Title != "Rust Draft" {
+		t.Errorf("Unexpected catalog after batch: %+v", books)
+	}
+}
+
+func TestBatchBooks_Rollback(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.insert(ctx, Book{Title: "The Go Programming Language", Author: "Donovan & Kernighan", Year: 2015})
+	cat.insert(ctx, Book{Title: "The C Programming Language", Author: "Kernighan & Ritchie", Year: 1978})
+	cat.addReview(ctx, Review{BookID: 2, User: "ann", Rating: 5})
+
+	before, _ := cat.list(ctx)
+	cat.refreshSimilar()
+	beforeIndex := fmt.Sprint(cat.similar)
+
+	// Everything up to the missing book is undone
+	w := apiRequest(router, "POST", "/api/v1/batch", `{"operations": [
+		{"op": "create", "ref": "new", "body": {"title": "Go in Action", "author": "Kennedy", "year": 2015}},
+		{"op": "update", "id": 1, "body": {"title": "Renamed"}},
+		{"op": "delete", "id": 2},
+		{"op": "update", "id": "$new", "body": {"year": 2016}},
+		{"op": "delete", "id": 99}
+	]}`)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+	var response struct {
+		Failed  int           `json:"failed"`
+		Results []BatchResult `json:"results"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Failed != 4 || len(response.Results) != 5 || response.Results[4].Error != "Book not found" {
+		t.Errorf("Unexpected failure report: %s", w.Body.String())
+	}
+
+	after, _ := cat.list(ctx)
+	if fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Errorf("Catalog changed by rolled back batch:\n%v\n%v", before, after)
+	}
+	cat.refreshSimilar()
+	if fmt.Sprint(cat.similar) != beforeIndex {
+		t.Errorf("Similar index changed by rolled back batch")
+	}
+	if reviews, _ := cat.listReviews(ctx, 2); len(reviews) != 1 {
+		t.Errorf("Expected the review to be restored, got %v", reviews)
+	}
+
+	// The failure report showed ID 3, so it is never handed out again
+	if response.Results[0].ID != 3 {
+		t.Errorf("Expected the report to show ID 3, got %d", response.Results[0].ID)
+	}
+	if book, _ := cat.insert(ctx, Book{Title: "Next", Author: "A", Year: 2000}); book.ID != 4 {
+		t.Errorf("Expected next ID 4, got %d", book.ID)
+	}
+}
+
+func TestBatchBooks_RollbackNeverVisible(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+
+	ops := make([]string, 0, MaxBatchOperations)
+	for i := 0; i < MaxBatchOperations-1; i++ {
+		ops = append(ops, `{"op": "create", "body": {"title": "Ghost", "author": "A", "year": 2000}}`)
+	}
+	ops = append(ops, `{"op": "delete", "id": 99999}`)
+	body := `{"operations": [` + strings.Join(ops, ",") + `]}`
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			apiRequest(router, "POST", "/api/v1/batch", body)
+		}
+		close(stop)
+	}()
+
+	for {
+		select {
+		case <-stop:
+			wg.Wait()
+			if n := cat.count(); n != 0 {
+				t.Errorf("Expected no books after rolled back batches, got %d", n)
+			}
+			return
+		default:
+		}
+		books, _ := cat.list(ctx)
+		if _, err := cat.find(ctx, 1); len(books) > 0 || err == nil {
+			t.Fatalf("A reader saw a book of a batch that was rolled back")
+		}
+	}
+}
+
+func TestBatchBooks_Validation(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	defer func(old int) { MaxBatchOperations = old }(MaxBatchOperations)
+	MaxBatchOperations = 2
+
+	create := `{"op": "create", "body": {"title": "T", "author": "A", "year": 2000}}`
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "no operations", body: `{"operations": []}`},
+		{name: "unknown op", body: `{"operations": [{"op": "upsert", "id": 1}]}`},
+		{name: "too many", body: `{"operations": [` + create + `,` + create + `,` + create + `]}`},
+		{name: "undefined ref", body: `{"operations": [{"op": "delete", "id": "$nope"}]}`},
+		{name: "ref used before created", body: `{"operations": [{"op": "delete", "id": "$a"}, {"op": "create", "ref": "a", "body": {"title": "T", "author": "A", "year": 2000}}]}`},
+		{name: "duplicate ref", body: `{"operations": [{"op": "create", "ref": "a", "body": {"title": "T", "author": "A", "year": 2000}}, {"op": "create", "ref": "a", "body": {"title": "T", "author": "A", "year": 2000}}]}`},
+		{name: "create with id", body: `{"operations": [{"op": "create", "id": 1, "body": {"title": "T", "author": "A", "year": 2000}}]}`},
+		{name: "update without id", body: `{"operations": [{"op": "update", "body": {"title": "T"}}]}`},
+		{name: "update without body", body: `{"operations": [{"op": "update", "id": 1}]}`},
+		{name: "invalid create body", body: `{"operations": [{"op": "create", "body": {"title": "T", "author": "A", "year": 99}}]}`},
+		{name: "malformed id", body: `{"operations": [{"op": "delete", "id": "one"}]}`},
+	}
+
+	for _, tt := range tests {
+		if w := apiRequest(router, "POST", "/api/v1/batch", tt.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+	if n := defaultTenant().catalog.count(); n != 0 {
+		t.Errorf("Expected no books after invalid batches, got %d", n)
+	}
+}
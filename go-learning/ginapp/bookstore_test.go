@@ -0,0 +1,450 @@
+package ginapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBookStore(t *testing.T) {
+	s := newBookStore(4)
+
+	first, _ := s.insert(Book{Title: "First"}, 0)
+	second, _ := s.insert(Book{Title: "Second"}, 0)
+	if first.ID != 1 || second.ID != 2 || s.len() != 2 || s.peekID() != 3 {
+		t.Fatalf("Expected IDs 1 and 2, got %d and %d (len %d, next %d)", first.ID, second.ID, s.len(), s.peekID())
+	}
+
+	book, exists := s.update(1, func(b *Book) { b.Year = 2020 })
+	if !exists || book.Year != 2020 {
+		t.Errorf("Expected updated book, got %+v", book)
+	}
+	if _, exists := s.update(99, func(b *Book) { b.Year = 2020 }); exists {
+		t.Error("Expected update of a missing book to fail")
+	}
+
+	if removed, exists := s.delete(2); !exists || removed.Title != "Second" || s.len() != 1 {
+		t.Errorf("Expected Second removed, got %+v (len %d)", removed, s.len())
+	}
+	if _, exists := s.get(2); exists {
+		t.Error("Expected deleted book to be gone")
+	}
+
+	// put restores a book under its own ID without taking a new one
+	s.put(second)
+	if _, exists := s.get(2); !exists || s.len() != 2 || s.peekID() != 3 {
+		t.Errorf("Expected Second back with next ID 3, got len %d, next %d", s.len(), s.peekID())
+	}
+
+	s.load([]Book{{ID: 7, Title: "Restored"}}, 10)
+	books, _ := s.snapshot(context.Background())
+	if len(books) != 1 || books[0].ID != 7 || s.len() != 1 || s.peekID() != 10 {
+		t.Errorf("Expected only the loaded book, got %+v (len %d, next %d)", books, s.len(), s.peekID())
+	}
+}
+
+func TestBookStore_ConcurrentInserts(t *testing.T) {
+	s := newBookStore(8)
+
+	const workers, perWorker = 8, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				s.insert(Book{Title: "Concurrent"}, 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	books, _ := s.snapshot(context.Background())
+	if len(books) != workers*perWorker || s.len() != len(books) {
+		t.Fatalf("Expected %d books, got %d (len %d)", workers*perWorker, len(books), s.len())
+	}
+	for i, b := range books {
+		if b.ID != i+1 {
+			t.Fatalf("Expected IDs 1-%d without gaps or duplicates, got %d at %d", len(books), b.ID, i)
+		}
+	}
+}
+
+func TestBookStore_Quota(t *testing.T) {
+	s := newBookStore(8)
+
+	var stored, rejected atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				_, err := s.insert(Book{Title: "Quota"}, 100)
+				switch {
+				case err == nil:
+					stored.Add(1)
+				case errors.Is(err, errQuotaExceeded):
+					rejected.Add(1)
+				default:
+					t.Errorf("Unexpected error: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stored.Load() != 100 || rejected.Load() != 300 || s.len() != 100 {
+		t.Errorf("Expected 100 stored and 300 rejected, got %d and %d (len %d)", stored.Load(), rejected.Load(), s.len())
+	}
+
+	// A delete frees a slot
+	s.delete(1)
+	if _, err := s.insert(Book{Title: "Quota"}, 100); err != nil {
+		t.Errorf("Expected insert after delete to succeed, got %v", err)
+	}
+}
+
+func TestBookStore_SnapshotConsistent(t *testing.T) {
+	s := newBookStore(16)
+	ctx := context.Background()
+
+	// A single writer inserts books one after another, so every
+	// consistent cut holds IDs 1-n. Copying shard by shard would catch
+	// a later ID in one shard and miss an earlier one in another.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5000; i++ {
+			s.insert(Book{Title: "Snapshot"}, 0)
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		books, err := s.snapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, b := range books {
+			if b.ID != i+1 {
+				t.Fatalf("Snapshot of %d books has ID %d at %d", len(books), b.ID, i)
+			}
+		}
+	}
+
+	// load swaps every shard at once: no snapshot mixes two generations
+	old := make([]Book, 100)
+	fresh := make([]Book, 100)
+	for i := range old {
+		old[i] = Book{ID: i + 1, Title: "old"}
+		fresh[i] = Book{ID: i + 1, Title: "new"}
+	}
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			s.load(old, 101)
+			s.load(fresh, 101)
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		books, _ := s.snapshot(ctx)
+		for _, b := range books {
+			if b.Title != books[0].Title {
+				t.Fatalf("Snapshot mixes %q and %q books", books[0].Title, b.Title)
+			}
+		}
+	}
+}
+
+func TestBookStore_SnapshotCancelled(t *testing.T) {
+	s := newBookStore(4)
+	s.insert(Book{Title: "Held"}, 0)
+
+	// A writer holding the store past the deadline makes the copy give up
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.lockAll()
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		s.unlockAll()
+	}()
+
+	if _, err := s.snapshot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestCatalog_LockModes(t *testing.T) {
+	ctx := context.Background()
+	cat := newCatalog(0)
+	book, _ := cat.insert(ctx, Book{Title: "Unblocked", Author: "A", Year: 2020})
+	cat.addReview(ctx, Review{BookID: book.ID, User: "ann", Rating: 4})
+
+	// Single-book writes share booksMu with reads: none of them waits
+	cat.booksMu.RLock()
+	found, err := cat.find(ctx, book.ID)
+	if err != nil || found.RatingCount != 1 || found.AverageRating != 4 {
+		t.Errorf("Expected decorated book, got %+v, %v", found, err)
+	}
+	if books, err := cat.list(ctx); err != nil || len(books) != 1 || books[0].RatingCount != 1 {
+		t.Errorf("Expected one decorated book, got %+v, %v", books, err)
+	}
+	if _, err := cat.insert(ctx, Book{Title: "Second", Author: "B", Year: 2021}); err != nil {
+		t.Errorf("Expected insert to pass a reader, got %v", err)
+	}
+	if _, err := cat.modify(ctx, book.ID, func(b *Book) { b.Year = 2019 }); err != nil {
+		t.Errorf("Expected modify to pass a reader, got %v", err)
+	}
+	cat.booksMu.RUnlock()
+
+	// Readers wait while a batch or restore holds it for writing
+	cat.booksMu.Lock()
+	done := make(chan error)
+	go func() {
+		_, err := cat.find(ctx, book.ID)
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected find to wait for the write lock")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cat.booksMu.Unlock()
+	if err := <-done; err != nil {
+		t.Errorf("Expected find to succeed once unlocked, got %v", err)
+	}
+	if n := cat.count(); n != 2 {
+		t.Errorf("Expected count 2, got %d", n)
+	}
+}
+
+// ============================================================
+// BENCHMARKS
+// ============================================================
+// Compare the sharded store with the single-lock design it replaced:
+//
+//	go test ./ginapp -run '^$' -bench BookStore -cpu 1,4,16
+//
+// The locked store serializes every write and keeps its lock through a
+// list's sort; in the sharded store writes only contend on a shard.
+
+// benchStore is what the benchmarks need from either store
+type benchStore interface {
+	get(id int) (Book, bool)
+	insert(book Book, limit int) (Book, error)
+	update(id int, fn func(*Book)) (Book, bool)
+	snapshot(ctx context.Context) ([]Book, error)
+}
+
+// lockedBookStore is the catalog's previous design: one map and an ID
+// counter behind one RWMutex, with lists copied under the read lock
+type lockedBookStore struct {
+	mu     sync.RWMutex
+	books  map[int]Book
+	nextID int
+}
+
+func newLockedBookStore() *lockedBookStore {
+	return &lockedBookStore{books: make(map[int]Book), nextID: 1}
+}
+
+func (s *lockedBookStore) get(id int) (Book, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	book, exists := s.books[id]
+	return book, exists
+}
+
+func (s *lockedBookStore) insert(book Book, limit int) (Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit > 0 && len(s.books) >= limit {
+		return Book{}, errQuotaExceeded
+	}
+	book.ID = s.nextID
+	s.books[book.ID] = book
+	s.nextID++
+	return book, nil
+}
+
+func (s *lockedBookStore) update(id int, fn func(*Book)) (Book, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	book, exists := s.books[id]
+	if !exists {
+		return Book{}, false
+	}
+	fn(&book)
+	s.books[id] = book
+	return book, true
+}
+
+func (s *lockedBookStore) snapshot(ctx context.Context) ([]Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	books := make([]Book, 0, len(s.books))
+	for _, b := range s.books {
+		books = append(books, b)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+	return books, nil
+}
+
+// benchWorkload is a mix of store operations in percent
+type benchWorkload struct {
+	name                string
+	get, update, insert int
+	snapshot            int // the rest
+	books               int // stored before timing starts
+}
+
+var benchWorkloads = []benchWorkload{
+	{name: "read", get: 100, books: 10000},
+	{name: "write", update: 50, insert: 50, books: 10000},
+	{name: "mixed", get: 75, update: 20, insert: 5, books: 10000},
+	{name: "list", update: 99, snapshot: 1, books: 1000},
+}
+
+func BenchmarkBookStore(b *testing.B) {
+	stores := []struct {
+		name string
+		new  func() benchStore
+	}{
+		{name: "locked", new: func() benchStore { return newLockedBookStore() }},
+		{name: "sharded", new: func() benchStore { return newBookStore(BookStoreShards) }},
+	}
+
+	for _, w := range benchWorkloads {
+		for _, store := range stores {
+			b.Run(fmt.Sprintf("%s/%s", w.name, store.name), func(b *testing.B) {
+				benchmarkWorkload(b, store.new(), w)
+			})
+		}
+	}
+}
+
+func benchmarkWorkload(b *testing.B, s benchStore, w benchWorkload) {
+	ctx := context.Background()
+	for i := 0; i < w.books; i++ {
+		s.insert(Book{Title: "Bench", Author: "A", Year: 2000 + i%25}, 0)
+	}
+
+	var seed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(seed.Add(1)))
+		for pb.Next() {
+			id := 1 + rnd.Intn(w.books)
+			switch n := rnd.Intn(100); {
+			case n < w.get:
+				s.get(id)
+			case n < w.get+w.update:
+				s.update(id, func(book *Book) { book.Year = 2000 + n%25 })
+			case n < w.get+w.update+w.insert:
+				s.insert(Book{Title: "Bench", Author: "A", Year: 2000 + n%25}, 0)
+			default:
+				s.snapshot(ctx)
+			}
+		}
+	})
+}
+
+// BenchmarkCatalog runs the same mixes through the catalog, whose reads
+// and single-book writes share booksMu and wait only on their shard.
+func BenchmarkCatalog(b *testing.B) {
+	defer func(n int) { BookStoreShards = n }(BookStoreShards)
+
+	for _, w := range benchWorkloads {
+		for _, shards := range []int{1, BookStoreShards} {
+			b.Run(fmt.Sprintf("%s/shards=%d", w.name, shards), func(b *testing.B) {
+				BookStoreShards = shards
+				benchmarkCatalog(b, newCatalog(0), w)
+			})
+		}
+	}
+}
+
+func benchmarkCatalog(b *testing.B, cat *catalog, w benchWorkload) {
+	ctx := context.Background()
+	for i := 0; i < w.books; i++ {
+		cat.insert(ctx, Book{Title: fmt.Sprintf("Bench %d", i), Author: "A", Year: 2000 + i%25})
+	}
+
+	var seed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(seed.Add(1)))
+		for pb.Next() {
+			id := 1 + rnd.Intn(w.books)
+			switch n := rnd.Intn(100); {
+			case n < w.get:
+				cat.find(ctx, id)
+			case n < w.get+w.update:
+				cat.modify(ctx, id, func(book *Book) { book.Year = 2000 + n%25 })
+			case n < w.get+w.update+w.insert:
+				cat.insert(ctx, Book{Title: "Bench", Author: "A", Year: 2000 + n%25})
+			default:
+				cat.list(ctx)
+			}
+		}
+	})
+}
+
+func BenchmarkRouter(b *testing.B) {
+	router := setupTestRouter()
+
+	for _, w := range []benchWorkload{
+		{name: "read", get: 100, books: 200},
+		{name: "mixed", get: 75, update: 25, books: 200},
+	} {
+		b.Run(w.name, func(b *testing.B) {
+			resetBooks()
+			cat := defaultTenant().catalog
+			for i := 0; i < w.books; i++ {
+				cat.insert(context.Background(), Book{Title: fmt.Sprintf("Bench %d", i), Author: "A", Year: 2000 + i%25})
+			}
+
+			var seed atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rnd := rand.New(rand.NewSource(seed.Add(1)))
+				for pb.Next() {
+					path := fmt.Sprintf("/api/v1/books/%d", 1+rnd.Intn(w.books))
+					req, _ := http.NewRequest("GET", path, nil)
+					if rnd.Intn(100) >= w.get {
+						req, _ = http.NewRequest("PUT", path, strings.NewReader(`{"year": 2001}`))
+						req.Header.Set("Content-Type", "application/json")
+					}
+					rec := httptest.NewRecorder()
+					router.ServeHTTP(rec, req)
+					if rec.Code != http.StatusOK {
+						b.Errorf("%s %s: status %d", req.Method, path, rec.Code)
+					}
+				}
+			})
+		})
+	}
+}
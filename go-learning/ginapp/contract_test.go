@@ -0,0 +1,31 @@
+package ginapp
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go-learning/golden"
+)
+
+// TestContract replays the golden files in testdata/golden against a
+// router seeded with the demo fixture set. After an intended change in
+// behavior, rerun with UPDATE_GOLDEN=1 and review the diff of the files.
+func TestContract(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.json"))
+	if len(files) == 0 {
+		t.Fatal("No golden files found")
+	}
+
+	for _, path := range files {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			router := setupTestRouter()
+			resetBooks()
+			resetAccounts(t)
+			if _, err := LoadFixture(context.Background(), "demo"); err != nil {
+				t.Fatal(err)
+			}
+			golden.Check(t, router, path, golden.DefaultNormalizer)
+		})
+	}
+}
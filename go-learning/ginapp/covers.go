@@ -0,0 +1,526 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// COVER IMAGES
+// ============================================================
+// PUT /api/v1/books/:id/cover accepts either multipart/form-data with a
+// "cover" file field or the raw image as the request body. The real
+// content type is sniffed from the bytes (never trusted from the client),
+// the original is stored on local disk and thumbnails are generated with
+// the standard image packages.
+//
+// Layout on disk:
+//   <CoverStorageDir>/<tenant>/<book id>/<version>/original.<ext>
+//   <CoverStorageDir>/<tenant>/<book id>/<version>/<size>.<ext>
+//
+// Every upload writes a new version directory. Swapping the metadata
+// under booksMu publishes it, and the previous version is deleted
+// afterwards, so concurrent uploads never write into the same place and
+// readers always find the files the metadata points to.
+// ============================================================
+
+// Cover storage settings
+var (
+	CoverStorageDir        = filepath.Join(os.TempDir(), "ginapp-covers")
+	MaxCoverSize     int64 = 5 << 20 // 5 MiB
+	MaxCoverPixels         = 40_000_000
+	CoverCacheMaxAge       = 24 * time.Hour
+)
+
+// coverSizes - thumbnail name -> longest edge in pixels
+var coverSizes = map[string]int{
+	"small":  64,
+	"medium": 256,
+	"large":  512,
+}
+
+// coverTypes - accepted image types -> file extension
+var coverTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// coverInfo - metadata of a stored cover
+type coverInfo struct {
+	Version     string // directory of the files, see coverFileDir
+	ContentType string
+	Ext         string
+	ThumbType   string // thumbnails of GIFs are PNGs
+	ThumbExt    string
+	ETag        string
+	Width       int
+	Height      int
+	Size        int64
+	UpdatedAt   time.Time
+}
+
+var (
+	errCoverTooLarge   = errors.New("cover image is too large")
+	errCoverType       = errors.New("cover must be a JPEG, PNG or GIF image")
+	errCoverDimensions = errors.New("cover image has too many pixels")
+)
+
+// ============================================================
+// CATALOG METHODS
+// ============================================================
+
+// setCover records cover metadata if the book still exists and returns
+// the cover it replaced, whose files the caller deletes
+func (cat *catalog) setCover(bookID int, info coverInfo) (coverInfo, bool, error) {
+	cat.booksMu.Lock()
+	defer cat.booksMu.Unlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return coverInfo{}, false, errBookNotFound
+	}
+	previous, replaced := cat.covers[bookID]
+	cat.covers[bookID] = info
+	cat.redecorate(bookID)
+	return previous, replaced, nil
+}
+
+// cover returns the cover metadata of a book
+func (cat *catalog) cover(bookID int) (coverInfo, bool) {
+	cat.booksMu.RLock()
+	defer cat.booksMu.RUnlock()
+
+	info, exists := cat.covers[bookID]
+	return info, exists
+}
+
+// clearCover forgets the cover of a book and returns it, if it had one
+func (cat *catalog) clearCover(bookID int) (coverInfo, bool) {
+	cat.booksMu.Lock()
+	defer cat.booksMu.Unlock()
+
+	info, exists := cat.covers[bookID]
+	if !exists {
+		return coverInfo{}, false
+	}
+	delete(cat.covers, bookID)
+	cat.redecorate(bookID)
+	return info, true
+}
+
+// ============================================================
+// STORAGE AND IMAGE PROCESSING
+// ============================================================
+
+// coverDir returns the directory holding a book's cover files
+func coverDir(tenantID string, bookID int) string {
+	return filepath.Join(CoverStorageDir, tenantID, strconv.Itoa(bookID))
+}
+
+// coverFileDir returns the directory of one version of a book's cover
+func coverFileDir(tenantID string, bookID int, version string) string {
+	return filepath.Join(coverDir(tenantID, bookID), version)
+}
+
+// removeCoverVersion deletes the files of one version of a book's cover
+func removeCoverVersion(tenantID string, bookID int, version string) {
+	if version != "" {
+		os.RemoveAll(coverFileDir(tenantID, bookID, version))
+	}
+}
+
+// removeCoverFiles deletes all cover files of a book
+func removeCoverFiles(tenantID string, bookID int) {
+	os.RemoveAll(coverDir(tenantID, bookID))
+}
+
+// removeTenantCovers deletes the cover files of every book of a tenant
+func removeTenantCovers(tenantID string) {
+	os.RemoveAll(filepath.Join(CoverStorageDir, tenantID))
+}
+
+// saveCover validates an uploaded image and writes the original plus
+// all thumbnails into a new version directory. Nothing reads it until
+// setCover publishes the returned metadata. Scaling stops with ctx's
+// error once nobody waits for it.
+func saveCover(ctx context.Context, tenantID string, bookID int, data []byte) (coverInfo, error) {
+	mime := mimetype.Detect(data)
+	contentType := strings.Split(mime.String(), ";")[0]
+	ext, allowed := coverTypes[contentType]
+	if !allowed {
+		return coverInfo{}, errCoverType
+	}
+
+	// Check dimensions before decoding to avoid decompression bombs
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return coverInfo{}, errCoverType
+	}
+	if config.Width*config.Height > MaxCoverPixels {
+		return coverInfo{}, errCoverDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return coverInfo{}, errCoverType
+	}
+
+	thumbType, thumbExt := contentType, ext
+	if contentType == "image/gif" {
+		thumbType, thumbExt = "image/png", ".png"
+	}
+
+	bookDir := coverDir(tenantID, bookID)
+	if err := os.MkdirAll(bookDir, 0o755); err != nil {
+		return coverInfo{}, err
+	}
+	dir, err := os.MkdirTemp(bookDir, "v")
+	if err != nil {
+		return coverInfo{}, err
+	}
+	if err := writeCoverFiles(ctx, dir, data, img, ext, thumbExt); err != nil {
+		os.RemoveAll(dir)
+		return coverInfo{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	return coverInfo{
+		Version:     filepath.Base(dir),
+		ContentType: contentType,
+		Ext:         ext,
+		ThumbType:   thumbType,
+		ThumbExt:    thumbExt,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Width:       config.Width,
+		Height:      config.Height,
+		Size:        int64(len(data)),
+		UpdatedAt:   time.Now().UTC().Truncate(time.Second),
+	}, nil
+}
+
+// writeCoverFiles writes the original and every thumbnail into dir.
+// Thumbnails are JPEGs for JPEG originals and PNGs otherwise.
+func writeCoverFiles(ctx context.Context, dir string, data []byte, img image.Image, ext, thumbExt string) error {
+	if err := os.WriteFile(filepath.Join(dir, "original"+ext), data, 0o644); err != nil {
+		return err
+	}
+	src := toRGBA(img)
+	for name, edge := range coverSizes {
+		thumb, err := scaleImage(ctx, src, edge)
+		if err != nil {
+			return err
+		}
+		if err := writeThumbnail(filepath.Join(dir, name+thumbExt), thumb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeThumbnail encodes img in the format its file extension names
+func writeThumbnail(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if filepath.Ext(path) == ".jpg" {
+		return jpeg.Encode(f, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(f, img)
+}
+
+// toRGBA converts a decoded image to RGBA once, so scaling can read
+// the pixels directly instead of calling At for each of them. draw has
+// fast paths for the types JPEG and PNG decode to; GIF frames are
+// mapped through their palette here.
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	if rgba, ok := img.(*image.RGBA); ok && bounds.Min == (image.Point{}) {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	paletted, ok := img.(*image.Paletted)
+	if !ok {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		return dst
+	}
+
+	palette := make([]color.RGBA, len(paletted.Palette))
+	for i, c := range paletted.Palette {
+		palette[i] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+	for y := 0; y < bounds.Dy(); y++ {
+		row := paletted.Pix[paletted.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+		out := dst.Pix[dst.PixOffset(0, y):]
+		for x := 0; x < bounds.Dx(); x++ {
+			var c color.RGBA // indices past the palette are transparent
+			if i := int(row[x]); i < len(palette) {
+				c = palette[i]
+			}
+			out[x*4], out[x*4+1], out[x*4+2], out[x*4+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return dst
+}
+
+// scaleImage downsizes src with a box filter: every destination pixel
+// is the average of the source pixels it covers. Averaging premultiplied
+// RGBA keeps transparent pixels from bleeding their color. Images that
+// already fit are returned as they are. It checks ctx once per row.
+func scaleImage(ctx context.Context, src *image.RGBA, edge int) (*image.RGBA, error) {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= edge && srcH <= edge {
+		return src, nil
+	}
+
+	dstW, dstH := edge, edge
+	if srcW >= srcH {
+		dstH = max(1, srcH*edge/srcW)
+	} else {
+		dstW = max(1, srcW*edge/srcH)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[src.PixOffset(x0, sy):src.PixOffset(x1, sy)]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					b += uint64(row[i+2])
+					a += uint64(row[i+3])
+				}
+			}
+			n := uint64((x1 - x0) * (y1 - y0))
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst, nil
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+// readCoverUpload extracts the image bytes from a multipart or raw body.
+// The route's BodyLimitMiddleware bounds the whole body, framing included.
+func readCoverUpload(c *gin.Context) ([]byte, error) {
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("cover")
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return nil, errCoverTooLarge
+			}
+			return nil, fmt.Errorf("multipart upload needs a \"cover\" file field: %w", err)
+		}
+		if fileHeader.Size > MaxCoverSize {
+			return nil, errCoverTooLarge
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, MaxCoverSize+1))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errCoverTooLarge
+		}
+		return nil, err
+	}
+	if int64(len(data)) > MaxCoverSize {
+		return nil, errCoverTooLarge
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty cover upload")
+	}
+	return data, nil
+}
+
+// UploadCover - PUT /api/v1/books/:id/cover
+func UploadCover(c *gin.Context) {
+	var uri struct {
+		ID int `uri:"id" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	tenant := tenantFrom(c)
+	if _, err := tenant.catalog.find(c.Request.Context(), uri.ID); err != nil {
+		storeError(c, err)
+		return
+	}
+
+	data, err := readCoverUpload(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errCoverTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := saveCover(c.Request.Context(), tenant.ID, uri.ID, data)
+	switch {
+	case errors.Is(err, errCoverType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errCoverDimensions):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		timeoutError(c, err)
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store cover"})
+		return
+	}
+
+	// The book may have been deleted while we were writing files
+	previous, replaced, err := tenant.catalog.setCover(uri.ID, info)
+	if err != nil {
+		removeCoverFiles(tenant.ID, uri.ID)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	if replaced {
+		removeCoverVersion(tenant.ID, uri.ID, previous.Version)
+	}
+
+	sizes := make(map[string]string, len(coverSizes))
+	for name := range coverSizes {
+		sizes[name] = fmt.Sprintf("/api/v1/books/%d/cover/%s", uri.ID, name)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"url":          fmt.Sprintf("/api/v1/books/%d/cover", uri.ID),
+		"thumbnails":   sizes,
+		"content_type": info.ContentType,
+		"width":        info.Width,
+		"height":       info.Height,
+		"size":         info.Size,
+	}})
+}
+
+// GetCover - GET /api/v1/books/:id/cover and /api/v1/books/:id/cover/:size
+func GetCover(c *gin.Context) {
+	var uri struct {
+		ID   int    `uri:"id" binding:"required,min=1"`
+		Size string `uri:"size"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	name := "original"
+	if uri.Size != "" {
+		if _, valid := coverSizes[uri.Size]; !valid {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown cover size, use small, medium or large"})
+			return
+		}
+		name = uri.Size
+	}
+
+	tenant := tenantFrom(c)
+	info, exists := tenant.catalog.cover(uri.ID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cover not found"})
+		return
+	}
+
+	contentType, ext := info.ContentType, info.Ext
+	if name != "original" {
+		contentType, ext = info.ThumbType, info.ThumbExt
+	}
+
+	f, err := os.Open(filepath.Join(coverFileDir(tenant.ID, uri.ID, info.Version), name+ext))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cover not found"})
+		return
+	}
+	defer f.Close()
+
+	// Each size has its own ETag derived from the original's hash
+	etag := info.ETag
+	if name != "original" {
+		etag = strings.TrimSuffix(etag, `"`) + "-" + name + `"`
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", etag)
+	// The same URL serves every tenant's cover, so shared caches must not
+	// keep it and browsers must tell the tenants apart
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(CoverCacheMaxAge.Seconds())))
+	c.Writer.Header().Add("Vary", "X-Tenant-ID")
+	c.Writer.Header().Add("Vary", "Authorization")
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, "", info.UpdatedAt, f)
+}
+
+// DeleteCover - DELETE /api/v1/books/:id/cover
+func DeleteCover(c *gin.Context) {
+	var uri struct {
+		ID int `uri:"id" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	tenant := tenantFrom(c)
+	info, exists := tenant.catalog.clearCover(uri.ID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cover not found"})
+		return
+	}
+	// Only this version: an upload in progress writes its own
+	removeCoverVersion(tenant.ID, uri.ID, info.Version)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cover deleted successfully"})
+}
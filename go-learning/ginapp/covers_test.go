@@ -0,0 +1,342 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testPNG encodes a solid-colour PNG of the given size
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 50, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// setupCoverTest points cover storage at a temporary directory and
+// creates one book
+func setupCoverTest(t *testing.T) http.Handler {
+	t.Helper()
+
+	router := setupTestRouter()
+	resetBooks()
+	CoverStorageDir = t.TempDir()
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+	return router
+}
+
+func TestUploadCover_Raw(t *testing.T) {
+	router := setupCoverTest(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/books/1/cover", bytes.NewReader(testPNG(t, 600, 300)))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// The book now advertises its cover
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1", nil)
+	router.ServeHTTP(w, req)
+
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"]["cover_url"] != "/api/v1/books/1/cover" {
+		t.Errorf("Unexpected cover_url: %v", response["data"]["cover_url"])
+	}
+}
+
+func TestUploadCover_Multipart(t *testing.T) {
+	router := setupCoverTest(t)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("cover", "cover.txt") // the name is not trusted
+	part.Write(testPNG(t, 100, 100))
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/books/1/cover", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"]["content_type"] != "image/png" {
+		t.Errorf("Expected sniffed type image/png, got %v", response["data"]["content_type"])
+	}
+}
+
+func TestUploadCover_Rejected(t *testing.T) {
+	router := setupCoverTest(t)
+	defer func(old int64) { MaxCoverSize = old }(MaxCoverSize)
+	MaxCoverSize = 1024
+
+	tests := []struct {
+		name           string
+		path           string
+		body           []byte
+		expectedStatus int
+	}{
+		{name: "not an image", path: "/api/v1/books/1/cover", body: []byte("hello, I am text"), expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "too large", path: "/api/v1/books/1/cover", body: bytes.Repeat([]byte{0xff}, 2048), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "empty", path: "/api/v1/books/1/cover", body: nil, expectedStatus: http.StatusBadRequest},
+		{name: "missing book", path: "/api/v1/books/9/cover", body: testPNG(t, 4, 4), expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", tt.path, bytes.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetCover_Thumbnails(t *testing.T) {
+	router := setupCoverTest(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/books/1/cover", bytes.NewReader(testPNG(t, 600, 300)))
+	router.ServeHTTP(w, req)
+
+	tests := []struct {
+		path           string
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{path: "/api/v1/books/1/cover", expectedWidth: 600, expectedHeight: 300},
+		{path: "/api/v1/books/1/cover/large", expectedWidth: 512, expectedHeight: 256},
+		{path: "/api/v1/books/1/cover/medium", expectedWidth: 256, expectedHeight: 128},
+		{path: "/api/v1/books/1/cover/small", expectedWidth: 64, expectedHeight: 32},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", tt.path, http.StatusOK, w.Code)
+		}
+		if w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%s: unexpected Content-Type %q", tt.path, w.Header().Get("Content-Type"))
+		}
+		if !strings.HasPrefix(w.Header().Get("Cache-Control"), "private") || w.Header().Get("ETag") == "" {
+			t.Errorf("%s: expected private caching headers, got %q", tt.path, w.Header().Get("Cache-Control"))
+		}
+		if vary := w.Header().Values("Vary"); !slices.Contains(vary, "X-Tenant-ID") {
+			t.Errorf("%s: expected Vary: X-Tenant-ID, got %v", tt.path, vary)
+		}
+
+		config, err := png.DecodeConfig(w.Body)
+		if err != nil {
+			t.Fatalf("%s: failed to decode image: %v", tt.path, err)
+		}
+		if config.Width != tt.expectedWidth || config.Height != tt.expectedHeight {
+			t.Errorf("%s: expected %dx%d, got %dx%d", tt.path, tt.expectedWidth, tt.expectedHeight, config.Width, config.Height)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1/cover/huge", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown size, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestUploadCover_Concurrent(t *testing.T) {
+	router := setupCoverTest(t)
+
+	// Uploads of different sizes race for the same book
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(size int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", "/api/v1/books/1/cover", bytes.NewReader(testPNG(t, size, size)))
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+		}(10 * i)
+	}
+	wg.Wait()
+
+	// The last upload to publish wins, its files are complete and the
+	// versions it replaced are gone
+	info, exists := defaultTenant().catalog.cover(1)
+	if !exists {
+		t.Fatal("Expected a cover")
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/books/1/cover", nil)
+	router.ServeHTTP(w, req)
+	config, err := png.DecodeConfig(w.Body)
+	if err != nil || config.Width != info.Width {
+		t.Errorf("Expected the published %dpx cover, got %+v, %v", info.Width, config, err)
+	}
+	entries, _ := os.ReadDir(coverDir(DefaultTenantID, 1))
+	if len(entries) != 1 || entries[0].Name() != info.Version {
+		t.Errorf("Expected only version %s on disk, got %d entries", info.Version, len(entries))
+	}
+}
+
+func TestGetCover_GIFThumbnails(t *testing.T) {
+	router := setupCoverTest(t)
+
+	img := image.NewPaletted(image.Rect(0, 0, 300, 100), color.Palette{color.Black, color.White})
+	for x := 150; x < 300; x++ {
+		for y := 0; y < 100; y++ {
+			img.SetColorIndex(x, y, 1)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/books/1/cover", &buf)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// The original stays a GIF, thumbnails are PNGs and say so
+	info, _ := defaultTenant().catalog.cover(1)
+	if info.ContentType != "image/gif" || info.ThumbType != "image/png" || info.ThumbExt != ".png" {
+		t.Errorf("Unexpected cover types: %+v", info)
+	}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1/cover/small", nil)
+	router.ServeHTTP(w, req)
+	if w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("Unexpected Content-Type %q", w.Header().Get("Content-Type"))
+	}
+	thumb, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a PNG thumbnail: %v", err)
+	}
+	if r, _, _, _ := thumb.At(0, 0).RGBA(); r != 0 {
+		t.Errorf("Expected the left half black, got red %d", r)
+	}
+	if r, _, _, _ := thumb.At(63, 0).RGBA(); r != 0xffff {
+		t.Errorf("Expected the right half white, got red %d", r)
+	}
+	if _, err := os.Stat(filepath.Join(coverFileDir(DefaultTenantID, 1, info.Version), "small.png")); err != nil {
+		t.Errorf("Expected small.png on disk: %v", err)
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	// Left half opaque white, right half transparent
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+
+	dst, err := scaleImage(context.Background(), src, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Fatalf("Expected 2x1, got %v", dst.Bounds())
+	}
+	if c := dst.RGBAAt(0, 0); c != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("Expected opaque white, got %v", c)
+	}
+	if c := dst.RGBAAt(1, 0); c != (color.RGBA{}) {
+		t.Errorf("Expected transparent, got %v", c)
+	}
+
+	// An upload nobody waits for any more stops scaling
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := scaleImage(ctx, src, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestGetCover_NotModified(t *testing.T) {
+	router := setupCoverTest(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/books/1/cover", bytes.NewReader(testPNG(t, 10, 10)))
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1/cover", nil)
+	router.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1/cover", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestDeleteBook_RemovesCover(t *testing.T) {
+	router := setupCoverTest(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/books/1/cover", bytes.NewReader(testPNG(t, 10, 10)))
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/v1/books/1", nil)
+	router.ServeHTTP(w, req)
+
+	if _, exists := defaultTenant().catalog.cover(1); exists {
+		t.Error("Expected cover metadata to be removed")
+	}
+	if _, err := os.Stat(coverDir(DefaultTenantID, 1)); !os.IsNotExist(err) {
+		t.Errorf("Expected cover directory to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ginapp
+
+// diskFree is not implemented here; the disk check passes
+func diskFree(dir string) (uint64, error) {
+	return 0, errDiskFreeUnsupported
+}
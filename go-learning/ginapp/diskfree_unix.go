@@ -0,0 +1,15 @@
+//go:build unix
+
+package ginapp
+
+import "syscall"
+
+// diskFree returns the bytes available to unprivileged users on the
+// file system holding dir
+func diskFree(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
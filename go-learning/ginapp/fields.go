@@ -0,0 +1,369 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/goccy/go-yaml"
+)
+
+// ============================================================
+// SPARSE FIELDSETS AND EMBEDDING
+// ============================================================
+// GetBooks, GetBook and SearchBooks shape their books with:
+//   ?fields=title,year              only these fields (id is always kept)
+//   ?include=reviews,authors,stats  embed related data in every book
+//   ?format=json|xml|yaml           or the Accept header, JSON by default
+//
+// Field names are Book's JSON names; unknown fields or includes are a
+// 400 listing the valid ones. Books are rendered as documents that keep
+// the model's field order and names, so XML and YAML use the same keys
+// as JSON.
+// ============================================================
+
+// Related data for ?include=
+const (
+	IncludeReviews = "reviews" // []Review, oldest first
+	IncludeAuthors = "authors" // []Author records
+	IncludeStats   = "stats"   // BookStats
+)
+
+var bookIncludes = []string{IncludeReviews, IncludeAuthors, IncludeStats}
+
+// bookFields are the names ?fields= accepts, in model order
+var bookFields = documentFields(reflect.TypeOf(Book{}))
+
+// BookStats - review and lending figures embedded by ?include=stats
+type BookStats struct {
+	ReviewCount  int   `json:"review_count"`
+	RatingCounts []int `json:"rating_counts"` // reviews with 1 to 5 stars
+	Copies       int   `json:"copies"`
+	OnLoan       int   `json:"on_loan"`
+	Available    int   `json:"available"`
+	Waiting      int   `json:"waiting"`
+}
+
+// projection is a parsed ?fields=, ?include= and ?format=
+type projection struct {
+	fields   map[string]bool // nil means every field
+	includes []string
+	format   string // json, xml or yaml
+}
+
+// parseProjection reads the shaping parameters of a books request
+func parseProjection(c *gin.Context) (projection, error) {
+	var p projection
+
+	if names := splitList(c.Query("fields")); len(names) > 0 {
+		p.fields = make(map[string]bool)
+		for _, name := range names {
+			if !containsString(bookFields, name) {
+				return p, fmt.Errorf("unknown field %q; valid fields: %s", name, strings.Join(bookFields, ", "))
+			}
+			p.fields[name] = true
+		}
+	}
+
+	if names := splitList(c.Query("include")); len(names) > 0 {
+		offered := bookIncludes
+		// stats rolls out behind a flag; while off it is not offered
+		if !FlagEnabled(c, "book-stats") {
+			offered = nil
+			for _, name := range bookIncludes {
+				if name != "stats" {
+					offered = append(offered, name)
+				}
+			}
+		}
+		for _, name := range names {
+			if !containsString(offered, name) {
+				return p, fmt.Errorf("unknown include %q; valid includes: %s", name, strings.Join(offered, ", "))
+			}
+			if !containsString(p.includes, name) {
+				p.includes = append(p.includes, name)
+			}
+		}
+	}
+
+	switch format := c.Query("format"); format {
+	case "json", "xml", "yaml":
+		p.format = format
+	case "":
+		switch c.NegotiateFormat(binding.MIMEJSON, binding.MIMEXML, binding.MIMEXML2, binding.MIMEYAML, binding.MIMEYAML2) {
+		case binding.MIMEXML, binding.MIMEXML2:
+			p.format = "xml"
+		case binding.MIMEYAML, binding.MIMEYAML2:
+			p.format = "yaml"
+		default:
+			p.format = "json"
+		}
+	default:
+		return p, fmt.Errorf("format must be one of: json, xml, yaml")
+	}
+	return p, nil
+}
+
+// splitList splits a comma-separated query value, dropping blanks
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// books projects books and embeds their includes
+func (p projection) books(ctx context.Context, cat *catalog, books []Book) ([]document, error) {
+	related, err := cat.related(ctx, books, p.includes)
+	if err != nil {
+		return nil, err
+	}
+
+	var keep func(name string) bool
+	if p.fields != nil {
+		keep = func(name string) bool { return name == "id" || p.fields[name] }
+	}
+
+	docs := make([]document, len(books))
+	for i, b := range books {
+		doc := structDocument(reflect.ValueOf(b), keep)
+		for _, name := range p.includes {
+			doc = append(doc, documentField{Name: name, Value: documentValue(reflect.ValueOf(related[b.ID][name]))})
+		}
+		docs[i] = doc
+	}
+	return docs, nil
+}
+
+// render writes obj in the projection's format
+func (p projection) render(c *gin.Context, status int, obj interface{}) {
+	switch p.format {
+	case "xml":
+		c.XML(status, obj)
+	case "yaml":
+		c.YAML(status, obj)
+	default:
+		c.JSON(status, obj)
+	}
+}
+
+// related collects the included data of books, by book ID and include
+func (cat *catalog) related(ctx context.Context, books []Book, includes []string) (map[int]map[string]interface{}, error) {
+	if len(includes) == 0 {
+		return nil, nil
+	}
+	if err := cat.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	reviews := make(map[int][]Review)
+	for _, r := range cat.reviews {
+		reviews[r.BookID] = append(reviews[r.BookID], r)
+	}
+
+	related := make(map[int]map[string]interface{}, len(books))
+	for _, b := range books {
+		bookReviews := reviews[b.ID]
+		sort.Slice(bookReviews, func(i, j int) bool { return bookReviews[i].ID < bookReviews[j].ID })
+
+		data := make(map[string]interface{})
+		for _, name := range includes {
+			switch name {
+			case IncludeReviews:
+				data[name] = append(make([]Review, 0, len(bookReviews)), bookReviews...)
+			case IncludeAuthors:
+				authors := b.Authors
+				if authors == nil {
+					authors = splitAuthors(b.Author)
+				}
+				data[name] = authors
+			case IncludeStats:
+				a := cat.availabilityLocked(b.ID)
+				stats := BookStats{
+					ReviewCount:  len(bookReviews),
+					RatingCounts: make([]int, 5),
+					Copies:       a.Copies,
+					OnLoan:       a.OnLoan,
+					Available:    a.Available,
+					Waiting:      a.Waiting,
+				}
+				for _, r := range bookReviews {
+					if r.Rating >= 1 && r.Rating <= 5 {
+						stats.RatingCounts[r.Rating-1]++
+					}
+				}
+				data[name] = stats
+			}
+		}
+		related[b.ID] = data
+	}
+	return related, nil
+}
+
+// ============================================================
+// DOCUMENTS
+// ============================================================
+
+// document is an object whose keys keep their order in JSON, XML and
+// YAML. Values are documents, []interface{} or plain values.
+type document []documentField
+
+type documentField struct {
+	Name  string
+	Value interface{}
+}
+
+// MarshalJSON writes the fields as a JSON object, in order
+func (d document) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range d {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Name)
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalXML writes each field as a child element. Lists repeat the
+// element once per item, as encoding/xml does for slices.
+func (d document) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, f := range d {
+		if err := e.EncodeElement(f.Value, xml.StartElement{Name: xml.Name{Local: f.Name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalYAML keeps the field order in YAML mappings
+func (d document) MarshalYAML() (interface{}, error) {
+	items := make(yaml.MapSlice, len(d))
+	for i, f := range d {
+		items[i] = yaml.MapItem{Key: f.Name, Value: f.Value}
+	}
+	return items, nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// documentValue converts structs to documents by their JSON names,
+// recursively. Values that marshal themselves, like time.Time, are kept.
+func documentValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return documentValue(v.Elem())
+	case reflect.Struct:
+		return structDocument(v, nil)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = documentValue(v.Index(i))
+		}
+		return list
+	default:
+		return v.Interface()
+	}
+}
+
+// structDocument converts a struct by its JSON tags. With keep, only
+// the fields it accepts are included, even if empty; without it every
+// field is, honouring omitempty.
+func structDocument(v reflect.Value, keep func(name string) bool) document {
+	t := v.Type()
+	doc := make(document, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, omitEmpty, ok := jsonField(t.Field(i))
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if keep != nil && !keep(name) || keep == nil && omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		doc = append(doc, documentField{Name: name, Value: documentValue(fv)})
+	}
+	return doc
+}
+
+// documentFields lists the JSON names of a struct type's fields
+func documentFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, ok := jsonField(t.Field(i)); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// jsonField returns the name encoding/json uses for a field, and
+// whether it is omitted when empty. ok is false for skipped fields.
+func jsonField(f reflect.StructField) (name string, omitEmpty, ok bool) {
+	if !f.IsExported() {
+		return "", false, false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, containsString(strings.Split(opts, ","), "omitempty"), true
+}
+
+// isEmptyValue matches encoding/json's notion of empty for omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,166 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+// getBooks sends a GET with an optional Accept header
+func getBooks(router http.Handler, path, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func setupFieldsTest() http.Handler {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.insert(ctx, Book{Title: "The Go Programming Language", Author: "Donovan & Kernighan", Year: 2015, ISBN: "978-0134190440"})
+	cat.insert(ctx, Book{Title: "Learning Go", Author: "Jon Bodner", Year: 2021})
+	cat.addReview(ctx, Review{BookID: 1, User: "ann", Rating: 5})
+	cat.addReview(ctx, Review{BookID: 1, User: "bob", Rating: 3})
+	return router
+}
+
+func TestSparseFieldsets(t *testing.T) {
+	router := setupFieldsTest()
+
+	w := getBooks(router, "/api/v1/books?fields=year,title", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	// id is always kept and fields stay in model order
+	if want := `"data":[{"id":1,"title":"The Go Programming Language","year":2015},{"id":2,"title":"Learning Go","year":2021}]`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Unexpected projection: %s", w.Body.String())
+	}
+
+	// Requested fields are included even when empty
+	w = getBooks(router, "/api/v1/books/2?fields=isbn", "")
+	if want := `{"data":{"id":2,"isbn":""}}`; w.Body.String() != want {
+		t.Errorf("Expected %s, got %s", want, w.Body.String())
+	}
+
+	w = getBooks(router, "/api/v1/books/search?author=Jon+Bodner&fields=title", "")
+	if !strings.Contains(w.Body.String(), `"data":[{"id":2,"title":"Learning Go"}]`) {
+		t.Errorf("Unexpected search projection: %s", w.Body.String())
+	}
+
+	// Without parameters the full book is returned as before
+	w = getBooks(router, "/api/v1/books/2", "")
+	var full map[string]map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &full)
+	if _, hasISBN := full["data"]["isbn"]; hasISBN || full["data"]["created_at"] == nil {
+		t.Errorf("Unexpected full book: %s", w.Body.String())
+	}
+}
+
+func TestInclude(t *testing.T) {
+	router := setupFieldsTest()
+
+	w := getBooks(router, "/api/v1/books/1?fields=title&include=reviews,authors,stats", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			Title   string    `json:"title"`
+			Reviews []Review  `json:"reviews"`
+			Authors []Author  `json:"authors"`
+			Stats   BookStats `json:"stats"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	data := response.Data
+	if len(data.Reviews) != 2 || data.Reviews[0].User != "ann" {
+		t.Errorf("Unexpected reviews: %+v", data.Reviews)
+	}
+	if len(data.Authors) != 2 || data.Authors[1].Name != "Kernighan" {
+		t.Errorf("Unexpected authors: %+v", data.Authors)
+	}
+	if s := data.Stats; s.ReviewCount != 2 || s.RatingCounts[2] != 1 || s.RatingCounts[4] != 1 || s.Copies != 1 || s.Available != 1 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+
+	// A book without reviews embeds an empty list
+	w = getBooks(router, "/api/v1/books?include=reviews", "")
+	if !strings.Contains(w.Body.String(), `"title":"Learning Go","author":"Jon Bodner","year":2021`) ||
+		!strings.Contains(w.Body.String(), `"rating_count":0,"reviews":[]}`) {
+		t.Errorf("Unexpected list with reviews: %s", w.Body.String())
+	}
+}
+
+func TestSparseFieldsets_Formats(t *testing.T) {
+	router := setupFieldsTest()
+	path := "/api/v1/books/1?fields=title,isbn&include=authors"
+
+	w := getBooks(router, path, "application/xml")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Fatalf("Expected XML, got %s: %s", ct, w.Body.String())
+	}
+	var x struct {
+		Data struct {
+			ID      int      `xml:"id"`
+			Title   string   `xml:"title"`
+			ISBN    string   `xml:"isbn"`
+			Authors []Author `xml:"authors"`
+			Year    int      `xml:"year"`
+		} `xml:"data"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &x); err != nil {
+		t.Fatalf("Failed to parse XML: %v", err)
+	}
+	if x.Data.ID != 1 || x.Data.ISBN != "978-0134190440" || len(x.Data.Authors) != 2 || x.Data.Year != 0 {
+		t.Errorf("Unexpected XML book: %+v", x.Data)
+	}
+
+	w = getBooks(router, path+"&format=yaml", "application/json")
+	var y struct {
+		Data map[string]interface{} `yaml:"data"`
+	}
+	if err := yaml.Unmarshal(w.Body.Bytes(), &y); err != nil {
+		t.Fatalf("Failed to parse YAML: %v\n%s", err, w.Body.String())
+	}
+	if len(y.Data) != 4 || y.Data["title"] != "The Go Programming Language" || y.Data["authors"] == nil {
+		t.Errorf("Unexpected YAML book: %v", y.Data)
+	}
+	// Keys keep the model's order as in JSON
+	if body := w.Body.String(); strings.Index(body, "id:") > strings.Index(body, "title:") {
+		t.Errorf("Expected model field order, got:\n%s", body)
+	}
+}
+
+func TestSparseFieldsets_Errors(t *testing.T) {
+	router := setupFieldsTest()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/api/v1/books?fields=title,price", want: `unknown field \"price\"; valid fields: id, title, author, year, isbn`},
+		{path: "/api/v1/books/1?include=publisher", want: `unknown include \"publisher\"; valid includes: reviews, authors, stats`},
+		{path: "/api/v1/books/search?fields=Title", want: `unknown field \"Title\"`},
+		{path: "/api/v1/books?format=csv", want: "format must be one of"},
+	}
+	for _, tt := range tests {
+		w := getBooks(router, tt.path, "")
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: expected 400 with %q, got %d: %s", tt.path, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package ginapp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ============================================================
+// FILTER EXPRESSION LANGUAGE
+// ============================================================
+// GET /api/v1/books/search?filter=... accepts expressions like
+//
+//   year >= 2015 AND (author ~ "Kernighan" OR title startswith "Go")
+//
+// Grammar (keywords are case-insensitive):
+//
+//   expr       := orExpr
+//   orExpr     := andExpr { "OR" andExpr }
+//   andExpr    := notExpr { "AND" notExpr }
+//   notExpr    := "NOT" notExpr | primary
+//   primary    := "(" expr ")" | comparison
+//   comparison := FIELD operator literal
+//   operator   := "=" | "!=" | "<" | "<=" | ">" | ">=" | "~"
+//               | "startswith" | "endswith"
+//   literal    := STRING | NUMBER
+//
+// "~" is a case-insensitive substring match. Expressions are lexed,
+// parsed and type-checked against the Book fields below before they
+// are evaluated, and every error carries the 1-based column it
+// refers to.
+// ============================================================
+
+// Filter limits
+const (
+	maxFilterLength = 1000
+	maxFilterDepth  = 32
+)
+
+// filterType - type of a filterable field or literal
+type filterType int
+
+const (
+	filterString filterType = iota
+	filterNumber
+	filterStringList
+)
+
+func (t filterType) String() string {
+	switch t {
+	case filterNumber:
+		return "number"
+	case filterStringList:
+		return "list of strings"
+	default:
+		return "string"
+	}
+}
+
+// filterField describes one Book field that filters may reference
+type filterField struct {
+	typ filterType
+	str func(Book) string
+	num func(Book) float64
+	lst func(Book) []string
+}
+
+// filterFields - the Book fields exposed to filter expressions
+var filterFields = map[string]filterField{
+	"id":           {typ: filterNumber, num: func(b Book) float64 { return float64(b.ID) }},
+	"title":        {typ: filterString, str: func(b Book) string { return b.Title }},
+	"author":       {typ: filterString, str: func(b Book) string { return b.Author }},
+	"year":         {typ: filterNumber, num: func(b Book) float64 { return float64(b.Year) }},
+	"isbn":         {typ: filterString, str: func(b Book) string { return b.ISBN }},
+	"publisher":    {typ: filterString, str: func(b Book) string { return b.Publisher }},
+	"language":     {typ: filterString, str: func(b Book) string { return b.Language }},
+	"rating":       {typ: filterNumber, num: func(b Book) float64 { return b.AverageRating }},
+	"rating_count": {typ: filterNumber, num: func(b Book) float64 { return float64(b.RatingCount) }},
+	"tags":         {typ: filterStringList, lst: func(b Book) []string { return b.Tags }},
+}
+
+// operators allowed per field type
+var filterOperators = map[filterType][]string{
+	filterString:     {"=", "!=", "~", "startswith", "endswith"},
+	filterNumber:     {"=", "!=", "<", "<=", ">", ">="},
+	filterStringList: {"=", "!=", "~"},
+}
+
+// FilterError is a lexing, parsing or type error at a column
+type FilterError struct {
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter error at position %d: %s", e.Position, e.Message)
+}
+
+// ============================================================
+// LEXER
+// ============================================================
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string // identifier, operator or decoded string literal
+	num  float64
+	pos  int // 1-based column
+}
+
+// describe renders a token for error messages
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// lexFilter splits the input into tokens
+func lexFilter(input string) ([]token, error) {
+	runes := []rune(input)
+	tokens := make([]token, 0)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: pos})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: pos})
+			i++
+
+		case r == '"' || r == '\'':
+			quote := r
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == quote {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, &FilterError{Position: pos, Message: "unterminated string"}
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: pos})
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, &FilterError{Position: pos, Message: fmt.Sprintf("invalid number %q", text)}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, num: num, pos: pos})
+
+		case strings.ContainsRune("=!<>~", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '~' && r != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &FilterError{Position: pos, Message: `unexpected "!", did you mean "!="?`}
+			}
+			tokens = append(tokens, token{kind: tokOperator, text: op, pos: pos})
+			i += len(op)
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			word := string(runes[start:i])
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, token{kind: tokAnd, text: word, pos: pos})
+			case "or":
+				tokens = append(tokens, token{kind: tokOr, text: word, pos: pos})
+			case "not":
+				tokens = append(tokens, token{kind: tokNot, text: word, pos: pos})
+			case "startswith", "endswith":
+				tokens = append(tokens, token{kind: tokOperator, text: strings.ToLower(word), pos: pos})
+			default:
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: pos})
+			}
+
+		default:
+			return nil, &FilterError{Position: pos, Message: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokEOF, pos: len(runes) + 1})
+	return tokens, nil
+}
+
+// ============================================================
+// PARSER
+// ============================================================
+
+// filterNode is a node of the parsed expression tree
+type filterNode interface {
+	eval(b Book) bool
+}
+
+type andNode struct{ left, right filterNode }
+type orNode struct{ left, right filterNode }
+type notNode struct{ inner filterNode }
+
+// compareNode - FIELD operator literal, already type-checked
+type compareNode struct {
+	field filterField
+	op    string
+	str   string
+	num   float64
+}
+
+func (n andNode) eval(b Book) bool { return n.left.eval(b) && n.right.eval(b) }
+func (n orNode) eval(b Book) bool  { return n.left.eval(b) || n.right.eval(b) }
+func (n notNode) eval(b Book) bool { return !n.inner.eval(b) }
+
+func (n compareNode) eval(b Book) bool {
+	switch n.field.typ {
+	case filterNumber:
+		v := n.field.num(b)
+		switch n.op {
+		case "=":
+			return v == n.num
+		case "!=":
+			return v != n.num
+		case "<":
+			return v < n.num
+		case "<=":
+			return v <= n.num
+		case ">":
+			return v > n.num
+		case ">=":
+			return v >= n.num
+		}
+	case filterString:
+		return matchString(n.field.str(b), n.op, n.str)
+	case filterStringList:
+		matched := false
+		for _, item := range n.field.lst(b) {
+			if matchString(item, strings.TrimPrefix(n.op, "!"), n.str) {
+				matched = true
+				break
+			}
+		}
+		if n.op == "!=" {
+			return !matched
+		}
+		return matched
+	}
+	return false
+}
+
+// matchString applies a string operator
+func matchString(value, op, literal string) bool {
+	switch op {
+	case "=":
+		return value == literal
+	case "!=":
+		return value != literal
+	case "~":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(literal))
+	case "startswith":
+		return strings.HasPrefix(value, literal)
+	case "endswith":
+		return strings.HasSuffix(value, literal)
+	}
+	return false
+}
+
+// filterParser is a recursive-descent parser over lexed tokens
+type filterParser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *filterParser) peek() token { return p.tokens[p.pos] }
+
+func (p *filterParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if p.peek().kind == tokNot {
+		t := p.next()
+		if err := p.enter(t); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokLParen:
+		p.next()
+		if err := p.enter(t); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &FilterError{Position: closing.pos, Message: fmt.Sprintf("expected \")\" to close \"(\" at position %d, got %s", t.pos, closing.describe())}
+		}
+		return node, nil
+	case tokIdent:
+		return p.parseComparison()
+	default:
+		return nil, &FilterError{Position: t.pos, Message: fmt.Sprintf("expected field name or \"(\", got %s", t.describe())}
+	}
+}
+
+// parseComparison parses and type-checks FIELD operator literal
+func (p *filterParser) parseComparison() (filterNode, error) {
+	fieldTok := p.next()
+	name := strings.ToLower(fieldTok.text)
+	field, exists := filterFields[name]
+	if !exists {
+		return nil, &FilterError{Position: fieldTok.pos, Message: fmt.Sprintf("unknown field %q (available: %s)", fieldTok.text, filterFieldNames())}
+	}
+
+	opTok := p.next()
+	if opTok.kind != tokOperator {
+		return nil, &FilterError{Position: opTok.pos, Message: fmt.Sprintf("expected operator after %q, got %s", fieldTok.text, opTok.describe())}
+	}
+	if !containsString(filterOperators[field.typ], opTok.text) {
+		return nil, &FilterError{Position: opTok.pos, Message: fmt.Sprintf("operator %q cannot be used with %s field %q (allowed: %s)",
+			opTok.text, field.typ, name, strings.Join(filterOperators[field.typ], " "))}
+	}
+
+	valueTok := p.next()
+	node := compareNode{field: field, op: opTok.text}
+	switch {
+	case field.typ == filterNumber && valueTok.kind == tokNumber:
+		node.num = valueTok.num
+	case field.typ != filterNumber && valueTok.kind == tokString:
+		node.str = valueTok.text
+	case valueTok.kind == tokNumber || valueTok.kind == tokString:
+		return nil, &FilterError{Position: valueTok.pos, Message: fmt.Sprintf("field %q is a %s, cannot compare it with %s", name, field.typ, valueTok.describe())}
+	default:
+		return nil, &FilterError{Position: valueTok.pos, Message: fmt.Sprintf("expected a value after %q, got %s", opTok.text, valueTok.describe())}
+	}
+	return node, nil
+}
+
+// enter / leave guard the nesting depth
+func (p *filterParser) enter(t token) error {
+	p.depth++
+	if p.depth > maxFilterDepth {
+		return &FilterError{Position: t.pos, Message: fmt.Sprintf("expression nested deeper than %d levels", maxFilterDepth)}
+	}
+	return nil
+}
+
+func (p *filterParser) leave() { p.depth-- }
+
+// filterFieldNames lists the filterable fields for error messages
+func filterFieldNames() string {
+	names := make([]string, 0, len(filterFields))
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ParseFilter compiles a filter expression into a predicate over books
+func ParseFilter(input string) (func(Book) bool, error) {
+	if len([]rune(input)) > maxFilterLength {
+		return nil, &FilterError{Position: maxFilterLength + 1, Message: fmt.Sprintf("filter is longer than %d characters", maxFilterLength)}
+	}
+
+	tokens, err := lexFilter(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &FilterError{Position: t.pos, Message: fmt.Sprintf("unexpected %s, expected AND, OR or end of input", t.describe())}
+	}
+	return node.eval, nil
+}
@@ -0,0 +1,160 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseFilter_Evaluation(t *testing.T) {
+	book := Book{
+		ID:            3,
+		Title:         "The Go Programming Language",
+		Author:        "Donovan & Kernighan",
+		Year:          2015,
+		Tags:          []string{"go", "classic"},
+		AverageRating: 4.5,
+	}
+
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{filter: `year >= 2015 AND (author ~ "Kernighan" OR title startswith "Go")`, expected: true},
+		{filter: `year > 2015`, expected: false},
+		{filter: `author ~ "kernighan"`, expected: true},
+		{filter: `title startswith "Go"`, expected: false},
+		{filter: `title endswith 'Language'`, expected: true},
+		{filter: `NOT year = 2015`, expected: false},
+		{filter: `not (year < 2000 or rating < 4)`, expected: true},
+		{filter: `tags = "classic"`, expected: true},
+		{filter: `tags != "go"`, expected: false},
+		{filter: `tags ~ "CLASS"`, expected: true},
+		{filter: `id = 3 AND isbn = ""`, expected: true},
+		{filter: `title = "The \"Go\" Book" OR year != 2015`, expected: false},
+		{filter: `rating >= 4.5`, expected: true},
+		// AND binds tighter than OR
+		{filter: `year = 1999 AND year = 1999 OR year = 2015`, expected: true},
+		{filter: `year = 1999 AND (year = 1999 OR year = 2015)`, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			predicate, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := predicate(book); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseFilter_Errors(t *testing.T) {
+	tests := []struct {
+		filter   string
+		position int
+	}{
+		{filter: `year >=`, position: 8},
+		{filter: `year >= "2015"`, position: 9},
+		{filter: `pages > 100`, position: 1},
+		{filter: `title > "A"`, position: 7},
+		{filter: `(year = 2015`, position: 13},
+		{filter: `year = 2015)`, position: 12},
+		{filter: `title ~ "unterminated`, position: 9},
+		{filter: `year = 2015 AND`, position: 16},
+		{filter: `year ! 2015`, position: 6},
+		{filter: `year = 2015 # comment`, position: 13},
+		{filter: `year 2015`, position: 6},
+		{filter: ``, position: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := ParseFilter(tt.filter)
+			var filterErr *FilterError
+			if !errors.As(err, &filterErr) {
+				t.Fatalf("Expected FilterError, got %v", err)
+			}
+			if filterErr.Position != tt.position {
+				t.Errorf("Expected position %d, got %d (%s)", tt.position, filterErr.Position, filterErr.Message)
+			}
+		})
+	}
+}
+
+func TestParseFilter_DepthLimit(t *testing.T) {
+	filter := ""
+	for i := 0; i < maxFilterDepth+1; i++ {
+		filter += "("
+	}
+	filter += "year = 1"
+
+	if _, err := ParseFilter(filter); err == nil {
+		t.Error("Expected deeply nested filter to be rejected")
+	}
+}
+
+func TestSearchBooks_Filter(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "The Go Programming Language", Author: "Donovan & Kernighan", Year: 2015})
+	cat.insert(context.Background(), Book{Title: "The C Programming Language", Author: "Kernighan & Ritchie", Year: 1978})
+	cat.insert(context.Background(), Book{Title: "Go in Action", Author: "Kennedy", Year: 2015})
+	cat.insert(context.Background(), Book{Title: "Learning Go", Author: "Jon Bodner", Year: 2021})
+
+	tests := []struct {
+		filter        string
+		extra         string
+		expectedCount int
+	}{
+		{filter: `year >= 2015 AND (author ~ "Kernighan" OR title startswith "Go")`, expectedCount: 2},
+		{filter: `author ~ "kernighan"`, expectedCount: 2},
+		{filter: `author ~ "kernighan"`, extra: "&year=1978", expectedCount: 1},
+		{filter: `title ~ "go"`, extra: "&limit=2", expectedCount: 2},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/books/search?filter="+url.QueryEscape(tt.filter)+tt.extra, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d: %s", tt.filter, http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if int(response["count"].(float64)) != tt.expectedCount {
+			t.Errorf("%s%s: expected count %d, got %v", tt.filter, tt.extra, tt.expectedCount, response["count"])
+		}
+	}
+}
+
+func TestSearchBooks_FilterSyntaxError(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/books/search?filter="+url.QueryEscape(`year >= AND`), nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["position"] != float64(9) {
+		t.Errorf("Expected position 9, got %v", response["position"])
+	}
+}
@@ -0,0 +1,361 @@
+package ginapp
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"go-learning/fixtures"
+)
+
+// ============================================================
+// FIXTURES
+// ============================================================
+// Seed data for demos and tests, loaded from YAML or JSON:
+//
+//   users:
+//     - {username: ann, password: correct-horse-42}
+//   books:
+//     - {ref: gopl, title: The Go Programming Language, author: Donovan & Kernighan, year: 2015, copies: 2}
+//   reviews:
+//     - {book: gopl, user: ann, rating: 5, comment: The classic}
+//   shelves:
+//     - {owner: ann, name: Favourites, visibility: public, books: [gopl]}
+//   tenants:
+//     - {id: acme, name: Acme Corp, max_books: 100, books: [...], reviews: [...], shelves: [...]}
+//
+// Top-level books, reviews and shelves go to the default tenant. Books
+// get a ref for reviews and shelves to point at. Everything is checked
+// with the API's own rules before anything is stored, and every problem
+// is reported with its path.
+//
+// LoadFixture takes a file path or the name of a built-in set from
+// fixtures/ ("demo", "library"); the cmd binaries expose it as -seed.
+// ============================================================
+
+//go:embed fixtures/*.yaml
+var fixtureSets embed.FS
+
+// Fixture - users plus catalog data for the default tenant and others
+type Fixture struct {
+	Users          []FixtureUser `json:"users,omitempty"` // checked like /auth/register
+	FixtureCatalog `json:",inline"`
+	Tenants        []FixtureTenant `json:"tenants,omitempty"`
+}
+
+// FixtureUser - an account, optionally with the admin role. It belongs
+// to the default tenant unless Tenant names one of the fixture's tenants.
+type FixtureUser struct {
+	CredentialsInput `json:",inline"`
+	Tenant           string `json:"tenant,omitempty"`
+	Admin            bool   `json:"admin,omitempty"`
+}
+
+// FixtureCatalog - the books of one tenant and what refers to them
+type FixtureCatalog struct {
+	Books   []FixtureBook   `json:"books,omitempty"`
+	Reviews []FixtureReview `json:"reviews,omitempty"`
+	Shelves []FixtureShelf  `json:"shelves,omitempty"`
+}
+
+// FixtureTenant - a tenant created if it does not exist yet
+type FixtureTenant struct {
+	CreateTenantInput `json:",inline"`
+	FixtureCatalog    `json:",inline"`
+}
+
+// FixtureBook - a book as POST /books takes it, plus a ref and copies
+type FixtureBook struct {
+	Ref             string `json:"ref,omitempty"`
+	CreateBookInput `json:",inline"`
+	Copies          *int `json:"copies,omitempty"`
+}
+
+// FixtureReview - a review of the book with ref Book
+type FixtureReview struct {
+	Book              string `json:"book"`
+	User              string `json:"user"`
+	CreateReviewInput `json:",inline"`
+}
+
+// FixtureShelf - a shelf holding the books with the given refs, in order
+type FixtureShelf struct {
+	Owner            string `json:"owner"`
+	CreateShelfInput `json:",inline"`
+	Books            []string `json:"books,omitempty"`
+}
+
+// FixtureSummary - what a fixture stored
+type FixtureSummary struct {
+	Users   int
+	Tenants int
+	Books   int
+	Reviews int
+	Shelves int
+}
+
+func (s FixtureSummary) String() string {
+	return fmt.Sprintf("%d users, %d tenants, %d books, %d reviews, %d shelves", s.Users, s.Tenants, s.Books, s.Reviews, s.Shelves)
+}
+
+// FixtureSets lists the built-in fixture sets
+func FixtureSets() []string {
+	return fixtures.Sets(builtinFixtures())
+}
+
+// builtinFixtures is the fixtures/ directory of the embedded sets
+func builtinFixtures() fs.FS {
+	sets, _ := fs.Sub(fixtureSets, "fixtures")
+	return sets
+}
+
+// LoadFixture reads a fixture file or built-in set and applies it
+func LoadFixture(ctx context.Context, source string) (FixtureSummary, error) {
+	var f Fixture
+	if err := fixtures.Load(builtinFixtures(), source, &f); err != nil {
+		return FixtureSummary{}, err
+	}
+	return ApplyFixture(ctx, &f)
+}
+
+// ============================================================
+// VALIDATION
+// ============================================================
+
+// validate checks the fixture against the API's rules
+func (f *Fixture) validate() error {
+	var problems fixtures.Problems
+
+	usernames := make(map[string]bool)
+	for i, u := range f.Users {
+		path := fmt.Sprintf("users[%d]", i)
+		validateInput(&problems, path, &u.CredentialsInput)
+		if u.Username != "" && !usernamePattern.MatchString(u.Username) {
+			problems.Add(path+".username", "must be 3-32 letters, digits, dots, dashes or underscores")
+		}
+		if strings.ToLower(u.Username) == DemoUser {
+			problems.Add(path+".username", "is reserved")
+		}
+		for _, v := range passwordViolations(u.Username, u.Password) {
+			problems.Add(path+".password", "%s", v)
+		}
+		if key := strings.ToLower(u.Username); usernames[key] {
+			problems.Add(path+".username", "%q is listed twice", u.Username)
+		} else {
+			usernames[key] = true
+		}
+	}
+
+	f.FixtureCatalog.validate(&problems, "")
+	tenantIDs := make(map[string]bool)
+	for i := range f.Tenants {
+		t := &f.Tenants[i]
+		path := fmt.Sprintf("tenants[%d]", i)
+		validateInput(&problems, path, &t.CreateTenantInput)
+		if t.ID != "" && !tenantIDPattern.MatchString(t.ID) {
+			problems.Add(path+".id", "must be 1-32 lowercase letters, digits or hyphens")
+		}
+		if t.ID == DefaultTenantID {
+			problems.Add(path+".id", "the default tenant's data goes at the top level")
+		}
+		if tenantIDs[t.ID] {
+			problems.Add(path+".id", "%q is listed twice", t.ID)
+		}
+		tenantIDs[t.ID] = true
+		if t.MaxBooks > 0 && len(t.Books) > t.MaxBooks {
+			problems.Add(path+".books", "%d books exceed max_books %d", len(t.Books), t.MaxBooks)
+		}
+		t.FixtureCatalog.validate(&problems, path+".")
+	}
+	for i, u := range f.Users {
+		if u.Tenant != "" && u.Tenant != DefaultTenantID && !tenantIDs[u.Tenant] {
+			problems.Add(fmt.Sprintf("users[%d].tenant", i), "%q is not one of the fixture's tenants", u.Tenant)
+		}
+	}
+	return problems.Err()
+}
+
+// validate checks one tenant's books, reviews and shelves
+func (fc *FixtureCatalog) validate(problems *fixtures.Problems, prefix string) {
+	refs := make(map[string]bool)
+	for i, b := range fc.Books {
+		path := fmt.Sprintf("%sbooks[%d]", prefix, i)
+		validateInput(problems, path, &b.CreateBookInput)
+		if b.Copies != nil && (*b.Copies < 0 || *b.Copies > 1000) {
+			problems.Add(path+".copies", "must be between 0 and 1000")
+		}
+		if b.Ref != "" && refs[b.Ref] {
+			problems.Add(path+".ref", "%q is already defined", b.Ref)
+		}
+		refs[b.Ref] = true
+	}
+	delete(refs, "")
+
+	reviewed := make(map[[2]string]bool)
+	for i, r := range fc.Reviews {
+		path := fmt.Sprintf("%sreviews[%d]", prefix, i)
+		validateInput(problems, path, &r.CreateReviewInput)
+		if !refs[r.Book] {
+			problems.Add(path+".book", "no book with ref %q", r.Book)
+		}
+		if r.User == "" {
+			problems.Add(path+".user", "is required")
+		}
+		if key := [2]string{r.Book, r.User}; reviewed[key] {
+			problems.Add(path, "%s already reviewed %q", r.User, r.Book)
+		} else {
+			reviewed[key] = true
+		}
+	}
+
+	shelfNames := make(map[[2]string]bool)
+	for i, s := range fc.Shelves {
+		path := fmt.Sprintf("%sshelves[%d]", prefix, i)
+		validateInput(problems, path, &s.CreateShelfInput)
+		if s.Owner == "" {
+			problems.Add(path+".owner", "is required")
+		}
+		if key := [2]string{s.Owner, s.Name}; shelfNames[key] {
+			problems.Add(path+".name", "%s already has a shelf named %q", s.Owner, s.Name)
+		} else {
+			shelfNames[key] = true
+		}
+		if len(s.Books) > MaxBooksPerShelf {
+			problems.Add(path+".books", "at most %d books", MaxBooksPerShelf)
+		}
+		onShelf := make(map[string]bool)
+		for j, ref := range s.Books {
+			if !refs[ref] {
+				problems.Add(fmt.Sprintf("%s.books[%d]", path, j), "no book with ref %q", ref)
+			}
+			if onShelf[ref] {
+				problems.Add(fmt.Sprintf("%s.books[%d]", path, j), "%q is already on the shelf", ref)
+			}
+			onShelf[ref] = true
+		}
+	}
+}
+
+// validateInput applies the binding rules of an API input type and
+// reports each failure under its JSON name
+func validateInput(problems *fixtures.Problems, path string, input interface{}) {
+	err := binding.Validator.ValidateStruct(input)
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		if err != nil {
+			problems.Add(path, "%v", err)
+		}
+		return
+	}
+
+	t := reflect.TypeOf(input).Elem()
+	for _, fe := range fieldErrors {
+		name := fe.Field()
+		if sf, ok := t.FieldByName(fe.StructField()); ok {
+			name, _, _ = jsonField(sf)
+		}
+		rule := fe.Tag()
+		if fe.Param() != "" {
+			rule += "=" + fe.Param()
+		}
+		problems.Add(path+"."+name, "fails %q", rule)
+	}
+}
+
+// ============================================================
+// APPLYING
+// ============================================================
+
+// ApplyFixture validates a fixture and stores its contents. Nothing is
+// stored if it is invalid; an error while storing (e.g. a username that
+// is already taken) leaves what was stored before it.
+func ApplyFixture(ctx context.Context, f *Fixture) (FixtureSummary, error) {
+	var summary FixtureSummary
+	if err := f.validate(); err != nil {
+		return summary, err
+	}
+
+	for i, u := range f.Users {
+		tenant := u.Tenant
+		if tenant == "" {
+			tenant = DefaultTenantID
+		}
+		if _, err := register(u.Username, u.Password, tenant); err != nil {
+			return summary, fmt.Errorf("users[%d]: %w", i, err)
+		}
+		if u.Admin {
+			SetAdmin(u.Username, true)
+		}
+		summary.Users++
+	}
+
+	if err := f.FixtureCatalog.apply(ctx, defaultTenant().catalog, "", &summary); err != nil {
+		return summary, err
+	}
+
+	for i, t := range f.Tenants {
+		tenantsMu.Lock()
+		tenant, exists := tenants[t.ID]
+		if !exists {
+			tenant = newTenant(t.ID, t.Name, t.MaxBooks)
+			tenants[t.ID] = tenant
+			summary.Tenants++
+		}
+		tenantsMu.Unlock()
+
+		if err := t.FixtureCatalog.apply(ctx, tenant.catalog, fmt.Sprintf("tenants[%d].", i), &summary); err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// apply stores one tenant's books, reviews and shelves
+func (fc *FixtureCatalog) apply(ctx context.Context, cat *catalog, prefix string, summary *FixtureSummary) error {
+	ids := make(map[string]int)
+	for i, b := range fc.Books {
+		book, err := cat.insert(ctx, b.book())
+		if err != nil {
+			return fmt.Errorf("%sbooks[%d]: %w", prefix, i, err)
+		}
+		if b.Copies != nil {
+			if _, err := cat.setCopies(ctx, book.ID, *b.Copies, time.Now()); err != nil {
+				return fmt.Errorf("%sbooks[%d].copies: %w", prefix, i, err)
+			}
+		}
+		if b.Ref != "" {
+			ids[b.Ref] = book.ID
+		}
+		summary.Books++
+	}
+
+	for i, r := range fc.Reviews {
+		_, err := cat.addReview(ctx, Review{BookID: ids[r.Book], User: r.User, Rating: r.Rating, Comment: r.Comment})
+		if err != nil {
+			return fmt.Errorf("%sreviews[%d]: %w", prefix, i, err)
+		}
+		summary.Reviews++
+	}
+
+	for i, s := range fc.Shelves {
+		shelf, err := cat.createShelf(ctx, Shelf{Owner: s.Owner, Name: s.Name, Description: s.Description, Visibility: s.Visibility})
+		if err != nil {
+			return fmt.Errorf("%sshelves[%d]: %w", prefix, i, err)
+		}
+		for j, ref := range s.Books {
+			if _, err := cat.addToShelf(ctx, shelf.ID, s.Owner, ids[ref], 0); err != nil {
+				return fmt.Errorf("%sshelves[%d].books[%d]: %w", prefix, i, j, err)
+			}
+		}
+		summary.Shelves++
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package ginapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-learning/fixtures"
+)
+
+// writeFixture writes a fixture file to a temp dir and returns its path
+func writeFixture(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFixture_BuiltinSets(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, set := range FixtureSets() {
+		resetBooks()
+		resetAccounts(t)
+		if _, err := LoadFixture(context.Background(), set); err != nil {
+			t.Errorf("Set %q: %v", set, err)
+		}
+	}
+
+	resetBooks()
+	resetAccounts(t)
+	summary, err := LoadFixture(context.Background(), "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary.String(); got != "2 users, 0 tenants, 3 books, 2 reviews, 2 shelves" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+
+	// Users log in with the fixture passwords, and refs resolve to IDs
+	ann, _, _, err := login("ann", "correct-horse-42")
+	if err != nil {
+		t.Fatalf("Expected fixture user to log in: %v", err)
+	}
+	w := apiRequest(router, "GET", "/api/v1/shelves", "", "Authorization", "Bearer "+ann)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"book_ids":[1,3]`) {
+		t.Errorf("Expected ann's shelf with books 1 and 3, got %d: %s", w.Code, w.Body.String())
+	}
+	if got, _ := defaultTenant().catalog.availability(context.Background(), 1); got.Copies != 2 {
+		t.Errorf("Expected 2 copies of book 1, got %d", got.Copies)
+	}
+
+	if _, err := LoadFixture(context.Background(), "nope"); !errors.Is(err, fixtures.ErrUnknownSet) {
+		t.Errorf("Expected ErrUnknownSet, got %v", err)
+	}
+}
+
+func TestLoadFixture_Invalid(t *testing.T) {
+	resetBooks()
+	resetAccounts(t)
+
+	path := writeFixture(t, "bad.yaml", `
+users:
+  - {username: ann, password: password}
+  - {username: bob, password: battery-staple-7, tenant: nowhere}
+books:
+  - {ref: a, title: Fine, author: Someone, year: 2001}
+  - {ref: a, title: "", author: Someone, year: 99}
+reviews:
+  - {book: missing, user: ann, rating: 6}
+shelves:
+  - {owner: ann, name: Mine, books: [a, a]}
+tenants:
+  - {id: Bad_ID, name: Bad}
+`)
+	_, err := LoadFixture(context.Background(), path)
+	var invalid *fixtures.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	for _, want := range []string{
+		"users[0].password: ",
+		"users[1].tenant: ",
+		"books[1].ref: ",
+		"books[1].title: ",
+		"books[1].year: ",
+		"reviews[0].book: ",
+		"reviews[0].rating: ",
+		"shelves[0].books[1]: ",
+		"tenants[0].id: ",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected a problem at %q in:\n%v", want, err)
+		}
+	}
+
+	// Nothing was stored
+	if n := defaultTenant().catalog.count(); n != 0 {
+		t.Errorf("Expected no books stored, got %d", n)
+	}
+	if _, _, _, err := login("ann", "password"); err == nil {
+		t.Error("Expected no user stored")
+	}
+}
+
+func TestLoadFixture_JSONFile(t *testing.T) {
+	resetBooks()
+	resetAccounts(t)
+
+	// Unknown fields are errors rather than silently dropped
+	path := writeFixture(t, "typo.json", `{"books": [{"title": "Go", "author": "Me", "yaer": 2020}]}`)
+	if _, err := LoadFixture(context.Background(), path); err == nil || !strings.Contains(err.Error(), "yaer") {
+		t.Errorf("Expected unknown field error, got %v", err)
+	}
+
+	path = writeFixture(t, "acme.json", `{
+		"tenants": [{"id": "acme", "name": "Acme", "max_books": 5,
+			"books": [{"title": "Go", "author": "A & B", "year": 2020}]}]
+	}`)
+	summary, err := LoadFixture(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Tenants != 1 || summary.Books != 1 {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+	tenantsMu.RLock()
+	acme := tenants["acme"]
+	tenantsMu.RUnlock()
+	if acme == nil || acme.MaxBooks != 5 || acme.catalog.count() != 1 {
+		t.Fatalf("Expected tenant acme with one book, got %+v", acme)
+	}
+	if book, _ := acme.catalog.books.get(1); len(book.Authors) != 2 {
+		t.Errorf("Expected authors split like the API does, got %+v", book)
+	}
+}
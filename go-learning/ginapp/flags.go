@@ -0,0 +1,327 @@
+package ginapp
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-learning/fixtures"
+)
+
+// ============================================================
+// FEATURE FLAGS
+// ============================================================
+// Endpoints can ship dark and be rolled out gradually. Flags are
+// defined in DefaultFeatureFlags or a YAML/JSON file (-flags on the
+// server) and changed at runtime under /api/v1/admin/flags.
+//
+// A flag that is not enabled is off for everyone. An enabled flag with
+// no rules is on for everyone; with rules it is on for the listed
+// users, the listed tenants and a percentage of the other signed-in
+// users. Percentage buckets hash the flag and user names, so a user
+// keeps their answer as the percentage grows, and different flags
+// reach different users.
+//
+//   - RequireFlag hides routes: while a flag is off they answer 404
+//     exactly like a route that does not exist, before any auth
+//   - FlagEnabled lets handlers branch on a flag for the request
+// ============================================================
+
+// Flag - a feature flag and its rollout rules
+type Flag struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	Users       []string  `json:"users,omitempty"`
+	Tenants     []string  `json:"tenants,omitempty"`
+	Percentage  *int      `json:"percentage,omitempty"` // of signed-in users, 0-100
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+}
+
+// UpdateFlagInput - input for changing a flag (all fields optional).
+// ClearRules removes the users, tenants and percentage first, so the
+// flag is on for everyone again unless the same input sets new rules.
+type UpdateFlagInput struct {
+	Description *string   `json:"description,omitempty" binding:"omitempty,max=200"`
+	Enabled     *bool     `json:"enabled,omitempty"`
+	Users       *[]string `json:"users,omitempty" binding:"omitempty,dive,required"`
+	Tenants     *[]string `json:"tenants,omitempty" binding:"omitempty,dive,required"`
+	Percentage  *int      `json:"percentage,omitempty" binding:"omitempty,gte=0,lte=100"`
+	ClearRules  bool      `json:"clear_rules,omitempty"`
+}
+
+// FlagDecision - whether a flag is on for a user and tenant, and why
+type FlagDecision struct {
+	Flag    string `json:"flag"`
+	User    string `json:"user,omitempty"`
+	Tenant  string `json:"tenant"`
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"` // disabled, everyone, user, tenant, percentage, not targeted or unknown flag
+}
+
+// DefaultFeatureFlags are the flags the server starts with
+var DefaultFeatureFlags = []Flag{
+	{Name: "shelves", Description: "Personal shelves under /api/v1/shelves", Enabled: true},
+	{Name: "graphql", Description: "GraphQL endpoint at /api/v1/graphql", Enabled: true},
+	{Name: "book-stats", Description: "?include=stats on book reads", Enabled: true},
+}
+
+// flagNamePattern - lowercase words joined by hyphens
+var flagNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+var errFlagNotFound = errors.New("flag not found")
+
+// Feature flag store, by name
+var (
+	flags   = flagMap(DefaultFeatureFlags)
+	flagsMu sync.RWMutex
+)
+
+// flagMap copies flag definitions into a store
+func flagMap(defs []Flag) map[string]*Flag {
+	m := make(map[string]*Flag, len(defs))
+	for _, f := range defs {
+		m[f.Name] = &f
+	}
+	return m
+}
+
+// LoadFeatureFlags replaces all flags with defs after checking them
+func LoadFeatureFlags(defs []Flag) error {
+	var problems fixtures.Problems
+	seen := make(map[string]bool)
+	for i, f := range defs {
+		path := fmt.Sprintf("flags[%d]", i)
+		if !flagNamePattern.MatchString(f.Name) {
+			problems.Add(path+".name", "must be lowercase letters and digits joined by hyphens")
+		}
+		if seen[f.Name] {
+			problems.Add(path+".name", "%q is defined twice", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Percentage != nil && (*f.Percentage < 0 || *f.Percentage > 100) {
+			problems.Add(path+".percentage", "must be between 0 and 100")
+		}
+	}
+	if err := problems.Err(); err != nil {
+		return err
+	}
+
+	flagsMu.Lock()
+	flags = flagMap(defs)
+	flagsMu.Unlock()
+	return nil
+}
+
+// LoadFeatureFlagsFile loads flags from a YAML or JSON file with a
+// top-level "flags" list
+func LoadFeatureFlagsFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		Flags []Flag `json:"flags"`
+	}
+	if err := fixtures.Decode(path, data, &config); err != nil {
+		return err
+	}
+	return LoadFeatureFlags(config.Flags)
+}
+
+// flagView returns a copy of a flag that callers may keep
+func flagView(f *Flag) Flag {
+	view := *f
+	view.Users = append([]string(nil), f.Users...)
+	view.Tenants = append([]string(nil), f.Tenants...)
+	if f.Percentage != nil {
+		p := *f.Percentage
+		view.Percentage = &p
+	}
+	return view
+}
+
+// ============================================================
+// EVALUATION
+// ============================================================
+
+// evaluateFlag decides a flag for a user (empty if anonymous) and tenant
+func evaluateFlag(name, user, tenant string) FlagDecision {
+	decision := FlagDecision{Flag: name, User: user, Tenant: tenant}
+
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+
+	f, exists := flags[name]
+	switch {
+	case !exists:
+		decision.Reason = "unknown flag"
+	case !f.Enabled:
+		decision.Reason = "disabled"
+	case len(f.Users) == 0 && len(f.Tenants) == 0 && f.Percentage == nil:
+		decision.Enabled, decision.Reason = true, "everyone"
+	case user != "" && containsString(f.Users, user):
+		decision.Enabled, decision.Reason = true, "user"
+	case containsString(f.Tenants, tenant):
+		decision.Enabled, decision.Reason = true, "tenant"
+	case user != "" && f.Percentage != nil && rolloutBucket(name, user) < *f.Percentage:
+		decision.Enabled, decision.Reason = true, "percentage"
+	default:
+		decision.Reason = "not targeted"
+	}
+	return decision
+}
+
+// rolloutBucket places a user in 0-99 for a flag
+func rolloutBucket(flag, user string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag + ":" + user))
+	return int(h.Sum32() % 100)
+}
+
+// flagsKey is where FlagEnabled caches decisions for a request
+const flagsKey = "feature_flags"
+
+// FlagEnabled reports whether a flag is on for this request's user and
+// tenant. The user is taken from the context, or from the Authorization
+// header on routes without AuthMiddleware; decisions are cached for the
+// rest of the request so a flag cannot flip halfway through it.
+func FlagEnabled(c *gin.Context, name string) bool {
+	var cache map[string]bool
+	if value, exists := c.Get(flagsKey); exists {
+		cache = value.(map[string]bool)
+	} else {
+		cache = make(map[string]bool)
+		c.Set(flagsKey, cache)
+	}
+	if enabled, cached := cache[name]; cached {
+		return enabled
+	}
+
+	user := c.GetString("user")
+	if user == "" {
+		user, _ = authenticate(c)
+	}
+	enabled := evaluateFlag(name, user, tenantFrom(c).ID).Enabled
+	cache[name] = enabled
+	return enabled
+}
+
+// RequireFlag - hides the routes after it while a flag is off. The 404
+// has the router's own status, content type and body, so a dark route
+// reads like a missing one.
+func RequireFlag(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !FlagEnabled(c, name) {
+			c.Data(http.StatusNotFound, "text/plain", []byte("404 page not found"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ============================================================
+// ADMIN HANDLERS
+// ============================================================
+
+// ListFlags - GET /api/v1/admin/flags
+func ListFlags(c *gin.Context) {
+	flagsMu.RLock()
+	list := make([]Flag, 0, len(flags))
+	for _, f := range flags {
+		list = append(list, flagView(f))
+	}
+	flagsMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	c.JSON(http.StatusOK, gin.H{"data": list, "count": len(list)})
+}
+
+// GetFlag - GET /api/v1/admin/flags/:flag
+func GetFlag(c *gin.Context) {
+	flagsMu.RLock()
+	f, exists := flags[c.Param("flag")]
+	var view Flag
+	if exists {
+		view = flagView(f)
+	}
+	flagsMu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flag not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// UpdateFlag - PUT /api/v1/admin/flags/:flag - takes effect on the next request
+func UpdateFlag(c *gin.Context) {
+	var input UpdateFlagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	view, err := updateFlag(c.Param("flag"), input, c.GetString("user"))
+	if errors.Is(err, errFlagNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flag not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// updateFlag applies the provided fields of input to a flag
+func updateFlag(name string, input UpdateFlagInput, by string) (Flag, error) {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+
+	f, exists := flags[name]
+	if !exists {
+		return Flag{}, errFlagNotFound
+	}
+	if input.Description != nil {
+		f.Description = *input.Description
+	}
+	if input.Enabled != nil {
+		f.Enabled = *input.Enabled
+	}
+	if input.ClearRules {
+		f.Users, f.Tenants, f.Percentage = nil, nil, nil
+	}
+	if input.Users != nil {
+		f.Users = append([]string(nil), (*input.Users)...)
+	}
+	if input.Tenants != nil {
+		f.Tenants = append([]string(nil), (*input.Tenants)...)
+	}
+	if input.Percentage != nil {
+		p := *input.Percentage
+		f.Percentage = &p
+	}
+	f.UpdatedAt = time.Now()
+	f.UpdatedBy = by
+	return flagView(f), nil
+}
+
+// CheckFlag - GET /api/v1/admin/flags/:flag/check?user=ann&tenant=acme
+// shows what a user would get, to verify a rollout
+func CheckFlag(c *gin.Context) {
+	name := c.Param("flag")
+	tenant := c.DefaultQuery("tenant", DefaultTenantID)
+	decision := evaluateFlag(name, c.Query("user"), tenant)
+	if decision.Reason == "unknown flag" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flag not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": decision})
+}
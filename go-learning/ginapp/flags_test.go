@@ -0,0 +1,157 @@
+package ginapp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// resetFlags restores the default flags before and after a test
+func resetFlags(t *testing.T) {
+	t.Helper()
+
+	if err := LoadFeatureFlags(DefaultFeatureFlags); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { LoadFeatureFlags(DefaultFeatureFlags) })
+}
+
+func TestEvaluateFlag(t *testing.T) {
+	resetFlags(t)
+	half := 50
+	LoadFeatureFlags([]Flag{
+		{Name: "off", Users: []string{"ann"}},
+		{Name: "on", Enabled: true},
+		{Name: "targeted", Enabled: true, Users: []string{"ann"}, Tenants: []string{"acme"}, Percentage: &half},
+	})
+
+	for _, tc := range []struct {
+		flag, user, tenant string
+		want               string
+	}{
+		{"off", "ann", "default", "disabled"},
+		{"on", "", "default", "everyone"},
+		{"targeted", "ann", "default", "user"},
+		{"targeted", "", "acme", "tenant"},
+		{"targeted", "", "default", "not targeted"},
+		{"missing", "ann", "default", "unknown flag"},
+	} {
+		if got := evaluateFlag(tc.flag, tc.user, tc.tenant).Reason; got != tc.want {
+			t.Errorf("%s for %q in %s: got %q, want %q", tc.flag, tc.user, tc.tenant, got, tc.want)
+		}
+	}
+
+	// About half of the users are in a 50% rollout, and each keeps the
+	// same answer
+	in := 0
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user%d", i)
+		first := evaluateFlag("targeted", user, "default").Enabled
+		if first != evaluateFlag("targeted", user, "default").Enabled {
+			t.Fatalf("Decision for %s changed", user)
+		}
+		if first {
+			in++
+		}
+	}
+	if in < 400 || in > 600 {
+		t.Errorf("Expected about 500 of 1000 users in a 50%% rollout, got %d", in)
+	}
+}
+
+func TestRequireFlag(t *testing.T) {
+	router, ann, bob := setupShelvesTest(t)
+	resetFlags(t)
+	apiRequest(router, "POST", "/api/v1/shelves", `{"name": "Dark"}`, "Authorization", "Bearer "+ann)
+
+	w := apiRequest(router, "PUT", "/api/v1/admin/flags/shelves", `{"users": ["ann"]}`, "Authorization", "Bearer "+adminToken(t))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"updated_by":"`+testAdmin+`"`) {
+		t.Fatalf("Update failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	// Hidden routes look exactly like missing ones, even without auth
+	missing := apiRequest(router, "GET", "/api/v1/no-such-route", "")
+	for _, headers := range [][]string{nil, {"Authorization", "Bearer " + bob}} {
+		w := apiRequest(router, "GET", "/api/v1/shelves", "", headers...)
+		if w.Code != http.StatusNotFound || w.Body.String() != missing.Body.String() ||
+			w.Header().Get("Content-Type") != missing.Header().Get("Content-Type") {
+			t.Errorf("Expected hidden route to match %d %q, got %d %q", missing.Code, missing.Body.String(), w.Code, w.Body.String())
+		}
+	}
+	if w := apiRequest(router, "GET", "/api/v1/shelves", "", "Authorization", "Bearer "+ann); w.Code != http.StatusOK {
+		t.Errorf("Expected ann in the rollout, got %d", w.Code)
+	}
+
+	w = apiRequest(router, "GET", "/api/v1/admin/flags/shelves/check?user=bob", "", "Authorization", "Bearer "+adminToken(t))
+	if !strings.Contains(w.Body.String(), `"reason":"not targeted"`) {
+		t.Errorf("Unexpected check: %s", w.Body.String())
+	}
+
+	// Clearing the rules opens the flag to everyone again
+	apiRequest(router, "PUT", "/api/v1/admin/flags/shelves", `{"percentage": 0}`, "Authorization", "Bearer "+adminToken(t))
+	w = apiRequest(router, "PUT", "/api/v1/admin/flags/shelves", `{"clear_rules": true}`, "Authorization", "Bearer "+adminToken(t))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"users"`) || strings.Contains(w.Body.String(), `"percentage"`) {
+		t.Fatalf("Expected rules cleared, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := apiRequest(router, "GET", "/api/v1/shelves", "", "Authorization", "Bearer "+bob); w.Code != http.StatusOK {
+		t.Errorf("Expected bob let in once rules are cleared, got %d", w.Code)
+	}
+
+	// Turned off at runtime, the route disappears for everyone
+	apiRequest(router, "PUT", "/api/v1/admin/flags/shelves", `{"enabled": false}`, "Authorization", "Bearer "+adminToken(t))
+	if w := apiRequest(router, "GET", "/api/v1/shelves", "", "Authorization", "Bearer "+ann); w.Code != http.StatusNotFound {
+		t.Errorf("Expected disabled flag to hide the route, got %d", w.Code)
+	}
+
+	if w := apiRequest(router, "PUT", "/api/v1/admin/flags/nope", `{"enabled": true}`, "Authorization", "Bearer "+adminToken(t)); w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown flag to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := apiRequest(router, "PUT", "/api/v1/admin/flags/shelves", `{"percentage": 101}`, "Authorization", "Bearer "+adminToken(t)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid percentage to be %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := apiRequest(router, "GET", "/api/v1/admin/flags", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected flag admin to require auth, got %d", w.Code)
+	}
+}
+
+func TestFlagEnabled_InHandler(t *testing.T) {
+	router, _, _ := setupShelvesTest(t)
+	resetFlags(t)
+
+	if w := apiRequest(router, "GET", "/api/v1/books/1?include=stats", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected stats while the flag is on, got %d: %s", w.Code, w.Body.String())
+	}
+
+	apiRequest(router, "PUT", "/api/v1/admin/flags/book-stats", `{"enabled": false}`, "Authorization", "Bearer "+adminToken(t))
+	w := apiRequest(router, "GET", "/api/v1/books/1?include=stats", "")
+	if w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "stats;") || strings.Contains(w.Body.String(), ", stats") {
+		t.Errorf("Expected stats to be unknown and unlisted, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := apiRequest(router, "GET", "/api/v1/books/1?include=reviews", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected other includes to work, got %d", w.Code)
+	}
+}
+
+func TestLoadFeatureFlagsFile(t *testing.T) {
+	resetFlags(t)
+
+	bad := writeFixture(t, "flags.yaml", "flags:\n  - {name: Bad_Name, enabled: true}\n  - {name: ok, percentage: 150}\n  - {name: ok}\n")
+	err := LoadFeatureFlagsFile(bad)
+	for _, want := range []string{"flags[0].name", "flags[1].percentage", "flags[2].name"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected a problem at %s, got %v", want, err)
+		}
+	}
+	if !evaluateFlag("shelves", "", DefaultTenantID).Enabled {
+		t.Error("Expected an invalid file to leave the flags unchanged")
+	}
+
+	good := writeFixture(t, "flags.json", `{"flags": [{"name": "new-search", "enabled": true, "tenants": ["acme"]}]}`)
+	if err := LoadFeatureFlagsFile(good); err != nil {
+		t.Fatal(err)
+	}
+	if !evaluateFlag("new-search", "", "acme").Enabled || evaluateFlag("shelves", "", DefaultTenantID).Reason != "unknown flag" {
+		t.Error("Expected the file to replace the flags")
+	}
+}
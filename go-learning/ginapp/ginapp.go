@@ -1,12 +1,17 @@
 package ginapp
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"go-learning/algorithms"
 )
 
 // ============================================================
@@ -27,6 +32,10 @@ import (
 // ============================================================
 
 // Book represents a book in our API
+//
+// The hidden fields belong to the richer v2 representation (see v2.go).
+// They are stored alongside the v1 fields so both API versions share one
+// store, but they are never serialized in v1 responses.
 type Book struct {
 	ID        int       `json:"id"`
 	Title     string    `json:"title" binding:"required,min=1,max=200"`
@@ -34,6 +43,17 @@ type Book struct {
 	Year      int       `json:"year" binding:"required,gte=1000,lte=2100"`
 	ISBN      string    `json:"isbn,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// Computed from reviews and covers, kept current by the catalog
+	// (see decorate) and never accepted from clients
+	AverageRating float64 `json:"average_rating"`
+	RatingCount   int     `json:"rating_count"`
+	CoverURL      string  `json:"cover_url,omitempty"`
+
+	Authors   []Author `json:"-"`
+	Publisher string   `json:"-"`
+	Language  string   `json:"-"`
+	Tags      []string `json:"-"`
 }
 
 // CreateBookInput - input for creating a book (without ID and CreatedAt)
@@ -52,21 +72,343 @@ type UpdateBookInput struct {
 	ISBN   *string `json:"isbn,omitempty"`
 }
 
-// In-memory store
-var (
-	books   = make(map[int]Book)
-	booksMu sync.RWMutex
-	bookID  = 1
-)
+// book builds the book to store
+func (input CreateBookInput) book() Book {
+	return Book{
+		Title:   input.Title,
+		Author:  input.Author,
+		Authors: splitAuthors(input.Author),
+		Year:    input.Year,
+		ISBN:    input.ISBN,
+	}
+}
+
+// apply updates only the provided fields
+func (input UpdateBookInput) apply(book *Book) {
+	if input.Title != nil {
+		book.Title = *input.Title
+	}
+	if input.Author != nil {
+		book.Author = *input.Author
+		book.Authors = splitAuthors(*input.Author)
+	}
+	if input.Year != nil {
+		book.Year = *input.Year
+	}
+	if input.ISBN != nil {
+		book.ISBN = *input.ISBN
+	}
+}
+
+// ============================================================
+// STORE
+// ============================================================
+// Each tenant owns a catalog: its own books, ID counter and lock (see
+// tenants.go). Every API version reads and writes a catalog through these
+// methods, so the locking rules live in one place.
+//
+// Books live in a sharded store (see bookstore.go). Reading a book and
+// inserting or changing one hold booksMu only for reading, so they run
+// in parallel and wait only on the book's shard. booksMu is taken for
+// writing by whatever touches the state kept next to the books (reviews,
+// covers, lending, shelves), by deletes, whose cascade does, and by
+// batches and restores, which readers must never see half done.
+
+// catalog is an isolated in-memory book store. Reviews and cover
+// metadata live next to the books under the same lock so deleting a
+// book cascades atomically.
+type catalog struct {
+	booksMu  sync.RWMutex
+	books    *bookStore // see bookstore.go
+	maxBooks int        // 0 means unlimited
+
+	reviews  map[int]Review
+	reviewID int
+	ratings  map[int]ratingTotals // by book ID
+
+	covers map[int]coverInfo // by book ID, image files live on disk
+
+	// Neighbor index, see similar.go. It has its own locks: writers
+	// only mark books stale, the index catches up outside booksMu.
+	similarMu sync.Mutex
+	similar   map[int][]neighbor // by book ID
+	staleMu   sync.Mutex
+	stale     map[int]bool // book IDs changed since the last refresh
+
+	// Lending, see lending.go
+	copies    map[int]int   // by book ID, DefaultCopies if unset
+	loans     map[int]*Loan // by loan ID, returned loans included
+	loanID    int
+	holds     map[int]*Hold // by hold ID, waiting and reserved
+	holdID    int
+	waitlists map[int]*algorithms.PriorityQueueFunc[*Hold] // waiting holds by book ID
+
+	shelves map[int]*Shelf // by shelf ID, see shelves.go
+	shelfID int
+
+	onChange func(event string, book Book) // see changed
+}
+
+// newCatalog creates an empty catalog
+func newCatalog(maxBooks int) *catalog {
+	return &catalog{
+		books:    newBookStore(BookStoreShards),
+		maxBooks: maxBooks,
+		reviews:  make(map[int]Review),
+		reviewID: 1,
+		ratings:  make(map[int]ratingTotals),
+		covers:   make(map[int]coverInfo),
+		similar:  make(map[int][]neighbor),
+		stale:    make(map[int]bool),
+
+		copies:    make(map[int]int),
+		loans:     make(map[int]*Loan),
+		loanID:    1,
+		holds:     make(map[int]*Hold),
+		holdID:    1,
+		waitlists: make(map[int]*algorithms.PriorityQueueFunc[*Hold]),
+
+		shelves: make(map[int]*Shelf),
+		shelfID: 1,
+	}
+}
+
+// decorate fills the computed fields of a book: ratings aggregated
+// from reviews and the cover URL. Callers must hold booksMu.
+//
+// Stored books are kept decorated so reads need not compute them:
+// writers that change ratings or covers call redecorate.
+func (cat *catalog) decorate(b Book) Book {
+	totals := cat.ratings[b.ID]
+	b.RatingCount = totals.count
+	b.AverageRating = 0
+	if totals.count > 0 {
+		avg := float64(totals.sum) / float64(totals.count)
+		b.AverageRating = math.Round(avg*100) / 100
+	}
+
+	b.CoverURL = ""
+	if _, exists := cat.covers[b.ID]; exists {
+		b.CoverURL = fmt.Sprintf("/api/v1/books/%d/cover", b.ID)
+	}
+	return b
+}
+
+// redecorate refreshes the computed fields of a stored book after its
+// reviews or cover changed. Callers must hold booksMu for writing.
+func (cat *catalog) redecorate(id int) {
+	cat.books.update(id, func(b *Book) { *b = cat.decorate(*b) })
+}
+
+// errQuotaExceeded is returned when a catalog is full
+var errQuotaExceeded = errors.New("tenant book quota exceeded")
+
+// rlock takes the read lock for a request. It gives up with ctx's error
+// if the request timed out or was cancelled before or while waiting, so
+// no work is done for a client that has stopped waiting.
+func (cat *catalog) rlock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cat.booksMu.RLock()
+	if err := ctx.Err(); err != nil {
+		cat.booksMu.RUnlock()
+		return err
+	}
+	return nil
+}
+
+// lock takes the write lock for a request, see rlock
+func (cat *catalog) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cat.booksMu.Lock()
+	if err := ctx.Err(); err != nil {
+		cat.booksMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// list returns a consistent copy of all stored books ordered by ID
+func (cat *catalog) list(ctx context.Context) ([]Book, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	return cat.books.snapshot(ctx)
+}
+
+// count returns the number of stored books
+func (cat *catalog) count() int {
+	return cat.books.len()
+}
+
+// find looks up a single book by ID
+func (cat *catalog) find(ctx context.Context, id int) (Book, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return Book{}, err
+	}
+	book, exists := cat.books.get(id)
+	cat.booksMu.RUnlock()
+	// The shard may have been held by a writer past the deadline
+	if err := ctx.Err(); err != nil {
+		return Book{}, err
+	}
+	if !exists {
+		return Book{}, errBookNotFound
+	}
+	return book, nil
+}
+
+// insert assigns the next ID and creation time, then stores the book
+func (cat *catalog) insert(ctx context.Context, book Book) (Book, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return Book{}, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	return cat.insertLocked(book, func(book Book) {
+		cat.changed(EventBookCreated, book)
+	})
+}
+
+// insertLocked is insert for callers holding booksMu. stored, if not
+// nil, is called with the new book under its shard's lock.
+func (cat *catalog) insertLocked(book Book, stored func(Book)) (Book, error) {
+	book.CreatedAt = time.Now()
+	book, err := cat.books.insertFunc(book, cat.maxBooks, stored)
+	if err != nil {
+		return Book{}, err
+	}
+	cat.markSimilarStale(book.ID)
+	return book, nil
+}
+
+// modify applies fn to a stored book
+func (cat *catalog) modify(ctx context.Context, id int, fn func(*Book)) (Book, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return Book{}, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	return cat.modifyLocked(id, fn, func(book Book) {
+		cat.changed(EventBookUpdated, book)
+	})
+}
+
+// modifyLocked is modify for callers holding booksMu. stored, if not
+// nil, is called with the result under the book's shard lock.
+func (cat *catalog) modifyLocked(id int, fn func(*Book), stored func(Book)) (Book, error) {
+	var before Book
+	book, exists := cat.books.update(id, func(b *Book) {
+		before = *b
+		fn(b)
+		*b = cat.decorate(*b)
+		if stored != nil {
+			stored(*b)
+		}
+	})
+	if !exists {
+		return Book{}, errBookNotFound
+	}
+	if similarFieldsChanged(before, book) {
+		cat.markSimilarStale(id)
+	}
+	return book, nil
+}
+
+// remove deletes a book together with its reviews and cover metadata.
+// Cover files are removed by the caller (see removeCoverFiles).
+func (cat *catalog) remove(ctx context.Context, id int) error {
+	if err := cat.lock(ctx); err != nil {
+		return err
+	}
+	defer cat.booksMu.Unlock()
+
+	book, err := cat.removeLocked(id)
+	if err == nil {
+		cat.changed(EventBookDeleted, book)
+	}
+	return err
+}
+
+// removeLocked is remove for callers holding booksMu for writing. It
+// returns the book as it was before removal.
+func (cat *catalog) removeLocked(id int) (Book, error) {
+	if _, exists := cat.books.get(id); !exists {
+		return Book{}, errBookNotFound
+	}
+	if _, onLoan, _ := cat.bookCounts(id); onLoan > 0 {
+		return Book{}, errBookOnLoan
+	}
+	book, _ := cat.books.delete(id)
+
+	for reviewID, r := range cat.reviews {
+		if r.BookID == id {
+			delete(cat.reviews, reviewID)
+		}
+	}
+	delete(cat.ratings, id)
+	delete(cat.covers, id)
+	cat.dropLending(id)
+	cat.unshelve(id)
+	cat.markSimilarStale(id)
+	return book, nil
+}
+
+// changed reports a committed change to the catalog's listener, if any
+// (see webhooks.go). It is called under the book's shard lock or with
+// booksMu held for writing, so listeners see the changes to a book in
+// commit order, and must not block.
+func (cat *catalog) changed(event string, book Book) {
+	if cat.onChange != nil {
+		cat.onChange(event, book)
+	}
+}
+
+// storeError writes the response for a failed catalog call
+func storeError(c *gin.Context, err error) {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		timeoutError(c, err)
+		return
+	}
+	status, message := storeErrorStatus(err)
+	c.JSON(status, gin.H{"error": message})
+}
+
+// storeErrorStatus maps a catalog error to a response status and message
+func storeErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errBookNotFound):
+		return http.StatusNotFound, "Book not found"
+	case errors.Is(err, errQuotaExceeded):
+		return http.StatusForbidden, "Tenant book quota exceeded"
+	case errors.Is(err, errBookOnLoan):
+		return http.StatusConflict, "Book has copies on loan"
+	default:
+		return http.StatusInternalServerError, err.Error()
+	}
+}
 
 // ============================================================
 // 1. BASIC HANDLERS
 // ============================================================
 
-// HealthCheck - GET /health
+// HealthCheck - GET /health, a summary of the liveness checks. Probes
+// should use /livez, /readyz and /startupz (see health.go).
 func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "ok",
+	status, message := http.StatusOK, "ok"
+	for _, result := range runHealthChecks(c.Request.Context(), Liveness, nil) {
+		if result.Status != "ok" {
+			status, message = http.StatusServiceUnavailable, "failed"
+		}
+	}
+
+	c.JSON(status, gin.H{
+		"status":    message,
 		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
@@ -84,23 +426,37 @@ func Welcome(c *gin.Context) {
 // 2. CRUD HANDLERS FOR BOOKS
 // ============================================================
 
-// GetBooks - GET /api/v1/books
+// GetBooks - GET /api/v1/books?sort=id|rating&fields=...&include=...
 func GetBooks(c *gin.Context) {
-	booksMu.RLock()
-	defer booksMu.RUnlock()
+	p, err := parseProjection(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	bookList := make([]Book, 0, len(books))
-	for _, b := range books {
-		bookList = append(bookList, b)
+	cat := catalogFrom(c)
+	bookList, err := cat.list(c.Request.Context())
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if err := sortBooks(bookList, c.Query("sort")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":  bookList,
-		"count": len(bookList),
+	docs, err := p.books(c.Request.Context(), cat, bookList)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	p.render(c, http.StatusOK, gin.H{
+		"data":  docs,
+		"count": len(docs),
 	})
 }
 
-// GetBook - GET /api/v1/books/:id
+// GetBook - GET /api/v1/books/:id?fields=...&include=...
 func GetBook(c *gin.Context) {
 	// URI binding - get ID from path parameter
 	var uri struct {
@@ -111,17 +467,25 @@ func GetBook(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
 		return
 	}
+	p, err := parseProjection(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	booksMu.RLock()
-	book, exists := books[uri.ID]
-	booksMu.RUnlock()
-
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	cat := catalogFrom(c)
+	book, err := cat.find(c.Request.Context(), uri.ID)
+	if err != nil {
+		storeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": book})
+	docs, err := p.books(c.Request.Context(), cat, []Book{book})
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	p.render(c, http.StatusOK, gin.H{"data": docs[0]})
 }
 
 // CreateBook - POST /api/v1/books
@@ -130,22 +494,15 @@ func CreateBook(c *gin.Context) {
 
 	// JSON binding with validation
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
-	booksMu.Lock()
-	book := Book{
-		ID:        bookID,
-		Title:     input.Title,
-		Author:    input.Author,
-		Year:      input.Year,
-		ISBN:      input.ISBN,
-		CreatedAt: time.Now(),
+	book, err := catalogFrom(c).insert(c.Request.Context(), input.book())
+	if err != nil {
+		storeError(c, err)
+		return
 	}
-	books[bookID] = book
-	bookID++
-	booksMu.Unlock()
 
 	c.JSON(http.StatusCreated, gin.H{"data": book})
 }
@@ -163,34 +520,16 @@ func UpdateBook(c *gin.Context) {
 
 	var input UpdateBookInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
-	booksMu.Lock()
-	defer booksMu.Unlock()
-
-	book, exists := books[uri.ID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	book, err := catalogFrom(c).modify(c.Request.Context(), uri.ID, input.apply)
+	if err != nil {
+		storeError(c, err)
 		return
 	}
 
-	// Update only provided fields
-	if input.Title != nil {
-		book.Title = *input.Title
-	}
-	if input.Author != nil {
-		book.Author = *input.Author
-	}
-	if input.Year != nil {
-		book.Year = *input.Year
-	}
-	if input.ISBN != nil {
-		book.ISBN = *input.ISBN
-	}
-
-	books[uri.ID] = book
 	c.JSON(http.StatusOK, gin.H{"data": book})
 }
 
@@ -205,15 +544,13 @@ func DeleteBook(c *gin.Context) {
 		return
 	}
 
-	booksMu.Lock()
-	defer booksMu.Unlock()
-
-	if _, exists := books[uri.ID]; !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	tenant := tenantFrom(c)
+	if err := tenant.catalog.remove(c.Request.Context(), uri.ID); err != nil {
+		storeError(c, err)
 		return
 	}
+	removeCoverFiles(tenant.ID, uri.ID)
 
-	delete(books, uri.ID)
 	c.JSON(http.StatusOK, gin.H{"message": "Book deleted successfully"})
 }
 
@@ -221,44 +558,98 @@ func DeleteBook(c *gin.Context) {
 // 3. QUERY PARAMETERS EXAMPLE
 // ============================================================
 
-// SearchBooks - GET /api/v1/books/search?author=...&year=...
+// SearchBooks - GET /api/v1/books/search?author=...&year=...&filter=...&sort=...&fields=...&include=...
 func SearchBooks(c *gin.Context) {
 	var query struct {
 		Author string `form:"author"`
 		Year   int    `form:"year"`
-		Limit  int    `form:"limit,default=10"`
+		Filter string `form:"filter"`
+		Limit  int    `form:"limit,default=10" binding:"min=1,max=100"`
+		Sort   string `form:"sort"`
 	}
 
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	p, err := parseProjection(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	booksMu.RLock()
-	defer booksMu.RUnlock()
+	cat := catalogFrom(c)
+	results, err := searchBooks(c.Request.Context(), cat, query.Author, query.Year, query.Filter, query.Sort, query.Limit)
+	if err != nil {
+		var filterErr *FilterError
+		if errors.As(err, &filterErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    filterErr.Error(),
+				"position": filterErr.Position,
+				"filter":   query.Filter,
+			})
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			storeError(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	results := make([]Book, 0)
-	count := 0
+	docs, err := p.books(c.Request.Context(), cat, results)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	p.render(c, http.StatusOK, gin.H{
+		"data":   docs,
+		"count":  len(docs),
+		"filter": query,
+	})
+}
 
-	for _, b := range books {
-		if count >= query.Limit {
-			break
+// searchBooks applies the search criteria shared by SearchBooks and the
+// GraphQL search query. Filter syntax errors are returned as *FilterError.
+// A limit below 0 is treated as 0.
+func searchBooks(ctx context.Context, cat *catalog, author string, year int, filter, sortBy string, limit int) ([]Book, error) {
+	// Optional filter expression (see filter.go)
+	matchFilter := func(Book) bool { return true }
+	if filter != "" {
+		predicate, err := ParseFilter(filter)
+		if err != nil {
+			return nil, err
 		}
+		matchFilter = predicate
+	}
 
-		matchAuthor := query.Author == "" || b.Author == query.Author
-		matchYear := query.Year == 0 || b.Year == query.Year
+	bookList, err := cat.list(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		if matchAuthor && matchYear {
+	results := make([]Book, 0)
+	for _, b := range bookList {
+		matchAuthor := author == "" || b.Author == author
+		matchYear := year == 0 || b.Year == year
+
+		if matchAuthor && matchYear && matchFilter(b) {
 			results = append(results, b)
-			count++
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":   results,
-		"count":  len(results),
-		"filter": query,
-	})
+	// Sort before applying the limit so "top rated" means what it says
+	if err := sortBooks(results, sortBy); err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
 }
 
 // ============================================================
@@ -288,26 +679,65 @@ func TimingMiddleware() gin.HandlerFunc {
 // AuthMiddleware - simple auth middleware example
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-
-		// Simple demo - in real app, validate JWT or session
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		user, err := authenticate(c)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, errWrongTenant) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		// For demo: accept any token starting with "Bearer "
-		if len(token) < 7 || token[:7] != "Bearer " {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
-			c.Abort()
-			return
+		// Set user info in context
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// Authentication errors, used as the 401 response message
+var (
+	errAuthRequired       = errors.New("Authorization header required")
+	errInvalidTokenFormat = errors.New("Invalid token format")
+)
+
+// authenticate returns the user identified by the Authorization header.
+// It is shared by AuthMiddleware and handlers with optional auth. A
+// session of another tenant's account fails with errWrongTenant.
+func authenticate(c *gin.Context) (string, error) {
+	// Already authenticated by APIKeyMiddleware (see apikeys.go)
+	if _, isKey := apiKeyFrom(c); isKey {
+		return c.GetString("user"), nil
+	}
+
+	token := c.GetHeader("Authorization")
+
+	if token == "" {
+		return "", errAuthRequired
+	}
+
+	if len(token) < 7 || token[:7] != "Bearer " {
+		return "", errInvalidTokenFormat
+	}
+
+	// Session tokens issued by /api/v1/auth/login (see accounts.go)
+	user, err := lookupSession(token[7:])
+	switch {
+	case err == nil:
+		if !userInTenant(user, tenantFrom(c).ID) {
+			return "", errWrongTenant
 		}
+		return user, nil
+	case errors.Is(err, errSessionExpired):
+		return "", err
+	}
 
-		// Set user info in context (in real app, decode from token)
-		c.Set("user", "demo_user")
-		c.Next()
+	// For demos and tests: accept any other token starting with "Bearer "
+	if AllowDemoTokens {
+		return DemoUser, nil
 	}
+	return "", err
 }
 
 // ============================================================
@@ -374,17 +804,35 @@ func SetupRouter() *gin.Engine {
 	router.Use(ErrorHandlerMiddleware())
 
 	// Root routes
-	router.GET("/", Welcome)
-	router.GET("/health", HealthCheck)
+	root := router.Group("/")
+	root.Use(SecurityHeadersMiddleware(APISecurityHeaders))
+	{
+		root.GET("/", Welcome)
+		root.GET("/health", HealthCheck)
+
+		// Kubernetes probes
+		root.GET("/livez", ProbeHandler(Liveness))
+		root.GET("/readyz", ProbeHandler(Readiness))
+		root.GET("/startupz", ProbeHandler(Startup))
+	}
 
-	// API v1 group
+	// API v1 group (deprecated in favour of v2)
 	v1 := router.Group("/api/v1")
+	v1.Use(SecurityHeadersMiddleware(APISecurityHeaders))
+	v1.Use(CORSMiddleware(APICORSConfig))
+	v1.Use(BodyLimitMiddleware(MaxRequestBodySize), TimeoutMiddleware(RequestTimeout))
+	v1.Use(APIUsageMiddleware("v1"))
+	v1.Use(DeprecationMiddleware(V1DeprecatedAt, V1SunsetAt, "/api/v2"))
+	v1.Use(TenantMiddleware())
+	v1.Use(APIKeyMiddleware())
 	{
 		// Public routes
+		CORSPreflightRoute(v1, APICORSConfig)
 		v1.GET("/formats", ResponseFormats)
 
 		// Books CRUD
 		booksGroup := v1.Group("/books")
+		booksGroup.Use(RequireMethodScope(ScopeBooksRead, ScopeBooksWrite))
 		{
 			booksGroup.GET("", GetBooks)
 			booksGroup.GET("/:id", GetBook)
@@ -392,24 +840,143 @@ func SetupRouter() *gin.Engine {
 			booksGroup.POST("", CreateBook)
 			booksGroup.PUT("/:id", UpdateBook)
 			booksGroup.DELETE("/:id", DeleteBook)
+
+			// Recommendations (see similar.go)
+			booksGroup.GET("/:id/similar", GetSimilarBooks)
+
+			// Reviews sub-resource (writes require auth)
+			booksGroup.GET("/:id/reviews", GetReviews)
+			booksGroup.GET("/:id/reviews/:reviewId", GetReview)
+			booksGroup.POST("/:id/reviews", AuthMiddleware(), CreateReview)
+			booksGroup.PUT("/:id/reviews/:reviewId", AuthMiddleware(), UpdateReview)
+			booksGroup.DELETE("/:id/reviews/:reviewId", AuthMiddleware(), DeleteReview)
+
+			// Cover images (uploads get larger limits than JSON bodies)
+			booksGroup.PUT("/:id/cover", BodyLimitMiddleware(MaxCoverSize+64<<10), TimeoutMiddleware(UploadTimeout), UploadCover)
+			booksGroup.GET("/:id/cover", GetCover)
+			booksGroup.GET("/:id/cover/:size", GetCover)
+			booksGroup.DELETE("/:id/cover", DeleteCover)
+
+			// Lending (see lending.go)
+			booksGroup.PUT("/:id/copies", SetCopies)
+			booksGroup.GET("/:id/availability", GetAvailability)
+			booksGroup.POST("/:id/checkout", AuthMiddleware(), CheckoutBook)
+			booksGroup.POST("/:id/return", AuthMiddleware(), ReturnBook)
+			booksGroup.GET("/:id/waitlist", GetWaitlist)
+			booksGroup.POST("/:id/waitlist", AuthMiddleware(), JoinWaitlist)
+			booksGroup.DELETE("/:id/waitlist", AuthMiddleware(), LeaveWaitlist)
+		}
+		v1.GET("/loans", AuthMiddleware(), GetMyLoans)
+
+		// Reading lists (see shelves.go)
+		shelvesGroup := v1.Group("/shelves")
+		shelvesGroup.Use(RequireFlag("shelves"), RequireMethodScope(ScopeBooksRead, ScopeBooksWrite))
+		{
+			shelvesGroup.GET("", AuthMiddleware(), ListShelves)
+			shelvesGroup.POST("", AuthMiddleware(), CreateShelf)
+			shelvesGroup.GET("/:shelfId", GetShelf)
+			shelvesGroup.PUT("/:shelfId", AuthMiddleware(), UpdateShelf)
+			shelvesGroup.DELETE("/:shelfId", AuthMiddleware(), DeleteShelf)
+			shelvesGroup.POST("/:shelfId/books", AuthMiddleware(), AddShelfBook)
+			shelvesGroup.PUT("/:shelfId/books", AuthMiddleware(), ReorderShelf)
+			shelvesGroup.DELETE("/:shelfId/books/:id", AuthMiddleware(), RemoveShelfBook)
+			shelvesGroup.POST("/:shelfId/share", AuthMiddleware(), ShareShelf)
+			shelvesGroup.DELETE("/:shelfId/share", AuthMiddleware(), UnshareShelf)
+		}
+		v1.GET("/shared/shelves/:token", RequireFlag("shelves"), RequireScope(ScopeBooksRead), GetSharedShelf)
+
+		// Several book operations in one transaction (see batch.go)
+		v1.POST("/batch", RequireScope(ScopeBooksWrite), BatchBooks)
+
+		// GraphQL over the same catalog
+		v1.GET("/graphql", RequireFlag("graphql"), GraphQL)
+		v1.POST("/graphql", RequireFlag("graphql"), GraphQL)
+
+		// User accounts and sessions
+		authGroup := v1.Group("/auth")
+		{
+			authGroup.POST("/register", Register)
+			authGroup.POST("/login", Login)
+			authGroup.POST("/logout", AuthMiddleware(), Logout)
+			authGroup.GET("/me", AuthMiddleware(), Me)
 		}
 
-		// Protected routes (require auth)
+		// Protected routes (require auth, and a client certificate if
+		// RequireAdminClientCert is set)
 		protected := v1.Group("/admin")
-		protected.Use(AuthMiddleware())
+		protected.Use(ClientCertMiddleware(RequireAdminClientCert), AuthMiddleware(), RequireScope(ScopeAdmin))
 		{
 			protected.GET("/stats", func(c *gin.Context) {
 				user := c.GetString("user")
-				booksMu.RLock()
-				count := len(books)
-				booksMu.RUnlock()
+				tenant := tenantFrom(c)
 
-				c.JSON(http.StatusOK, gin.H{
+				stats := gin.H{
 					"user":        user,
-					"total_books": count,
+					"tenant":      tenant.ID,
+					"total_books": tenant.catalog.count(),
+					"max_books":   tenant.MaxBooks,
+					"api_usage":   APIUsageSnapshot(),
 					"timestamp":   time.Now().Format(time.RFC3339),
-				})
+				}
+				if subject := c.GetString("client_cert_subject"); subject != "" {
+					stats["client_cert"] = subject
+				}
+				c.JSON(http.StatusOK, stats)
 			})
+
+			// Tenant management
+			protected.POST("/tenants", CreateTenant)
+			protected.GET("/tenants", ListTenants)
+			protected.DELETE("/tenants/:tenant", DeleteTenant)
+
+			// Active loans across users
+			protected.GET("/loans", GetAllLoans)
+
+			// Backup and restore of the tenant's catalog
+			protected.POST("/backup", BackupCatalog)
+			protected.POST("/restore", BodyLimitMiddleware(MaxBackupSize), TimeoutMiddleware(UploadTimeout), RestoreCatalog)
+
+			// API keys for machine clients
+			protected.POST("/api-keys", CreateAPIKey)
+			protected.GET("/api-keys", ListAPIKeys)
+			protected.POST("/api-keys/:keyId/rotate", RotateAPIKey)
+			protected.DELETE("/api-keys/:keyId", RevokeAPIKey)
+
+			// Webhooks for book events (see webhooks.go)
+			protected.POST("/webhooks", CreateWebhook)
+			protected.GET("/webhooks", ListWebhooks)
+			protected.GET("/webhooks/:webhookId", GetWebhook)
+			protected.DELETE("/webhooks/:webhookId", DeleteWebhook)
+			protected.POST("/webhooks/:webhookId/enable", EnableWebhook)
+			protected.GET("/webhooks/:webhookId/deliveries", ListWebhookDeliveries)
+
+			// Feature flags (see flags.go)
+			protected.GET("/flags", ListFlags)
+			protected.GET("/flags/:flag", GetFlag)
+			protected.PUT("/flags/:flag", UpdateFlag)
+			protected.GET("/flags/:flag/check", CheckFlag)
+		}
+	}
+
+	// API v2 group - same store, richer Book representation
+	v2 := router.Group("/api/v2")
+	v2.Use(SecurityHeadersMiddleware(APISecurityHeaders))
+	v2.Use(CORSMiddleware(APICORSConfig))
+	v2.Use(BodyLimitMiddleware(MaxRequestBodySize), TimeoutMiddleware(RequestTimeout))
+	v2.Use(APIUsageMiddleware("v2"))
+	v2.Use(TenantMiddleware())
+	v2.Use(APIKeyMiddleware())
+	{
+		CORSPreflightRoute(v2, APICORSConfig)
+
+		booksGroup := v2.Group("/books")
+		booksGroup.Use(RequireMethodScope(ScopeBooksRead, ScopeBooksWrite))
+		{
+			booksGroup.GET("", GetBooksV2)
+			booksGroup.GET("/:id", GetBookV2)
+			booksGroup.POST("", CreateBookV2)
+			booksGroup.PUT("/:id", UpdateBookV2)
+			booksGroup.DELETE("/:id", DeleteBook)
 		}
 	}
 
@@ -431,31 +998,90 @@ func RunGinApp() {
 	fmt.Println("   - JSON validation")
 	fmt.Println("   - Route grouping")
 	fmt.Println("   - Error handling")
+	fmt.Println("   - Multi-tenant catalogs (X-Tenant-ID, signed JWT tenant_id claim or subdomain)")
+	fmt.Println("   - Session tokens for users, scoped X-API-Key keys for machine clients")
+	fmt.Println("   - CORS allowlist and security headers per route group")
+	fmt.Println("   - Body size limits (413) and request deadlines (504) per route group")
+	fmt.Println("   - HTTP/1.1, HTTP/2 over TLS and optional HTTP/3 (QUIC) with Alt-Svc")
+	fmt.Println("   - Hot-reloaded TLS certificates, development CA and mTLS for admin routes")
 
 	fmt.Println("\n2. Available Endpoints:")
 	fmt.Println("   GET  /                     - Welcome message")
 	fmt.Println("   GET  /health               - Health check")
+	fmt.Println("   GET  /livez, /readyz, /startupz - Kubernetes probes (?verbose&exclude=name)")
 	fmt.Println("   GET  /api/v1/formats       - Response format demo")
-	fmt.Println("   GET  /api/v1/books         - List all books")
+	fmt.Println("   GET  /api/v1/books         - List all books (?sort=id|rating)")
 	fmt.Println("   GET  /api/v1/books/:id     - Get book by ID")
-	fmt.Println("   GET  /api/v1/books/search  - Search books (?author=...&year=...)")
+	fmt.Println("        ?fields=title,year&include=reviews,authors,stats&format=json|xml|yaml on list, get and search")
+	fmt.Println("   GET  /api/v1/books/search  - Search books (?author=...&year=...&filter=...)")
 	fmt.Println("   POST /api/v1/books         - Create book")
 	fmt.Println("   PUT  /api/v1/books/:id     - Update book")
-	fmt.Println("   DELETE /api/v1/books/:id   - Delete book")
-	fmt.Println("   GET  /api/v1/admin/stats   - Admin stats (requires auth)")
+	fmt.Println("   DELETE /api/v1/books/:id   - Delete book (and its reviews and cover)")
+	fmt.Println("   GET  /api/v1/books/:id/similar - Similar books with reasons (?limit=5)")
+	fmt.Println("   GET  /api/v1/books/:id/reviews - List reviews of a book")
+	fmt.Println("   POST /api/v1/books/:id/reviews - Rate and review a book (requires auth)")
+	fmt.Println("   PUT  /api/v1/books/:id/reviews/:reviewId - Update own review (requires auth)")
+	fmt.Println("   DELETE /api/v1/books/:id/reviews/:reviewId - Delete own review (requires auth)")
+	fmt.Println("   PUT  /api/v1/books/:id/cover - Upload cover image (multipart or raw body)")
+	fmt.Println("   GET  /api/v1/books/:id/cover[/small|medium|large] - Cover image or thumbnail")
+	fmt.Println("   DELETE /api/v1/books/:id/cover - Remove cover image")
+	fmt.Println("   PUT  /api/v1/books/:id/copies - Set the number of copies owned")
+	fmt.Println("   GET  /api/v1/books/:id/availability - Copies on loan, reserved and free")
+	fmt.Println("   POST /api/v1/books/:id/checkout - Borrow a copy (requires auth)")
+	fmt.Println("   POST /api/v1/books/:id/return - Return a borrowed copy (requires auth)")
+	fmt.Println("   GET  /api/v1/books/:id/waitlist - Reservations and waiting users in order")
+	fmt.Println("   POST /api/v1/books/:id/waitlist - Wait for a copy, {\"priority\": 0-10} set by admins (requires auth)")
+	fmt.Println("   DELETE /api/v1/books/:id/waitlist - Leave the waitlist (requires auth)")
+	fmt.Println("   GET  /api/v1/loans         - Own loans (?status=active|returned|overdue, requires auth)")
+	fmt.Println("   GET  /api/v1/shelves       - Own shelves, or ?owner=name for their public ones (requires auth)")
+	fmt.Println("   POST /api/v1/shelves       - Create a shelf (requires auth)")
+	fmt.Println("   GET  /api/v1/shelves/:shelfId - Shelf with its books (public shelves need no auth)")
+	fmt.Println("   PUT  /api/v1/shelves/:shelfId - Rename, describe or change visibility (owner)")
+	fmt.Println("   DELETE /api/v1/shelves/:shelfId - Delete a shelf (owner)")
+	fmt.Println("   POST /api/v1/shelves/:shelfId/books - Add a book at a position (owner)")
+	fmt.Println("   PUT  /api/v1/shelves/:shelfId/books - Reorder the shelf's books (owner)")
+	fmt.Println("   DELETE /api/v1/shelves/:shelfId/books/:id - Take a book off the shelf (owner)")
+	fmt.Println("   POST /api/v1/shelves/:shelfId/share - Create a sharing link; DELETE revokes it (owner)")
+	fmt.Println("   GET  /api/v1/shared/shelves/:token - Shelf behind a sharing link")
+	fmt.Println("   POST /api/v1/batch         - Apply book creates, updates and deletes atomically")
+	fmt.Println("   POST /api/v1/graphql       - GraphQL: book, books, search, stats and book mutations")
+	fmt.Println("   POST /api/v1/auth/register - Create an account")
+	fmt.Println("   POST /api/v1/auth/login    - Log in and get a session token")
+	fmt.Println("   POST /api/v1/auth/logout   - End the session (requires auth)")
+	fmt.Println("   GET  /api/v1/auth/me       - Current user (requires auth)")
+	fmt.Println("   GET  /api/v1/admin/stats   - Admin stats (requires admin)")
+	fmt.Println("   POST /api/v1/admin/tenants - Create tenant (requires admin)")
+	fmt.Println("   GET  /api/v1/admin/tenants - List tenants (requires admin)")
+	fmt.Println("   DELETE /api/v1/admin/tenants/:tenant - Delete tenant (requires admin)")
+	fmt.Println("   GET  /api/v1/admin/loans   - Active loans of all users (?overdue=true, requires admin)")
+	fmt.Println("   POST /api/v1/admin/backup  - Download catalog backup archive (requires admin)")
+	fmt.Println("   POST /api/v1/admin/restore - Restore catalog from a backup archive (requires admin)")
+	fmt.Println("   POST /api/v1/admin/api-keys - Mint a scoped API key (requires admin)")
+	fmt.Println("   GET  /api/v1/admin/api-keys - List API keys (requires admin)")
+	fmt.Println("   POST /api/v1/admin/api-keys/:keyId/rotate - Rotate an API key (requires admin)")
+	fmt.Println("   DELETE /api/v1/admin/api-keys/:keyId - Revoke an API key (requires admin)")
+	fmt.Println("   POST /api/v1/admin/webhooks - Subscribe a URL to book events (requires admin)")
+	fmt.Println("   GET  /api/v1/admin/webhooks - List webhooks (requires admin)")
+	fmt.Println("   GET  /api/v1/admin/webhooks/:webhookId/deliveries - Delivery log (requires admin)")
+	fmt.Println("   POST /api/v1/admin/webhooks/:webhookId/enable - Re-enable a failing webhook (requires admin)")
+	fmt.Println("   DELETE /api/v1/admin/webhooks/:webhookId - Delete a webhook (requires admin)")
+	fmt.Println("   GET  /api/v1/admin/flags   - Feature flags (requires admin)")
+	fmt.Println("   PUT  /api/v1/admin/flags/:flag - Toggle a flag or change its rollout (requires admin)")
+	fmt.Println("   GET  /api/v1/admin/flags/:flag/check - What a user and tenant would get (requires admin)")
+	fmt.Println("   GET  /api/v2/books         - List books, v2 shape (?tag=...&language=...)")
+	fmt.Println("   GET  /api/v2/books/:id     - Get book by ID, v2 shape")
+	fmt.Println("   POST /api/v2/books         - Create book with structured authors")
+	fmt.Println("   PUT  /api/v2/books/:id     - Update book, v2 shape")
+	fmt.Println("   DELETE /api/v2/books/:id   - Delete book")
 
 	fmt.Println("\n3. To start the server, run:")
 	fmt.Println("   go run cmd/ginapp/main.go")
 
-	// Load sample data
-	booksMu.Lock()
-	books[bookID] = Book{ID: bookID, Title: "The Go Programming Language", Author: "Donovan & Kernighan", Year: 2015, CreatedAt: time.Now()}
-	bookID++
-	books[bookID] = Book{ID: bookID, Title: "Learning Go", Author: "Jon Bodner", Year: 2021, CreatedAt: time.Now()}
-	bookID++
-	books[bookID] = Book{ID: bookID, Title: "Concurrency in Go", Author: "Katherine Cox-Buday", Year: 2017, CreatedAt: time.Now()}
-	bookID++
-	booksMu.Unlock()
-
-	fmt.Printf("\n4. Sample books loaded: %d books\n", len(books))
+	// Load the demo fixture set into the default tenant
+	summary, err := LoadFixture(context.Background(), "demo")
+	if err != nil {
+		fmt.Printf("\n4. Could not load sample data: %v\n", err)
+		return
+	}
+	fmt.Printf("\n4. Sample data loaded: %s\n", summary)
 }
@@ -5,21 +5,39 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 )
 
+func TestMain(m *testing.M) {
+	// Tests send "Bearer mytoken" where any signed-in user will do
+	AllowDemoTokens = true
+	os.Exit(m.Run())
+}
+
 func setupTestRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	return SetupRouter()
 }
 
+// apiRequest sends a JSON request. headers are name/value pairs.
+func apiRequest(router http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
 func resetBooks() {
-	booksMu.Lock()
-	books = make(map[int]Book)
-	bookID = 1
-	booksMu.Unlock()
+	tenantsMu.Lock()
+	tenants = map[string]*Tenant{DefaultTenantID: newTenant(DefaultTenantID, "Default", 0)}
+	tenantsMu.Unlock()
 }
 
 func TestWelcome(t *testing.T) {
@@ -406,6 +424,21 @@ func TestSearchBooks(t *testing.T) {
 	}
 }
 
+func TestSearchBooks_InvalidLimit(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+
+	for _, limit := range []string{"-1", "0", "101", "x"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/books/search?limit="+limit, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: expected status %d, got %d", limit, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
 func TestResponseFormats(t *testing.T) {
 	router := setupTestRouter()
 
@@ -502,7 +535,7 @@ func TestAuthMiddleware_ValidToken(t *testing.T) {
 	resetBooks()
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/api/v1/admin/stats", nil)
+	req, _ := http.NewRequest("GET", "/api/v1/auth/me", nil)
 	req.Header.Set("Authorization", "Bearer mytoken")
 	router.ServeHTTP(w, req)
 
@@ -510,13 +543,13 @@ func TestAuthMiddleware_ValidToken(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
-	var response map[string]interface{}
+	var response map[string]map[string]interface{}
 	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
 
-	if response["user"] != "demo_user" {
-		t.Errorf("Expected user 'demo_user', got %v", response["user"])
+	if response["data"]["username"] != "demo_user" {
+		t.Errorf("Expected user 'demo_user', got %v", response["data"]["username"])
 	}
 }
 
@@ -0,0 +1,674 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// ============================================================
+// GRAPHQL
+// ============================================================
+// /api/v1/graphql lets a client fetch books, authors and stats in one
+// round trip, selecting exactly the fields it needs:
+//
+//   {
+//     books(sort: "rating", limit: 5) { id title authors { name } }
+//     stats { totalBooks totalReviews }
+//   }
+//
+// The Book type is derived from the Go struct by reflection, so a new
+// field on Book shows up in the schema without touching this file.
+// Resolvers go through the same catalog methods as the REST handlers
+// and mutations reuse the REST input structs and their validation tags.
+//
+// Every request is parsed and validated, then its depth and complexity
+// are checked against the limits below before anything executes.
+// Introspection (__schema, __type) is supported. Its fields count
+// toward the complexity limit like any others, but its nesting follows
+// the schema's type references, so it has its own, deeper depth limit
+// that still fits the query GraphiQL sends.
+// ============================================================
+
+// GraphQL limits
+var (
+	MaxGraphQLQueryLength = 10000 // bytes
+	MaxGraphQLDepth       = 8     // nested field levels
+	MaxGraphQLComplexity  = 500   // see queryCost
+
+	MaxGraphQLIntrospectionDepth = 15 // nested levels below __schema or __type
+)
+
+// GraphQL list bounds for books(limit: ...)
+const (
+	graphqlDefaultLimit = 50
+	graphqlMaxLimit     = 100
+)
+
+// CatalogStats - summary of the requesting tenant's catalog
+type CatalogStats struct {
+	Tenant       string
+	TotalBooks   int
+	MaxBooks     int
+	TotalReviews int
+}
+
+// graphqlSchema is built once at startup; it only changes with the code
+var graphqlSchema = buildGraphQLSchema()
+
+// graphqlContextKey carries per-request state to resolvers
+type graphqlContextKey struct{}
+
+// graphqlCaller - who is asking and which catalog they see
+type graphqlCaller struct {
+	tenant *Tenant
+	user   string // empty when unauthenticated
+	admin  bool   // false for API keys without the admin scope
+}
+
+func callerFrom(ctx context.Context) graphqlCaller {
+	caller, _ := ctx.Value(graphqlContextKey{}).(graphqlCaller)
+	return caller
+}
+
+// ============================================================
+// SCHEMA
+// ============================================================
+
+// graphqlTypes derives GraphQL object types from Go structs. Each struct
+// maps to one object type named after it, built on first use.
+type graphqlTypes map[reflect.Type]*graphql.Object
+
+// object returns the object type for struct type t. Every exported field
+// is included, even ones hidden from the v1 JSON, named in lowerCamelCase
+// (ISBN -> isbn, CoverURL -> coverURL).
+func (types graphqlTypes) object(t reflect.Type, description string) *graphql.Object {
+	if obj, exists := types[t]; exists {
+		return obj
+	}
+
+	fields := graphql.Fields{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		index := sf.Index
+		fields[graphqlFieldName(sf.Name)] = &graphql.Field{
+			Type: types.output(sf.Type),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				v := reflect.ValueOf(p.Source)
+				if v.Kind() == reflect.Pointer {
+					v = v.Elem()
+				}
+				return v.FieldByIndex(index).Interface(), nil
+			},
+		}
+	}
+
+	obj := graphql.NewObject(graphql.ObjectConfig{
+		Name:        t.Name(),
+		Description: description,
+		Fields:      fields,
+	})
+	types[t] = obj
+	return obj
+}
+
+// output maps a Go field type to a non-null GraphQL output type
+func (types graphqlTypes) output(t reflect.Type) graphql.Output {
+	if t == reflect.TypeOf(time.Time{}) {
+		return graphql.NewNonNull(graphql.DateTime)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return graphql.NewNonNull(graphql.String)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return graphql.NewNonNull(graphql.Int)
+	case reflect.Float32, reflect.Float64:
+		return graphql.NewNonNull(graphql.Float)
+	case reflect.Bool:
+		return graphql.NewNonNull(graphql.Boolean)
+	case reflect.Slice:
+		return graphql.NewNonNull(graphql.NewList(types.output(t.Elem())))
+	case reflect.Struct:
+		return graphql.NewNonNull(types.object(t, ""))
+	}
+	panic(fmt.Sprintf("graphql: unsupported field type %s", t))
+}
+
+// graphqlFieldName converts a Go field name to lowerCamelCase,
+// keeping acronyms together: ID -> id, URLPath -> urlPath
+func graphqlFieldName(goName string) string {
+	runes := []rune(goName)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+
+	switch {
+	case upper == len(runes):
+		return strings.ToLower(goName)
+	case upper > 1:
+		upper-- // the last capital starts the next word
+	}
+	return strings.ToLower(string(runes[:upper])) + string(runes[upper:])
+}
+
+// buildGraphQLSchema assembles the query and mutation types
+func buildGraphQLSchema() graphql.Schema {
+	types := graphqlTypes{}
+	bookType := types.object(reflect.TypeOf(Book{}), "A book in the tenant's catalog")
+	statsType := types.object(reflect.TypeOf(CatalogStats{}), "Catalog statistics (requires auth)")
+	bookList := graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType)))
+
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateBookInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"author": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"year":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+			"isbn":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateBookInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"author": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"year":   &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"isbn":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"book": &graphql.Field{
+				Type:        bookType,
+				Description: "A single book, null if it does not exist",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: resolveBook,
+			},
+			"books": &graphql.Field{
+				Type:        bookList,
+				Description: "Books ordered by id or rating",
+				Args: graphql.FieldConfigArgument{
+					"sort":   &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "id"},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphqlDefaultLimit},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: resolveBooks,
+			},
+			"search": &graphql.Field{
+				Type:        bookList,
+				Description: "Same criteria as GET /api/v1/books/search",
+				Args: graphql.FieldConfigArgument{
+					"author": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"year":   &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"filter": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"sort":   &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10},
+				},
+				Resolve: resolveSearch,
+			},
+			"stats": &graphql.Field{
+				Type:    graphql.NewNonNull(statsType),
+				Resolve: resolveStats,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createBook": &graphql.Field{
+				Type: graphql.NewNonNull(bookType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createInput)},
+				},
+				Resolve: resolveCreateBook,
+			},
+			"updateBook": &graphql.Field{
+				Type: bookType,
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateInput)},
+				},
+				Resolve: resolveUpdateBook,
+			},
+			"deleteBook": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: resolveDeleteBook,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		panic(fmt.Sprintf("graphql: invalid schema: %v", err))
+	}
+	return schema
+}
+
+// ============================================================
+// RESOLVERS
+// ============================================================
+
+// graphqlError is a resolver error with machine-readable extensions
+type graphqlError struct {
+	message    string
+	extensions map[string]interface{}
+}
+
+func (e *graphqlError) Error() string                      { return e.message }
+func (e *graphqlError) Extensions() map[string]interface{} { return e.extensions }
+
+// Resolver errors, worded like the REST responses
+var (
+	errGraphQLBookNotFound = errors.New("Book not found")
+	errGraphQLAuthRequired = errors.New("Authorization required")
+	errGraphQLAdminScope   = errors.New("API key lacks scope " + ScopeAdmin)
+)
+
+func resolveBook(p graphql.ResolveParams) (interface{}, error) {
+	book, err := callerFrom(p.Context).tenant.catalog.find(p.Context, p.Args["id"].(int))
+	if errors.Is(err, errBookNotFound) {
+		return nil, nil
+	}
+	return book, err
+}
+
+func resolveBooks(p graphql.ResolveParams) (interface{}, error) {
+	limit, offset := p.Args["limit"].(int), p.Args["offset"].(int)
+	if limit < 1 || limit > graphqlMaxLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", graphqlMaxLimit)
+	}
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+
+	bookList, err := callerFrom(p.Context).tenant.catalog.list(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	if err := sortBooks(bookList, p.Args["sort"].(string)); err != nil {
+		return nil, err
+	}
+	if offset > len(bookList) {
+		offset = len(bookList)
+	}
+	bookList = bookList[offset:]
+	if len(bookList) > limit {
+		bookList = bookList[:limit]
+	}
+	return bookList, nil
+}
+
+func resolveSearch(p graphql.ResolveParams) (interface{}, error) {
+	results, err := searchBooks(
+		p.Context,
+		callerFrom(p.Context).tenant.catalog,
+		p.Args["author"].(string),
+		p.Args["year"].(int),
+		p.Args["filter"].(string),
+		p.Args["sort"].(string),
+		p.Args["limit"].(int),
+	)
+
+	var filterErr *FilterError
+	if errors.As(err, &filterErr) {
+		return nil, &graphqlError{
+			message:    filterErr.Error(),
+			extensions: map[string]interface{}{"code": "BAD_FILTER", "position": filterErr.Position},
+		}
+	}
+	return results, err
+}
+
+func resolveStats(p graphql.ResolveParams) (interface{}, error) {
+	caller := callerFrom(p.Context)
+	if caller.user == "" {
+		return nil, errGraphQLAuthRequired
+	}
+	if !caller.admin {
+		return nil, errGraphQLAdminScope
+	}
+
+	return CatalogStats{
+		Tenant:       caller.tenant.ID,
+		TotalBooks:   caller.tenant.catalog.count(),
+		MaxBooks:     caller.tenant.MaxBooks,
+		TotalReviews: caller.tenant.catalog.reviewCount(),
+	}, nil
+}
+
+// decodeInput copies a GraphQL input object into a REST input struct
+// and runs the struct's binding validation, so both APIs accept exactly
+// the same values
+func decodeInput(args interface{}, input interface{}) error {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, input); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(input)
+}
+
+func resolveCreateBook(p graphql.ResolveParams) (interface{}, error) {
+	var input CreateBookInput
+	if err := decodeInput(p.Args["input"], &input); err != nil {
+		return nil, err
+	}
+
+	return callerFrom(p.Context).tenant.catalog.insert(p.Context, input.book())
+}
+
+func resolveUpdateBook(p graphql.ResolveParams) (interface{}, error) {
+	var input UpdateBookInput
+	if err := decodeInput(p.Args["input"], &input); err != nil {
+		return nil, err
+	}
+
+	book, err := callerFrom(p.Context).tenant.catalog.modify(p.Context, p.Args["id"].(int), input.apply)
+	if errors.Is(err, errBookNotFound) {
+		return nil, errGraphQLBookNotFound
+	}
+	return book, err
+}
+
+func resolveDeleteBook(p graphql.ResolveParams) (interface{}, error) {
+	tenant := callerFrom(p.Context).tenant
+	id := p.Args["id"].(int)
+	if err := tenant.catalog.remove(p.Context, id); err != nil {
+		if errors.Is(err, errBookNotFound) {
+			return false, errGraphQLBookNotFound
+		}
+		return false, err
+	}
+	removeCoverFiles(tenant.ID, id)
+	return true, nil
+}
+
+// ============================================================
+// QUERY LIMITS
+// ============================================================
+
+// queryCost measures an operation before it runs. Depth counts nested
+// field levels. Complexity counts one per field, and the fields selected
+// below a list are multiplied by the list's limit argument (or its
+// default), so books(limit: 100) { id title } costs 1 + 100*2 = 201.
+// Introspection is measured the same way, but its depth is counted
+// from its own root field and kept apart.
+type queryCost struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+
+	introspectionDepth int // deepest level below __schema or __type
+}
+
+// measure returns the depth and complexity of a selection set
+func (qc *queryCost) measure(set *ast.SelectionSet, parent graphql.Type, depth int) (maxDepth, complexity int) {
+	maxDepth = depth
+	if set == nil {
+		return maxDepth, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, cost int
+		switch sel := selection.(type) {
+		case *ast.Field:
+			field := qc.field(parent, sel.Name.Value)
+			if field == nil {
+				continue
+			}
+			named, _ := graphql.GetNamed(field.Type).(graphql.Type)
+			if field == graphql.SchemaMetaFieldDef || field == graphql.TypeMetaFieldDef {
+				var introspection int
+				introspection, cost = qc.measure(sel.SelectionSet, named, 1)
+				qc.introspectionDepth = max(qc.introspectionDepth, introspection)
+				complexity += 1 + cost
+				continue
+			}
+			d, cost = qc.measure(sel.SelectionSet, named, depth+1)
+			cost = 1 + cost*qc.listSize(sel, field)
+		case *ast.InlineFragment:
+			typ := parent
+			if sel.TypeCondition != nil {
+				typ = qc.schema.Type(sel.TypeCondition.Name.Value)
+			}
+			d, cost = qc.measure(sel.SelectionSet, typ, depth)
+		case *ast.FragmentSpread:
+			// Fragment cycles are rejected by validation before this runs
+			fragment, exists := qc.fragments[sel.Name.Value]
+			if !exists {
+				continue
+			}
+			d, cost = qc.measure(fragment.SelectionSet, qc.schema.Type(fragment.TypeCondition.Name.Value), depth)
+		}
+		maxDepth = max(maxDepth, d)
+		complexity += cost
+	}
+	return maxDepth, complexity
+}
+
+// field looks up a field definition on an object type, including the
+// introspection fields every type or the query root has implicitly
+func (qc *queryCost) field(parent graphql.Type, name string) *graphql.FieldDefinition {
+	switch name {
+	case "__schema":
+		return graphql.SchemaMetaFieldDef
+	case "__type":
+		return graphql.TypeMetaFieldDef
+	case "__typename":
+		return graphql.TypeNameMetaFieldDef
+	}
+	obj, ok := parent.(*graphql.Object)
+	if !ok {
+		return nil
+	}
+	return obj.Fields()[name]
+}
+
+// listSize estimates how many items a field returns: 1 for non-lists,
+// otherwise its "limit" argument as given, defaulted, or 1 if it has none
+func (qc *queryCost) listSize(sel *ast.Field, field *graphql.FieldDefinition) int {
+	if _, isList := graphql.GetNullable(field.Type).(*graphql.List); !isList {
+		return 1
+	}
+
+	for _, arg := range sel.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				return max(n, 1)
+			}
+		case *ast.Variable:
+			// JSON numbers decode as float64
+			if n, ok := qc.variables[value.Name.Value].(float64); ok {
+				return max(int(n), 1)
+			}
+		}
+	}
+
+	for _, arg := range field.Args {
+		if n, ok := arg.DefaultValue.(int); ok && arg.Name() == "limit" {
+			return n
+		}
+	}
+	return 1
+}
+
+// selectOperation picks the operation a request asks to run
+func selectOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" && found != nil {
+			return nil // ambiguous; Execute reports it
+		}
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			found = op
+		}
+	}
+	return found
+}
+
+// ============================================================
+// HANDLER
+// ============================================================
+
+// GraphQLRequest - body of POST /api/v1/graphql (query parameters for GET)
+type GraphQLRequest struct {
+	Query         string                 `json:"query" form:"query" binding:"required"`
+	OperationName string                 `json:"operationName" form:"operationName"`
+	Variables     map[string]interface{} `json:"variables" form:"-"`
+}
+
+// graphqlFail writes a request error in the GraphQL response shape
+func graphqlFail(c *gin.Context, status int, errs ...gqlerrors.FormattedError) {
+	c.JSON(status, gin.H{"errors": errs})
+}
+
+// limitError reports a query that is too deep or too expensive
+func limitError(message string, limit, actual int) gqlerrors.FormattedError {
+	err := gqlerrors.NewFormattedError(message)
+	err.Extensions = map[string]interface{}{"limit": limit, "actual": actual}
+	return err
+}
+
+// GraphQL - POST /api/v1/graphql, or GET /api/v1/graphql?query=... for queries
+func GraphQL(c *gin.Context) {
+	var req GraphQLRequest
+	if c.Request.Method == http.MethodGet {
+		if err := c.ShouldBindQuery(&req); err != nil {
+			graphqlFail(c, http.StatusBadRequest, gqlerrors.NewFormattedError(err.Error()))
+			return
+		}
+		if vars := c.Query("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				graphqlFail(c, http.StatusBadRequest, gqlerrors.NewFormattedError("variables must be a JSON object"))
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		graphqlFail(c, status, gqlerrors.NewFormattedError(err.Error()))
+		return
+	}
+
+	if len(req.Query) > MaxGraphQLQueryLength {
+		graphqlFail(c, http.StatusBadRequest, limitError("query is too long", MaxGraphQLQueryLength, len(req.Query)))
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		graphqlFail(c, http.StatusBadRequest, gqlerrors.FormatErrors(err)...)
+		return
+	}
+	if result := graphql.ValidateDocument(&graphqlSchema, doc, nil); !result.IsValid {
+		graphqlFail(c, http.StatusBadRequest, result.Errors...)
+		return
+	}
+
+	if op := selectOperation(doc, req.OperationName); op != nil {
+		if op.Operation == ast.OperationTypeMutation && c.Request.Method == http.MethodGet {
+			c.Header("Allow", http.MethodPost)
+			graphqlFail(c, http.StatusMethodNotAllowed, gqlerrors.NewFormattedError("mutations must use POST"))
+			return
+		}
+
+		// API keys need books:read for queries and books:write for mutations
+		scope := ScopeBooksRead
+		if op.Operation == ast.OperationTypeMutation {
+			scope = ScopeBooksWrite
+		}
+		if !scopeAllowed(c, scope) {
+			graphqlFail(c, http.StatusForbidden, gqlerrors.NewFormattedError("API key lacks scope "+scope))
+			return
+		}
+
+		qc := &queryCost{schema: &graphqlSchema, fragments: map[string]*ast.FragmentDefinition{}, variables: req.Variables}
+		for _, def := range doc.Definitions {
+			if fragment, ok := def.(*ast.FragmentDefinition); ok {
+				qc.fragments[fragment.Name.Value] = fragment
+			}
+		}
+		root := graphqlSchema.QueryType()
+		if op.Operation == ast.OperationTypeMutation {
+			root = graphqlSchema.MutationType()
+		}
+
+		depth, complexity := qc.measure(op.SelectionSet, root, 0)
+		if depth > MaxGraphQLDepth {
+			graphqlFail(c, http.StatusBadRequest, limitError("query is nested too deeply", MaxGraphQLDepth, depth))
+			return
+		}
+		if qc.introspectionDepth > MaxGraphQLIntrospectionDepth {
+			graphqlFail(c, http.StatusBadRequest, limitError("introspection is nested too deeply", MaxGraphQLIntrospectionDepth, qc.introspectionDepth))
+			return
+		}
+		if complexity > MaxGraphQLComplexity {
+			graphqlFail(c, http.StatusBadRequest, limitError("query is too complex", MaxGraphQLComplexity, complexity))
+			return
+		}
+	}
+
+	// Auth is optional: only fields like stats require a user
+	user, _ := authenticate(c)
+	ctx := context.WithValue(c.Request.Context(), graphqlContextKey{}, graphqlCaller{
+		tenant: tenantFrom(c),
+		user:   user,
+		admin:  scopeAllowed(c, ScopeAdmin),
+	})
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphqlSchema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	if err := ctx.Err(); err != nil {
+		timeoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,336 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// graphqlResponse - decoded GraphQL response body
+type graphqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// postGraphQL sends a query and decodes the response
+func postGraphQL(t *testing.T, router http.Handler, query string, variables map[string]interface{}, headers ...string) (int, graphqlResponse) {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	router.ServeHTTP(w, req)
+
+	var response graphqlResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v: %s", err, w.Body.String())
+	}
+	return w.Code, response
+}
+
+func setupGraphQLTest() http.Handler {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "The Go Programming Language", Author: "Donovan & Kernighan", Authors: splitAuthors("Donovan & Kernighan"), Year: 2015})
+	cat.insert(context.Background(), Book{Title: "Learning Go", Author: "Jon Bodner", Authors: splitAuthors("Jon Bodner"), Year: 2021})
+	return router
+}
+
+func TestGraphQL_Queries(t *testing.T) {
+	router := setupGraphQLTest()
+
+	status, response := postGraphQL(t, router, `{
+		books(limit: 1) { id title authors { name role } }
+		book(id: 2) { title year isbn createdAt }
+		missing: book(id: 99) { id }
+		search(filter: "year > 2020") { title }
+	}`, nil)
+
+	if status != http.StatusOK || len(response.Errors) > 0 {
+		t.Fatalf("Expected success, got %d: %+v", status, response.Errors)
+	}
+
+	books := response.Data["books"].([]interface{})
+	if len(books) != 1 {
+		t.Fatalf("Expected 1 book, got %d", len(books))
+	}
+	authors := books[0].(map[string]interface{})["authors"].([]interface{})
+	if len(authors) != 2 || authors[1].(map[string]interface{})["name"] != "Kernighan" {
+		t.Errorf("Unexpected authors: %v", authors)
+	}
+
+	book := response.Data["book"].(map[string]interface{})
+	if book["title"] != "Learning Go" || book["year"] != float64(2021) || book["createdAt"] == "" {
+		t.Errorf("Unexpected book: %v", book)
+	}
+	if response.Data["missing"] != nil {
+		t.Errorf("Expected null for a missing book, got %v", response.Data["missing"])
+	}
+	if search := response.Data["search"].([]interface{}); len(search) != 1 {
+		t.Errorf("Expected 1 search result, got %d", len(search))
+	}
+}
+
+func TestGraphQL_Get(t *testing.T) {
+	router := setupGraphQLTest()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/graphql?query="+url.QueryEscape(`{ books { id } }`), nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Mutations are not allowed over GET
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/graphql?query="+url.QueryEscape(`mutation { deleteBook(id: 1) }`), nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+	if _, err := defaultTenant().catalog.find(context.Background(), 1); err != nil {
+		t.Error("Expected book 1 to survive a GET mutation")
+	}
+}
+
+func TestGraphQL_Mutations(t *testing.T) {
+	router := setupGraphQLTest()
+
+	status, response := postGraphQL(t, router,
+		`mutation Create($input: CreateBookInput!) { createBook(input: $input) { id title authors { name } } }`,
+		map[string]interface{}{"input": map[string]interface{}{"title": "Go in Action", "author": "Kennedy & Ketelsen", "year": 2015}})
+	if status != http.StatusOK || len(response.Errors) > 0 {
+		t.Fatalf("Expected success, got %d: %+v", status, response.Errors)
+	}
+	created := response.Data["createBook"].(map[string]interface{})
+	if created["id"] != float64(3) || len(created["authors"].([]interface{})) != 2 {
+		t.Errorf("Unexpected created book: %v", created)
+	}
+
+	_, response = postGraphQL(t, router, `mutation { updateBook(id: 3, input: {year: 2016, author: "Kennedy"}) { title year authors { name } } }`, nil)
+	updated := response.Data["updateBook"].(map[string]interface{})
+	if updated["title"] != "Go in Action" || updated["year"] != float64(2016) || len(updated["authors"].([]interface{})) != 1 {
+		t.Errorf("Unexpected updated book: %v", updated)
+	}
+
+	_, response = postGraphQL(t, router, `mutation { deleteBook(id: 3) }`, nil)
+	if response.Data["deleteBook"] != true {
+		t.Errorf("Expected deleteBook to return true, got %v", response.Data["deleteBook"])
+	}
+	if _, err := defaultTenant().catalog.find(context.Background(), 3); err == nil {
+		t.Error("Expected book 3 to be deleted")
+	}
+}
+
+func TestGraphQL_MutationErrors(t *testing.T) {
+	router := setupGraphQLTest()
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{name: "validation tags", query: `mutation { createBook(input: {title: "X", author: "Y", year: 99}) { id } }`, message: "Year"},
+		{name: "update validation tags", query: `mutation { updateBook(id: 1, input: {title: ""}) { id } }`, message: "Title"},
+		{name: "missing book", query: `mutation { updateBook(id: 99, input: {title: "X"}) { id } }`, message: "Book not found"},
+		{name: "delete missing", query: `mutation { deleteBook(id: 99) }`, message: "Book not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, response := postGraphQL(t, router, tt.query, nil)
+			if len(response.Errors) == 0 || !strings.Contains(response.Errors[0].Message, tt.message) {
+				t.Errorf("Expected error containing %q, got %+v", tt.message, response.Errors)
+			}
+		})
+	}
+
+	if defaultTenant().catalog.count() != 2 {
+		t.Error("Expected failed mutations to leave the catalog unchanged")
+	}
+}
+
+func TestGraphQL_Stats(t *testing.T) {
+	router := setupGraphQLTest()
+
+	_, response := postGraphQL(t, router, `{ stats { totalBooks } }`, nil)
+	if len(response.Errors) == 0 {
+		t.Error("Expected stats to require auth")
+	}
+
+	_, response = postGraphQL(t, router, `{ stats { tenant totalBooks maxBooks totalReviews } }`, nil, "Authorization", "Bearer mytoken")
+	if len(response.Errors) == 0 {
+		t.Error("Expected stats to require the admin role")
+	}
+
+	_, response = postGraphQL(t, router, `{ stats { tenant totalBooks maxBooks totalReviews } }`, nil, "Authorization", "Bearer "+adminToken(t))
+	stats := response.Data["stats"].(map[string]interface{})
+	if stats["tenant"] != DefaultTenantID || stats["totalBooks"] != float64(2) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestGraphQL_TenantIsolation(t *testing.T) {
+	router := setupGraphQLTest()
+	createTestTenant(t, router, "acme", 0)
+
+	_, response := postGraphQL(t, router, `{ books { id } }`, nil, "X-Tenant-ID", "acme")
+	if books := response.Data["books"].([]interface{}); len(books) != 0 {
+		t.Errorf("Expected acme to see no books, got %d", len(books))
+	}
+}
+
+func TestGraphQL_FilterError(t *testing.T) {
+	router := setupGraphQLTest()
+
+	_, response := postGraphQL(t, router, `{ search(filter: "year >= AND") { id } }`, nil)
+	if len(response.Errors) != 1 {
+		t.Fatalf("Expected 1 error, got %+v", response.Errors)
+	}
+	if response.Errors[0].Extensions["position"] != float64(9) {
+		t.Errorf("Expected position 9, got %v", response.Errors[0].Extensions)
+	}
+}
+
+func TestGraphQL_SearchNegativeLimit(t *testing.T) {
+	router := setupGraphQLTest()
+
+	code, response := postGraphQL(t, router, `{ search(limit: -1) { id } }`, nil)
+	if code != http.StatusOK || len(response.Errors) != 0 {
+		t.Fatalf("Expected status 200 without errors, got %d: %+v", code, response.Errors)
+	}
+	if books := response.Data["search"].([]interface{}); len(books) != 0 {
+		t.Errorf("Expected no books, got %d", len(books))
+	}
+}
+
+func TestGraphQL_Limits(t *testing.T) {
+	router := setupGraphQLTest()
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{name: "within limits", query: `{ books(limit: 100) { id title } }`, expectedStatus: http.StatusOK},
+		{name: "too complex", query: `{ books(limit: 100) { id title author year isbn authors { name role } } }`, expectedStatus: http.StatusBadRequest},
+		{name: "default limit counts", query: `{ a: books { id title author year isbn language } b: books { id title author year isbn language } }`, expectedStatus: http.StatusBadRequest},
+		{name: "fragments count", query: `{ books(limit: 100) { ...f } } fragment f on Book { id title author year isbn }`, expectedStatus: http.StatusBadRequest},
+		{name: "syntax error", query: `{ books { id `, expectedStatus: http.StatusBadRequest},
+		{name: "unknown field", query: `{ books { pages } }`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := postGraphQL(t, router, tt.query, nil)
+			if status != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %+v", tt.expectedStatus, status, response.Errors)
+			}
+		})
+	}
+}
+
+func TestGraphQL_DepthLimit(t *testing.T) {
+	router := setupGraphQLTest()
+	defer func(old int) { MaxGraphQLDepth = old }(MaxGraphQLDepth)
+	MaxGraphQLDepth = 1
+
+	status, response := postGraphQL(t, router, `{ books { authors { name } } }`, nil)
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, status)
+	}
+	if response.Errors[0].Extensions["actual"] != float64(3) {
+		t.Errorf("Expected depth 3, got %v", response.Errors[0].Extensions)
+	}
+}
+
+func TestGraphQL_Introspection(t *testing.T) {
+	router := setupGraphQLTest()
+
+	status, response := postGraphQL(t, router, `{ __type(name: "Book") { fields { name type { kind ofType { name } } } } }`, nil)
+	if status != http.StatusOK || len(response.Errors) > 0 {
+		t.Fatalf("Expected success, got %d: %+v", status, response.Errors)
+	}
+
+	fields := map[string]bool{}
+	for _, f := range response.Data["__type"].(map[string]interface{})["fields"].([]interface{}) {
+		fields[f.(map[string]interface{})["name"].(string)] = true
+	}
+	for _, name := range []string{"id", "isbn", "coverURL", "averageRating", "authors", "tags", "createdAt"} {
+		if !fields[name] {
+			t.Errorf("Expected Book to expose %q, got %v", name, fields)
+		}
+	}
+}
+
+func TestGraphQL_IntrospectionLimits(t *testing.T) {
+	router := setupGraphQLTest()
+
+	// ofType nested n times, as GraphiQL's TypeRef fragment does
+	typeRef := func(n int) string {
+		return "type { kind name " + strings.Repeat("ofType { kind name ", n) + strings.Repeat("} ", n) + "}"
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{name: "graphiql type refs", query: `{ __schema { types { name fields { name ` + typeRef(7) + ` } } } }`, expectedStatus: http.StatusOK},
+		{name: "too deep", query: `{ __type(name: "Book") { fields { ` + typeRef(MaxGraphQLIntrospectionDepth) + ` } } }`, expectedStatus: http.StatusBadRequest},
+		{name: "typename in data", query: `{ books { authors { __typename } } }`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := postGraphQL(t, router, tt.query, nil)
+			if status != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %+v", tt.expectedStatus, status, response.Errors)
+			}
+		})
+	}
+}
+
+func TestGraphQL_IntrospectionComplexity(t *testing.T) {
+	router := setupGraphQLTest()
+	defer func(old int) { MaxGraphQLComplexity = old }(MaxGraphQLComplexity)
+	MaxGraphQLComplexity = 3
+
+	status, response := postGraphQL(t, router, `{ __schema { types { name kind } } }`, nil)
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, status)
+	}
+	if response.Errors[0].Extensions["actual"] != float64(4) {
+		t.Errorf("Expected complexity 4, got %v", response.Errors[0].Extensions)
+	}
+}
+
+func TestGraphQLFieldName(t *testing.T) {
+	tests := map[string]string{
+		"ID":            "id",
+		"ISBN":          "isbn",
+		"Title":         "title",
+		"CoverURL":      "coverURL",
+		"URLPath":       "urlPath",
+		"AverageRating": "averageRating",
+	}
+
+	for goName, expected := range tests {
+		if got := graphqlFieldName(goName); got != expected {
+			t.Errorf("graphqlFieldName(%q) = %q, expected %q", goName, got, expected)
+		}
+	}
+}
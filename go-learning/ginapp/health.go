@@ -0,0 +1,259 @@
+package ginapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// HEALTH PROBES
+// ============================================================
+// Kubernetes asks three different questions, each with its own endpoint:
+//
+//   GET /livez    - is the process healthy, or should it be restarted?
+//   GET /readyz   - should it receive traffic right now?
+//   GET /startupz - has it finished starting? (liveness waits for this)
+//
+// Each endpoint runs the named checks registered for its probe, in
+// parallel and with HealthCheckTimeout each, and answers 200 if all
+// pass or 503 otherwise. ?verbose lists every check with its result and
+// ?exclude=<name> (repeatable) skips checks, as in kube-apiserver.
+//
+// Readiness fails until MarkStarted is called and again after
+// BeginShutdown, so a pod stops receiving traffic before the server
+// stops accepting connections (see cmd/ginapp).
+// ============================================================
+
+// Probe selects the endpoints that run a check; combine with |
+type Probe int
+
+const (
+	Liveness Probe = 1 << iota
+	Readiness
+	Startup
+)
+
+// HealthChecker reports a problem by returning an error. It should give
+// up when ctx is done.
+type HealthChecker func(ctx context.Context) error
+
+// Health settings
+var (
+	HealthCheckTimeout        = 2 * time.Second
+	MinFreeDiskBytes   uint64 = 100 << 20 // 100 MiB for cover images
+)
+
+// HealthCheckResult - outcome of one check in verbose output
+type HealthCheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// healthCheck is a registered check
+type healthCheck struct {
+	name   string
+	probes Probe
+	check  HealthChecker
+}
+
+// Health state
+var (
+	healthChecks = defaultHealthChecks()
+	healthMu     sync.RWMutex
+
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+)
+
+var (
+	errNotStarted   = errors.New("still starting")
+	errShuttingDown = errors.New("shutting down")
+
+	errDiskFreeUnsupported = errors.New("free disk space is not available on this platform")
+)
+
+// defaultHealthChecks are the checks every server has
+func defaultHealthChecks() []healthCheck {
+	return []healthCheck{
+		{name: "ping", probes: Liveness, check: func(context.Context) error { return nil }},
+		{name: "workers", probes: Liveness, check: checkWorkers},
+		{name: "started", probes: Startup | Readiness, check: checkStarted},
+		{name: "store", probes: Readiness, check: checkStore},
+		{name: "disk", probes: Readiness, check: checkDiskSpace},
+		{name: "shutdown", probes: Readiness, check: checkShutdown},
+	}
+}
+
+// RegisterHealthCheck adds a check to the given probes, replacing any
+// check of the same name
+func RegisterHealthCheck(name string, probes Probe, check HealthChecker) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	for i, hc := range healthChecks {
+		if hc.name == name {
+			healthChecks[i] = healthCheck{name: name, probes: probes, check: check}
+			return
+		}
+	}
+	healthChecks = append(healthChecks, healthCheck{name: name, probes: probes, check: check})
+}
+
+// MarkStarted tells the probes that startup has finished
+func MarkStarted() {
+	started.Store(true)
+}
+
+// BeginShutdown makes readiness fail so load balancers stop sending
+// traffic. Call it before shutting the HTTP server down.
+func BeginShutdown() {
+	shuttingDown.Store(true)
+}
+
+// ============================================================
+// BUILT-IN CHECKS
+// ============================================================
+
+func checkStarted(context.Context) error {
+	if !started.Load() {
+		return errNotStarted
+	}
+	return nil
+}
+
+func checkShutdown(context.Context) error {
+	if shuttingDown.Load() {
+		return errShuttingDown
+	}
+	return nil
+}
+
+// checkStore verifies the default catalog can be read, i.e. no writer
+// has been holding its lock for the whole timeout
+func checkStore(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cat := defaultTenant().catalog
+		cat.booksMu.RLock()
+		cat.booksMu.RUnlock()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("catalog is locked")
+	}
+}
+
+// checkDiskSpace verifies the cover storage has MinFreeDiskBytes free.
+// The directory is created lazily, so the nearest existing parent is
+// checked until then.
+func checkDiskSpace(context.Context) error {
+	dir := CoverStorageDir
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	free, err := diskFree(dir)
+	if errors.Is(err, errDiskFreeUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if free < MinFreeDiskBytes {
+		return fmt.Errorf("%d bytes free in %s, need %d", free, dir, MinFreeDiskBytes)
+	}
+	return nil
+}
+
+// ============================================================
+// RUNNING CHECKS
+// ============================================================
+
+// runHealthChecks runs the checks of a probe in parallel and returns
+// their results in registration order
+func runHealthChecks(ctx context.Context, probe Probe, exclude []string) []HealthCheckResult {
+	healthMu.RLock()
+	var checks []healthCheck
+	for _, hc := range healthChecks {
+		if hc.probes&probe != 0 && !containsString(exclude, hc.name) {
+			checks = append(checks, hc)
+		}
+	}
+	healthMu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, hc := range checks {
+		wg.Add(1)
+		go func(i int, hc healthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := hc.check(checkCtx)
+			results[i] = HealthCheckResult{
+				Name:       hc.name,
+				Status:     "ok",
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Status = "failed"
+				results[i].Error = err.Error()
+			}
+		}(i, hc)
+	}
+	wg.Wait()
+	return results
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+// ProbeHandler - GET /livez, /readyz and /startupz (?verbose&exclude=name)
+func ProbeHandler(probe Probe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		results := runHealthChecks(c.Request.Context(), probe, c.QueryArray("exclude"))
+
+		var failed []string
+		for _, r := range results {
+			if r.Status != "ok" {
+				failed = append(failed, r.Name)
+			}
+		}
+
+		status, body := http.StatusOK, gin.H{"status": "ok"}
+		if len(failed) > 0 {
+			status, body = http.StatusServiceUnavailable, gin.H{
+				"status": "failed",
+				"failed": failed,
+			}
+		}
+		if _, verbose := c.GetQuery("verbose"); verbose {
+			body["checks"] = results
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(status, body)
+	}
+}
@@ -0,0 +1,177 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetHealth restores the default checks and a started, running server.
+// The disk check is relaxed so small test machines pass.
+func resetHealth(t *testing.T) {
+	t.Helper()
+
+	oldMinFree := MinFreeDiskBytes
+	MinFreeDiskBytes = 0
+
+	healthMu.Lock()
+	healthChecks = defaultHealthChecks()
+	healthMu.Unlock()
+	started.Store(true)
+	shuttingDown.Store(false)
+
+	t.Cleanup(func() {
+		MinFreeDiskBytes = oldMinFree
+		healthMu.Lock()
+		healthChecks = defaultHealthChecks()
+		healthMu.Unlock()
+		started.Store(false)
+		shuttingDown.Store(false)
+	})
+}
+
+// probe calls a probe endpoint and decodes the response
+func probe(t *testing.T, path string) (int, map[string]interface{}) {
+	t.Helper()
+
+	router := setupTestRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return w.Code, response
+}
+
+func TestProbes_StartupAndShutdown(t *testing.T) {
+	resetBooks()
+	resetHealth(t)
+	started.Store(false)
+
+	tests := []struct {
+		name           string
+		setup          func()
+		path           string
+		expectedStatus int
+	}{
+		{name: "starting: startup", path: "/startupz", expectedStatus: http.StatusServiceUnavailable},
+		{name: "starting: ready", path: "/readyz", expectedStatus: http.StatusServiceUnavailable},
+		{name: "starting: live", path: "/livez", expectedStatus: http.StatusOK},
+		{name: "started: startup", setup: MarkStarted, path: "/startupz", expectedStatus: http.StatusOK},
+		{name: "started: ready", path: "/readyz", expectedStatus: http.StatusOK},
+		{name: "shutting down: ready", setup: BeginShutdown, path: "/readyz", expectedStatus: http.StatusServiceUnavailable},
+		{name: "shutting down: live", path: "/livez", expectedStatus: http.StatusOK},
+		{name: "shutting down: excluded", path: "/readyz?exclude=shutdown", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if tt.setup != nil {
+			tt.setup()
+		}
+		if status, response := probe(t, tt.path); status != tt.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d: %v", tt.name, tt.expectedStatus, status, response)
+		}
+	}
+}
+
+func TestProbes_Verbose(t *testing.T) {
+	resetBooks()
+	resetHealth(t)
+	BeginShutdown()
+
+	status, response := probe(t, "/readyz?verbose")
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, status)
+	}
+
+	failed, _ := response["failed"].([]interface{})
+	if len(failed) != 1 || failed[0] != "shutdown" {
+		t.Errorf("Expected only shutdown to fail, got %v", response["failed"])
+	}
+
+	checks, _ := response["checks"].([]interface{})
+	var names []string
+	for _, check := range checks {
+		names = append(names, check.(map[string]interface{})["name"].(string))
+	}
+	if strings.Join(names, ",") != "started,store,disk,shutdown" {
+		t.Errorf("Unexpected readiness checks %v", names)
+	}
+
+	// Without ?verbose only the summary is returned
+	if _, response := probe(t, "/readyz"); response["checks"] != nil {
+		t.Errorf("Expected no check details, got %v", response)
+	}
+}
+
+func TestProbes_FailingChecks(t *testing.T) {
+	resetBooks()
+	resetHealth(t)
+
+	RegisterHealthCheck("broken", Liveness, func(context.Context) error { return errors.New("boom") })
+	if status, _ := probe(t, "/livez"); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected /livez to fail, got %d", status)
+	}
+	if status, response := probe(t, "/health"); status != http.StatusServiceUnavailable || response["status"] != "failed" {
+		t.Errorf("Expected /health to fail, got %d: %v", status, response)
+	}
+
+	// Re-registering replaces the check
+	RegisterHealthCheck("broken", Liveness, func(context.Context) error { return nil })
+	if status, _ := probe(t, "/livez"); status != http.StatusOK {
+		t.Errorf("Expected /livez to pass, got %d", status)
+	}
+
+	MinFreeDiskBytes = math.MaxUint64
+	if err := checkDiskSpace(context.Background()); err == nil {
+		t.Error("Expected disk check to fail")
+	}
+}
+
+func TestProbes_StoreLocked(t *testing.T) {
+	resetBooks()
+	resetHealth(t)
+	defer func(old time.Duration) { HealthCheckTimeout = old }(HealthCheckTimeout)
+	HealthCheckTimeout = 20 * time.Millisecond
+
+	cat := defaultTenant().catalog
+	cat.booksMu.Lock()
+	status, response := probe(t, "/readyz")
+	cat.booksMu.Unlock()
+
+	failed, _ := response["failed"].([]interface{})
+	if status != http.StatusServiceUnavailable || len(failed) != 1 || failed[0] != "store" {
+		t.Errorf("Expected store check to fail, got %d: %v", status, response)
+	}
+}
+
+func TestCheckWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	runWorker(ctx, "healthy", 5*time.Millisecond, func(time.Time) {})
+	runWorker(ctx, "stuck", 5*time.Millisecond, func(time.Time) { <-release })
+	defer close(release)
+	defer func() {
+		workersMu.Lock()
+		delete(workers, "healthy")
+		delete(workers, "stuck")
+		workersMu.Unlock()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	err := checkWorkers(ctx)
+	if err == nil || err.Error() != "stalled: stuck" {
+		t.Errorf("Expected only the stuck worker to stall, got %v", err)
+	}
+}
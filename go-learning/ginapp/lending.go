@@ -0,0 +1,770 @@
+package ginapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-learning/algorithms"
+)
+
+// ============================================================
+// LENDING
+// ============================================================
+// The catalog lends out physical copies of its books:
+//   PUT    /api/v1/books/:id/copies        how many copies the library owns
+//   GET    /api/v1/books/:id/availability
+//   POST   /api/v1/books/:id/checkout      borrow a copy (auth)
+//   POST   /api/v1/books/:id/return        give it back (auth)
+//   GET    /api/v1/books/:id/waitlist
+//   POST   /api/v1/books/:id/waitlist      wait for a copy (auth)
+//   DELETE /api/v1/books/:id/waitlist      stop waiting (auth)
+//   GET    /api/v1/loans                   the caller's loans (auth)
+//   GET    /api/v1/admin/loans             every loan, ?overdue=true
+//
+// A loan is due LoanPeriod after checkout. Users may hold at most
+// MaxLoansPerUser loans and cannot borrow while one is overdue.
+//
+// When no copy is free, users join the book's waitlist. It is a
+// priority queue like algorithms.PriorityQueue: a higher priority goes
+// first and equal priorities are served in request order. Priorities
+// are set by admins; users join with 0. A returned
+// copy is reserved for the head of the queue for ReservationWindow;
+// reservations not picked up in time pass the copy to the next in line.
+// ============================================================
+
+// Lending settings; tests may override them
+var (
+	LoanPeriod               = 14 * 24 * time.Hour
+	MaxLoansPerUser          = 5
+	ReservationWindow        = 48 * time.Hour
+	DefaultCopies            = 1
+	ReservationSweepInterval = time.Minute
+)
+
+// Hold statuses
+const (
+	HoldWaiting  = "waiting"
+	HoldReserved = "reserved"
+)
+
+// Loan - a copy of a book lent to a user
+type Loan struct {
+	ID           int        `json:"id"`
+	BookID       int        `json:"book_id"`
+	User         string     `json:"user"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty"`
+	Overdue      bool       `json:"overdue"` // computed when read
+}
+
+func (l *Loan) active() bool {
+	return l.ReturnedAt == nil
+}
+
+func (l *Loan) overdue(now time.Time) bool {
+	return l.active() && now.After(l.DueAt)
+}
+
+// Hold - a user's place in a book's waitlist. A reserved hold has a
+// copy set aside until ReservedUntil.
+type Hold struct {
+	ID            int        `json:"id"`
+	BookID        int        `json:"book_id"`
+	User          string     `json:"user"`
+	Priority      int        `json:"priority"`
+	RequestedAt   time.Time  `json:"requested_at"`
+	Status        string     `json:"status"`
+	ReservedUntil *time.Time `json:"reserved_until,omitempty"`
+	Position      int        `json:"position,omitempty"` // 1 is next; computed when read
+}
+
+// holdBefore orders a waitlist: higher priority first, then oldest
+// request, then the order holds were created in
+func holdBefore(a, b *Hold) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.RequestedAt.Equal(b.RequestedAt) {
+		return a.RequestedAt.Before(b.RequestedAt)
+	}
+	return a.ID < b.ID
+}
+
+// Availability - copy counts of one book
+type Availability struct {
+	BookID    int `json:"book_id"`
+	Copies    int `json:"copies"`
+	OnLoan    int `json:"on_loan"`
+	Reserved  int `json:"reserved"`
+	Available int `json:"available"`
+	Waiting   int `json:"waiting"`
+}
+
+// SetCopiesInput - input for PUT /api/v1/books/:id/copies
+type SetCopiesInput struct {
+	Copies *int `json:"copies" binding:"required,min=0,max=1000"`
+}
+
+// JoinWaitlistInput - optional body of POST /api/v1/books/:id/waitlist.
+// Only admins may set a priority; everyone else waits with 0.
+type JoinWaitlistInput struct {
+	Priority int `json:"priority" binding:"min=0,max=10"`
+}
+
+var (
+	errNoCopyAvailable = errors.New("no copy available")
+	errAlreadyBorrowed = errors.New("user already borrowed this book")
+	errLoanLimit       = errors.New("loan limit reached")
+	errOverdueLoans    = errors.New("user has overdue loans")
+	errLoanNotFound    = errors.New("no active loan of this book")
+	errCopyAvailable   = errors.New("a copy is available")
+	errAlreadyWaiting  = errors.New("user is already on the waitlist")
+	errHoldNotFound    = errors.New("user is not on the waitlist")
+	errCopiesInUse     = errors.New("copies are on loan or reserved")
+	errBookOnLoan      = errors.New("book has copies on loan")
+)
+
+// ============================================================
+// CATALOG METHODS
+// ============================================================
+// Loans and holds live in the catalog next to the books and share
+// booksMu. Loans are kept after return as the lending history.
+
+// bookCounts returns a book's copies, active loans and reserved holds.
+// Callers must hold booksMu.
+func (cat *catalog) bookCounts(bookID int) (copies, onLoan, reserved int) {
+	copies, set := cat.copies[bookID]
+	if !set {
+		copies = DefaultCopies
+	}
+	for _, l := range cat.loans {
+		if l.BookID == bookID && l.active() {
+			onLoan++
+		}
+	}
+	for _, h := range cat.holds {
+		if h.BookID == bookID && h.Status == HoldReserved {
+			reserved++
+		}
+	}
+	return copies, onLoan, reserved
+}
+
+// availabilityLocked - availability without locking
+func (cat *catalog) availabilityLocked(bookID int) Availability {
+	copies, onLoan, reserved := cat.bookCounts(bookID)
+	a := Availability{
+		BookID:    bookID,
+		Copies:    copies,
+		OnLoan:    onLoan,
+		Reserved:  reserved,
+		Available: max(copies-onLoan-reserved, 0),
+	}
+	if queue := cat.waitlists[bookID]; queue != nil {
+		a.Waiting = queue.Size()
+	}
+	return a
+}
+
+// fillReservations reserves free copies for the head of the waitlist.
+// Callers must hold booksMu for writing.
+func (cat *catalog) fillReservations(bookID int, now time.Time) {
+	queue := cat.waitlists[bookID]
+	for queue != nil && !queue.IsEmpty() && cat.availabilityLocked(bookID).Available > 0 {
+		hold, _ := queue.Pop()
+		until := now.Add(ReservationWindow)
+		hold.Status = HoldReserved
+		hold.ReservedUntil = &until
+	}
+	if queue != nil && queue.IsEmpty() {
+		delete(cat.waitlists, bookID)
+	}
+}
+
+// expireLocked drops reservations not picked up in time, handing their
+// copies on. It returns the number of reservations dropped. Callers must
+// hold booksMu for writing.
+func (cat *catalog) expireLocked(now time.Time) int {
+	books := make(map[int]bool)
+	expired := 0
+	for id, h := range cat.holds {
+		if h.Status == HoldReserved && now.After(*h.ReservedUntil) {
+			delete(cat.holds, id)
+			books[h.BookID] = true
+			expired++
+		}
+	}
+	for bookID := range books {
+		cat.fillReservations(bookID, now)
+	}
+	return expired
+}
+
+// expireReservations is expireLocked for the background worker
+func (cat *catalog) expireReservations(now time.Time) int {
+	cat.booksMu.Lock()
+	defer cat.booksMu.Unlock()
+
+	return cat.expireLocked(now)
+}
+
+// findHold returns a user's hold on a book, or nil. Callers must hold booksMu.
+func (cat *catalog) findHold(bookID int, user string) *Hold {
+	for _, h := range cat.holds {
+		if h.BookID == bookID && h.User == user {
+			return h
+		}
+	}
+	return nil
+}
+
+// findLoan returns a user's active loan of a book, or nil. Callers must
+// hold booksMu.
+func (cat *catalog) findLoan(bookID int, user string) *Loan {
+	for _, l := range cat.loans {
+		if l.BookID == bookID && l.User == user && l.active() {
+			return l
+		}
+	}
+	return nil
+}
+
+// dropHold removes a hold and its place in the waitlist. Callers must
+// hold booksMu for writing.
+func (cat *catalog) dropHold(hold *Hold) {
+	delete(cat.holds, hold.ID)
+	if queue := cat.waitlists[hold.BookID]; queue != nil {
+		queue.RemoveFunc(func(h *Hold) bool { return h == hold })
+		if queue.IsEmpty() {
+			delete(cat.waitlists, hold.BookID)
+		}
+	}
+}
+
+// availability returns the copy counts of a book
+func (cat *catalog) availability(ctx context.Context, bookID int) (Availability, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return Availability{}, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return Availability{}, errBookNotFound
+	}
+	return cat.availabilityLocked(bookID), nil
+}
+
+// setCopies changes how many copies of a book the library owns. Copies
+// on loan or reserved cannot be removed; new ones go to the waitlist.
+func (cat *catalog) setCopies(ctx context.Context, bookID, copies int, now time.Time) (Availability, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Availability{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return Availability{}, errBookNotFound
+	}
+	cat.expireLocked(now)
+	if a := cat.availabilityLocked(bookID); copies < a.OnLoan+a.Reserved {
+		return Availability{}, errCopiesInUse
+	}
+
+	cat.copies[bookID] = copies
+	cat.fillReservations(bookID, now)
+	return cat.availabilityLocked(bookID), nil
+}
+
+// checkout lends a copy of a book to user, using their reservation if
+// they have one
+func (cat *catalog) checkout(ctx context.Context, bookID int, user string, now time.Time) (Loan, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Loan{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return Loan{}, errBookNotFound
+	}
+	if cat.findLoan(bookID, user) != nil {
+		return Loan{}, errAlreadyBorrowed
+	}
+	active := 0
+	for _, l := range cat.loans {
+		if l.User != user {
+			continue
+		}
+		if l.overdue(now) {
+			return Loan{}, errOverdueLoans
+		}
+		if l.active() {
+			active++
+		}
+	}
+	if active >= MaxLoansPerUser {
+		return Loan{}, errLoanLimit
+	}
+
+	cat.expireLocked(now)
+	hold := cat.findHold(bookID, user)
+	if (hold == nil || hold.Status != HoldReserved) && cat.availabilityLocked(bookID).Available == 0 {
+		return Loan{}, errNoCopyAvailable
+	}
+	if hold != nil {
+		cat.dropHold(hold)
+	}
+
+	loan := &Loan{
+		ID:           cat.loanID,
+		BookID:       bookID,
+		User:         user,
+		CheckedOutAt: now,
+		DueAt:        now.Add(LoanPeriod),
+	}
+	cat.loans[loan.ID] = loan
+	cat.loanID++
+	return *loan, nil
+}
+
+// returnBook ends user's loan of a book and reserves the copy for the
+// next person waiting. Loans of deleted books can still be returned.
+func (cat *catalog) returnBook(ctx context.Context, bookID int, user string, now time.Time) (Loan, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Loan{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	loan := cat.findLoan(bookID, user)
+	if loan == nil {
+		return Loan{}, errLoanNotFound
+	}
+	returned := now
+	loan.ReturnedAt = &returned
+
+	cat.expireLocked(now)
+	cat.fillReservations(bookID, now)
+	return *loan, nil
+}
+
+// joinWaitlist queues user for a book that has no free copy
+func (cat *catalog) joinWaitlist(ctx context.Context, bookID int, user string, priority int, now time.Time) (Hold, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Hold{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return Hold{}, errBookNotFound
+	}
+	cat.expireLocked(now)
+	if cat.findHold(bookID, user) != nil {
+		return Hold{}, errAlreadyWaiting
+	}
+	if cat.findLoan(bookID, user) != nil {
+		return Hold{}, errAlreadyBorrowed
+	}
+	if cat.availabilityLocked(bookID).Available > 0 {
+		return Hold{}, errCopyAvailable
+	}
+
+	hold := &Hold{
+		ID:          cat.holdID,
+		BookID:      bookID,
+		User:        user,
+		Priority:    priority,
+		RequestedAt: now,
+		Status:      HoldWaiting,
+	}
+	cat.holds[hold.ID] = hold
+	cat.holdID++
+
+	queue := cat.waitlists[bookID]
+	if queue == nil {
+		queue = algorithms.NewPriorityQueueFunc(holdBefore)
+		cat.waitlists[bookID] = queue
+	}
+	queue.Push(hold)
+	return cat.positioned(hold), nil
+}
+
+// leaveWaitlist drops user's hold on a book. A reserved copy goes to
+// the next person waiting.
+func (cat *catalog) leaveWaitlist(ctx context.Context, bookID int, user string, now time.Time) error {
+	if err := cat.lock(ctx); err != nil {
+		return err
+	}
+	defer cat.booksMu.Unlock()
+
+	hold := cat.findHold(bookID, user)
+	if hold == nil {
+		return errHoldNotFound
+	}
+	cat.dropHold(hold)
+	cat.fillReservations(bookID, now)
+	return nil
+}
+
+// waitlist returns a book's holds in the order they are served:
+// reservations first, then the queue
+func (cat *catalog) waitlist(ctx context.Context, bookID int) ([]Hold, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return nil, errBookNotFound
+	}
+
+	holds := make([]Hold, 0)
+	for _, h := range cat.holds {
+		if h.BookID == bookID && h.Status == HoldReserved {
+			holds = append(holds, *h)
+		}
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i].ReservedUntil.Before(*holds[j].ReservedUntil) })
+
+	if queue := cat.waitlists[bookID]; queue != nil {
+		for i, h := range queue.Sorted() {
+			hold := *h
+			hold.Position = i + 1
+			holds = append(holds, hold)
+		}
+	}
+	return holds, nil
+}
+
+// positioned returns a copy of a hold with its waitlist position.
+// Callers must hold booksMu.
+func (cat *catalog) positioned(hold *Hold) Hold {
+	result := *hold
+	if queue := cat.waitlists[hold.BookID]; queue != nil && hold.Status == HoldWaiting {
+		for i, h := range queue.Sorted() {
+			if h == hold {
+				result.Position = i + 1
+			}
+		}
+	}
+	return result
+}
+
+// listLoans returns loans, newest first, for which keep returns true
+func (cat *catalog) listLoans(ctx context.Context, now time.Time, keep func(*Loan) bool) ([]Loan, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	loans := make([]Loan, 0)
+	for _, l := range cat.loans {
+		if keep(l) {
+			loan := *l
+			loan.Overdue = l.overdue(now)
+			loans = append(loans, loan)
+		}
+	}
+	sort.Slice(loans, func(i, j int) bool { return loans[i].ID > loans[j].ID })
+	return loans, nil
+}
+
+// bookLending is the lending state removeLocked drops with a book
+type bookLending struct {
+	copies    int
+	hasCopies bool
+	holds     []*Hold
+	waitlist  *algorithms.PriorityQueueFunc[*Hold]
+}
+
+// lendingOf captures a book's lending state. Callers must hold booksMu.
+func (cat *catalog) lendingOf(bookID int) bookLending {
+	l := bookLending{waitlist: cat.waitlists[bookID]}
+	l.copies, l.hasCopies = cat.copies[bookID]
+	for _, h := range cat.holds {
+		if h.BookID == bookID {
+			l.holds = append(l.holds, h)
+		}
+	}
+	return l
+}
+
+// dropLending forgets a book's copies and holds. Callers must hold
+// booksMu for writing.
+func (cat *catalog) dropLending(bookID int) {
+	delete(cat.copies, bookID)
+	for id, h := range cat.holds {
+		if h.BookID == bookID {
+			delete(cat.holds, id)
+		}
+	}
+	delete(cat.waitlists, bookID)
+}
+
+// restoreLending puts back what lendingOf captured. Callers must hold
+// booksMu for writing.
+func (cat *catalog) restoreLending(bookID int, l bookLending) {
+	if l.hasCopies {
+		cat.copies[bookID] = l.copies
+	}
+	for _, h := range l.holds {
+		cat.holds[h.ID] = h
+	}
+	if l.waitlist != nil {
+		cat.waitlists[bookID] = l.waitlist
+	}
+}
+
+// pruneLending drops copies and holds of books that no longer exist,
+// e.g. after a restore. Loans are history and stay. Callers must hold
+// booksMu for writing.
+func (cat *catalog) pruneLending() {
+	stale := make(map[int]bool)
+	for id := range cat.copies {
+		stale[id] = true
+	}
+	for _, h := range cat.holds {
+		stale[h.BookID] = true
+	}
+	for id := range stale {
+		if _, exists := cat.books.get(id); !exists {
+			cat.dropLending(id)
+		}
+	}
+}
+
+// expireAllReservations sweeps every tenant's reservations
+func expireAllReservations(now time.Time) {
+	tenantsMu.RLock()
+	list := make([]*Tenant, 0, len(tenants))
+	for _, t := range tenants {
+		list = append(list, t)
+	}
+	tenantsMu.RUnlock()
+
+	for _, t := range list {
+		t.catalog.expireReservations(now)
+	}
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+// lendingError writes the response for a failed lending call
+func lendingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errBookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	case errors.Is(err, errLoanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not borrowed this book"})
+	case errors.Is(err, errHoldNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not on the waitlist for this book"})
+	case errors.Is(err, errNoCopyAvailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "No copy available, join the waitlist instead"})
+	case errors.Is(err, errAlreadyBorrowed):
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already borrowed this book"})
+	case errors.Is(err, errCopyAvailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "A copy is available, check it out instead"})
+	case errors.Is(err, errAlreadyWaiting):
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already on the waitlist for this book"})
+	case errors.Is(err, errCopiesInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove copies that are on loan or reserved"})
+	case errors.Is(err, errLoanLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Loan limit reached"})
+	case errors.Is(err, errOverdueLoans):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Return your overdue books first"})
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		timeoutError(c, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// bindBookID binds the :id parameter, answering 400 if it is invalid
+func bindBookID(c *gin.Context) (int, bool) {
+	var uri reviewURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return 0, false
+	}
+	return uri.ID, true
+}
+
+// GetAvailability - GET /api/v1/books/:id/availability
+func GetAvailability(c *gin.Context) {
+	id, ok := bindBookID(c)
+	if !ok {
+		return
+	}
+
+	availability, err := catalogFrom(c).availability(c.Request.Context(), id)
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": availability})
+}
+
+// SetCopies - PUT /api/v1/books/:id/copies
+func SetCopies(c *gin.Context) {
+	id, ok := bindBookID(c)
+	if !ok {
+		return
+	}
+
+	var input SetCopiesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	availability, err := catalogFrom(c).setCopies(c.Request.Context(), id, *input.Copies, time.Now())
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": availability})
+}
+
+// CheckoutBook - POST /api/v1/books/:id/checkout
+func CheckoutBook(c *gin.Context) {
+	id, ok := bindBookID(c)
+	if !ok {
+		return
+	}
+
+	loan, err := catalogFrom(c).checkout(c.Request.Context(), id, c.GetString("user"), time.Now())
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": loan})
+}
+
+// ReturnBook - POST /api/v1/books/:id/return
+func ReturnBook(c *gin.Context) {
+	id, ok := bindBookID(c)
+	if !ok {
+		return
+	}
+
+	loan, err := catalogFrom(c).returnBook(c.Request.Context(), id, c.GetString("user"), time.Now())
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": loan})
+}
+
+// GetWaitlist - GET /api/v1/books/:id/waitlist
+func GetWaitlist(c *gin.Context) {
+	id, ok := bindBookID(c)
+	if !ok {
+		return
+	}
+
+	holds, err := catalogFrom(c).waitlist(c.Request.Context(), id)
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  holds,
+		"count": len(holds),
+	})
+}
+
+// JoinWaitlist - POST /api/v1/books/:id/waitlist
+func JoinWaitlist(c *gin.Context) {
+	id, ok := bindBookID(c)
+	if !ok {
+		return
+	}
+
+	var input JoinWaitlistInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			bindError(c, err)
+			return
+		}
+	}
+	if input.Priority != 0 && !scopeAllowed(c, ScopeAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins may set a waitlist priority"})
+		return
+	}
+
+	hold, err := catalogFrom(c).joinWaitlist(c.Request.Context(), id, c.GetString("user"), input.Priority, time.Now())
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": hold})
+}
+
+// LeaveWaitlist - DELETE /api/v1/books/:id/waitlist
+func LeaveWaitlist(c *gin.Context) {
+	id, ok := bindBookID(c)
+	if !ok {
+		return
+	}
+
+	if err := catalogFrom(c).leaveWaitlist(c.Request.Context(), id, c.GetString("user"), time.Now()); err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Left the waitlist"})
+}
+
+// GetMyLoans - GET /api/v1/loans?status=active|returned|overdue
+func GetMyLoans(c *gin.Context) {
+	user := c.GetString("user")
+	now := time.Now()
+
+	var keep func(*Loan) bool
+	switch status := c.Query("status"); status {
+	case "":
+		keep = func(l *Loan) bool { return l.User == user }
+	case "active":
+		keep = func(l *Loan) bool { return l.User == user && l.active() }
+	case "returned":
+		keep = func(l *Loan) bool { return l.User == user && !l.active() }
+	case "overdue":
+		keep = func(l *Loan) bool { return l.User == user && l.overdue(now) }
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of: active, returned, overdue"})
+		return
+	}
+
+	loans, err := catalogFrom(c).listLoans(c.Request.Context(), now, keep)
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  loans,
+		"count": len(loans),
+	})
+}
+
+// GetAllLoans - GET /api/v1/admin/loans?overdue=true, active loans
+func GetAllLoans(c *gin.Context) {
+	now := time.Now()
+	overdueOnly := c.Query("overdue") == "true"
+
+	loans, err := catalogFrom(c).listLoans(c.Request.Context(), now, func(l *Loan) bool {
+		return l.active() && (!overdueOnly || l.overdue(now))
+	})
+	if err != nil {
+		lendingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  loans,
+		"count": len(loans),
+	})
+}
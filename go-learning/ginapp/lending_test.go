@@ -0,0 +1,227 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitlistUsers lists the users of a book's waitlist in serving order
+func waitlistUsers(t *testing.T, cat *catalog, bookID int) string {
+	t.Helper()
+
+	holds, err := cat.waitlist(context.Background(), bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []string
+	for _, h := range holds {
+		users = append(users, h.User+":"+h.Status)
+	}
+	return strings.Join(users, ", ")
+}
+
+func TestCheckoutAndReturn(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+
+	availability := func() Availability {
+		w := apiRequest(router, "GET", "/api/v1/books/1/availability", "", "Authorization", "Bearer mytoken")
+		var response struct{ Data Availability }
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	if w := apiRequest(router, "PUT", "/api/v1/books/1/copies", `{"copies": 2}`, "Authorization", "Bearer mytoken"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w := apiRequest(router, "POST", "/api/v1/books/1/checkout", "", "Authorization", "Bearer mytoken")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var response struct{ Data Loan }
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if due := response.Data.DueAt.Sub(response.Data.CheckedOutAt); due != LoanPeriod || response.Data.User != "demo_user" {
+		t.Errorf("Unexpected loan: %+v", response.Data)
+	}
+	if a := availability(); a.Copies != 2 || a.OnLoan != 1 || a.Available != 1 {
+		t.Errorf("Unexpected availability after checkout: %+v", a)
+	}
+
+	if w := apiRequest(router, "POST", "/api/v1/books/1/checkout", "", "Authorization", "Bearer mytoken"); w.Code != http.StatusConflict {
+		t.Errorf("Expected second checkout to be %d, got %d", http.StatusConflict, w.Code)
+	}
+	if w := apiRequest(router, "PUT", "/api/v1/books/1/copies", `{"copies": 0}`, "Authorization", "Bearer mytoken"); w.Code != http.StatusConflict {
+		t.Errorf("Expected removing a lent copy to be %d, got %d", http.StatusConflict, w.Code)
+	}
+	if w := apiRequest(router, "DELETE", "/api/v1/books/1", "", "Authorization", "Bearer mytoken"); w.Code != http.StatusConflict {
+		t.Errorf("Expected deleting a lent book to be %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = apiRequest(router, "GET", "/api/v1/loans?status=active", "", "Authorization", "Bearer mytoken")
+	if !strings.Contains(w.Body.String(), `"count":1`) {
+		t.Errorf("Expected one active loan, got %s", w.Body.String())
+	}
+
+	if w := apiRequest(router, "POST", "/api/v1/books/1/return", "", "Authorization", "Bearer mytoken"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := apiRequest(router, "POST", "/api/v1/books/1/return", "", "Authorization", "Bearer mytoken"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected second return to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if a := availability(); a.OnLoan != 0 || a.Available != 2 {
+		t.Errorf("Unexpected availability after return: %+v", a)
+	}
+
+	w = apiRequest(router, "GET", "/api/v1/loans?status=returned", "", "Authorization", "Bearer mytoken")
+	if !strings.Contains(w.Body.String(), `"count":1`) {
+		t.Errorf("Expected one returned loan, got %s", w.Body.String())
+	}
+	if w := apiRequest(router, "DELETE", "/api/v1/books/1", "", "Authorization", "Bearer mytoken"); w.Code != http.StatusOK {
+		t.Errorf("Expected returned book to be deletable, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCheckout_Limits(t *testing.T) {
+	resetBooks()
+	defer func(old int) { MaxLoansPerUser = old }(MaxLoansPerUser)
+	MaxLoansPerUser = 2
+
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		cat.insert(ctx, Book{Title: "Book", Author: "A", Year: 2000})
+	}
+	now := time.Now()
+
+	cat.checkout(ctx, 1, "ann", now)
+	cat.checkout(ctx, 2, "ann", now)
+	if _, err := cat.checkout(ctx, 3, "ann", now); !errors.Is(err, errLoanLimit) {
+		t.Errorf("Expected loan limit, got %v", err)
+	}
+
+	cat.returnBook(ctx, 2, "ann", now)
+	later := now.Add(LoanPeriod + time.Hour)
+	if _, err := cat.checkout(ctx, 3, "ann", later); !errors.Is(err, errOverdueLoans) {
+		t.Errorf("Expected overdue loans to block checkout, got %v", err)
+	}
+
+	overdue, _ := cat.listLoans(ctx, later, func(l *Loan) bool { return l.overdue(later) })
+	if len(overdue) != 1 || overdue[0].BookID != 1 || !overdue[0].Overdue {
+		t.Errorf("Expected book 1 overdue, got %+v", overdue)
+	}
+}
+
+func TestWaitlist(t *testing.T) {
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.insert(ctx, Book{Title: "Popular", Author: "A", Year: 2020})
+	now := time.Now()
+
+	if _, err := cat.joinWaitlist(ctx, 1, "ann", 0, now); !errors.Is(err, errCopyAvailable) {
+		t.Errorf("Expected free copy to refuse the waitlist, got %v", err)
+	}
+	cat.checkout(ctx, 1, "ann", now)
+
+	// Same priority is first come, first served; higher priority jumps ahead
+	cat.joinWaitlist(ctx, 1, "bob", 0, now.Add(1*time.Minute))
+	cat.joinWaitlist(ctx, 1, "cat", 0, now.Add(2*time.Minute))
+	hold, _ := cat.joinWaitlist(ctx, 1, "dan", 5, now.Add(3*time.Minute))
+	if hold.Position != 1 {
+		t.Errorf("Expected priority hold first, got position %d", hold.Position)
+	}
+	if _, err := cat.joinWaitlist(ctx, 1, "bob", 0, now); !errors.Is(err, errAlreadyWaiting) {
+		t.Errorf("Expected duplicate hold to fail, got %v", err)
+	}
+	if got := waitlistUsers(t, cat, 1); got != "dan:waiting, bob:waiting, cat:waiting" {
+		t.Errorf("Unexpected waitlist: %s", got)
+	}
+
+	// A returned copy is reserved for the head of the queue only
+	cat.returnBook(ctx, 1, "ann", now.Add(time.Hour))
+	if got := waitlistUsers(t, cat, 1); got != "dan:reserved, bob:waiting, cat:waiting" {
+		t.Errorf("Unexpected waitlist after return: %s", got)
+	}
+	if _, err := cat.checkout(ctx, 1, "bob", now.Add(time.Hour)); !errors.Is(err, errNoCopyAvailable) {
+		t.Errorf("Expected reserved copy to be unavailable to others, got %v", err)
+	}
+
+	// An unclaimed reservation passes to the next in line
+	expiry := now.Add(time.Hour + ReservationWindow + time.Minute)
+	if n := cat.expireReservations(expiry); n != 1 {
+		t.Errorf("Expected 1 expired reservation, got %d", n)
+	}
+	if got := waitlistUsers(t, cat, 1); got != "bob:reserved, cat:waiting" {
+		t.Errorf("Unexpected waitlist after expiry: %s", got)
+	}
+
+	// Leaving with a reservation hands the copy on; checking out uses it
+	if err := cat.leaveWaitlist(ctx, 1, "bob", expiry); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cat.checkout(ctx, 1, "cat", expiry); err != nil {
+		t.Fatalf("Expected reserved checkout to succeed, got %v", err)
+	}
+	if a, _ := cat.availability(ctx, 1); a.OnLoan != 1 || a.Reserved != 0 || a.Waiting != 0 {
+		t.Errorf("Unexpected availability: %+v", a)
+	}
+}
+
+func TestJoinWaitlist_PriorityAdminOnly(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.insert(ctx, Book{Title: "Popular", Author: "A", Year: 2020})
+	cat.setCopies(ctx, 1, 0, time.Now())
+
+	// Users cannot jump the queue
+	if w := apiRequest(router, "POST", "/api/v1/books/1/waitlist", `{"priority": 5}`, "Authorization", "Bearer mytoken"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+	if w := apiRequest(router, "POST", "/api/v1/books/1/waitlist", "", "Authorization", "Bearer mytoken"); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	// Admins can
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/books/1/waitlist", strings.NewReader(`{"priority": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if got := waitlistUsers(t, cat, 1); got != testAdmin+":waiting, demo_user:waiting" {
+		t.Errorf("Unexpected waitlist: %s", got)
+	}
+}
+
+func TestWaitlist_BatchDeleteRollback(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.insert(ctx, Book{Title: "Popular", Author: "A", Year: 2020})
+	cat.setCopies(ctx, 1, 0, time.Now())
+	cat.joinWaitlist(ctx, 1, "ann", 0, time.Now())
+
+	w := apiRequest(router, "POST", "/api/v1/batch", `{"operations": [{"op": "delete", "id": 1}, {"op": "delete", "id": 99}]}`)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+	if got := waitlistUsers(t, cat, 1); got != "ann:waiting" {
+		t.Errorf("Expected the waitlist to be restored, got %q", got)
+	}
+	if a, _ := cat.availability(ctx, 1); a.Copies != 0 {
+		t.Errorf("Expected copies to be restored, got %+v", a)
+	}
+}
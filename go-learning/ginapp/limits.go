@@ -0,0 +1,159 @@
+package ginapp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// REQUEST LIMITS
+// ============================================================
+// Two middlewares bound the work a single request can cause:
+//
+//   - BodyLimitMiddleware caps the request body. Reading a body announced
+//     larger than the limit fails at once, without reading any of it;
+//     chunked bodies fail once they pass the limit. Either way the
+//     error is an *http.MaxBytesError and bindError turns it into 413.
+//     The declared length is only checked on the first read, when every
+//     nested limit has had its say.
+//   - TimeoutMiddleware puts a deadline on the request context. Catalog
+//     methods take that context and give up once it expires, so a slow
+//     request ends with 504 instead of holding locks for ever. A request
+//     whose context is cancelled early (client gone, server shutting
+//     down) gets 503.
+//
+// Handlers are not preempted: the deadline is honoured wherever the
+// context is passed on. Both middlewares can be nested; the innermost
+// group's limit wins, so routes that accept uploads can raise the
+// limits set for the whole API (see SetupRouter).
+// ============================================================
+
+// Limits used by SetupRouter
+var (
+	MaxRequestBodySize int64 = 1 << 20 // 1 MiB, JSON bodies of the API
+	RequestTimeout           = 5 * time.Second
+	UploadTimeout            = time.Minute // covers and catalog restores
+)
+
+// timeoutParentKey stores the context that existed before the first
+// TimeoutMiddleware, so nested timeouts replace instead of shorten it
+const timeoutParentKey = "timeout_parent"
+
+// limitedBody is a request body wrapped by BodyLimitMiddleware. It keeps
+// the original body so a nested limit can replace the outer one.
+type limitedBody struct {
+	io.ReadCloser
+	raw      io.ReadCloser
+	limit    int64
+	declared int64 // Content-Length, -1 if unknown
+	checked  bool
+}
+
+// Read refuses a body announced larger than the limit before reading it,
+// so an oversized upload is not read up to the limit first
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if !b.checked {
+		b.checked = true
+		if b.declared > b.limit {
+			return 0, &http.MaxBytesError{Limit: b.limit}
+		}
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// BodyLimitMiddleware - refuses request bodies larger than maxBytes with
+// 413. A limit of 0 or less lifts any limit set by an outer group. The
+// body is not checked until a handler reads it, so a nested group can
+// still raise the limit of an outer one.
+func BodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.Request.Body
+		if body, ok := raw.(*limitedBody); ok {
+			raw = body.raw
+		}
+		if raw == nil || raw == http.NoBody || maxBytes <= 0 {
+			if raw != nil {
+				c.Request.Body = raw
+			}
+			c.Next()
+			return
+		}
+
+		c.Request.Body = &limitedBody{
+			ReadCloser: http.MaxBytesReader(c.Writer, raw, maxBytes),
+			raw:        raw,
+			limit:      maxBytes,
+			declared:   c.Request.ContentLength,
+		}
+		c.Next()
+	}
+}
+
+// TimeoutMiddleware - gives the rest of the chain timeout to finish.
+// If the handler returns without responding after the deadline, the
+// middleware answers 504. A timeout of 0 or less lifts any deadline set
+// by an outer group.
+func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent := c.Request.Context()
+		if saved, exists := c.Get(timeoutParentKey); exists {
+			parent = saved.(context.Context)
+		} else {
+			c.Set(timeoutParentKey, parent)
+		}
+
+		if timeout <= 0 {
+			c.Request = c.Request.WithContext(parent)
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		// A nested TimeoutMiddleware replaced ctx and answered for it
+		if c.Request.Context() != ctx {
+			return
+		}
+		if err := ctx.Err(); err != nil && !c.Writer.Written() {
+			timeoutError(c, err)
+		}
+	}
+}
+
+// bodyTooLarge writes the 413 response
+func bodyTooLarge(c *gin.Context, maxBytes int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":     "Request body too large",
+		"max_bytes": maxBytes,
+	})
+}
+
+// bindError writes the response for a failed ShouldBindJSON: 413 if the
+// body hit the limit, 400 otherwise
+func bindError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		bodyTooLarge(c, tooLarge.Limit)
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// timeoutError writes the response for a request whose context ended:
+// 504 when its deadline passed, 503 when it was cancelled
+func timeoutError(c *gin.Context, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		return
+	}
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Request was cancelled"})
+}
@@ -0,0 +1,225 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	defer func(old int64) { MaxRequestBodySize = old }(MaxRequestBodySize)
+	MaxRequestBodySize = 64
+	router := setupTestRouter()
+	resetBooks()
+
+	small := `{"title":"Go","author":"A","year":2020}`
+	large := `{"title":"` + strings.Repeat("x", 100) + `","author":"A","year":2020}`
+
+	tests := []struct {
+		name           string
+		body           string
+		chunked        bool
+		expectedStatus int
+	}{
+		{name: "within limit", body: small, expectedStatus: http.StatusCreated},
+		{name: "announced too large", body: large, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked too large", body: large, chunked: true, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/books", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if count := defaultTenant().catalog.count(); count != 1 {
+		t.Errorf("Expected only the small book to be stored, got %d books", count)
+	}
+}
+
+func TestBodyLimitMiddleware_Nested(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/", BodyLimitMiddleware(10))
+	group.POST("/small", readBody)
+	group.POST("/large", BodyLimitMiddleware(100), readBody)
+
+	tests := []struct {
+		path           string
+		size           int
+		chunked        bool
+		expectedStatus int
+	}{
+		{path: "/small", size: 50, expectedStatus: http.StatusRequestEntityTooLarge},
+		{path: "/small", size: 50, chunked: true, expectedStatus: http.StatusRequestEntityTooLarge},
+		{path: "/large", size: 50, expectedStatus: http.StatusOK},
+		{path: "/large", size: 50, chunked: true, expectedStatus: http.StatusOK},
+		{path: "/large", size: 150, expectedStatus: http.StatusRequestEntityTooLarge},
+		{path: "/large", size: 150, chunked: true, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", tt.path, bytes.NewReader(make([]byte, tt.size)))
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s (%d bytes, chunked %v): expected status %d, got %d", tt.path, tt.size, tt.chunked, tt.expectedStatus, w.Code)
+		}
+	}
+}
+
+func TestBodyLimitMiddleware_UploadRoutes(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	if code := createTenantBook(router, "", "Covered"); code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, code)
+	}
+
+	// Uploads above the API-wide limit reach the upload handlers, which
+	// reject the content instead of the size
+	body := bytes.Repeat([]byte("x"), int(MaxRequestBodySize)+1024)
+	tests := []struct {
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{method: "PUT", path: "/api/v1/books/1/cover", expectedStatus: http.StatusUnsupportedMediaType},
+		{method: "POST", path: "/api/v1/admin/restore", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+adminToken(t))
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.expectedStatus, w.Code, w.Body.String())
+		}
+	}
+}
+
+// readBody reads the whole body, answering 413 if it hits the limit
+func readBody(c *gin.Context) {
+	if _, err := io.ReadAll(c.Request.Body); err != nil {
+		bindError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func TestTimeoutMiddleware_StoreCalls(t *testing.T) {
+	defer func(old time.Duration) { RequestTimeout = old }(RequestTimeout)
+	RequestTimeout = 20 * time.Millisecond
+	router := setupTestRouter()
+	resetBooks()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: "GET", path: "/api/v1/books"},
+		{method: "GET", path: "/api/v2/books/1"},
+		{method: "POST", path: "/api/v1/books", body: `{"title":"Late","author":"A","year":2020}`},
+	}
+
+	for _, tt := range tests {
+		// A long-running writer holds the catalog past the deadline;
+		// reads only wait on the book store, so it is held too
+		cat := defaultTenant().catalog
+		cat.booksMu.Lock()
+		cat.books.lockAll()
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cat.books.unlockAll()
+			cat.booksMu.Unlock()
+		}()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusGatewayTimeout {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, http.StatusGatewayTimeout, w.Code, w.Body.String())
+		}
+	}
+
+	// The timed-out insert must not have happened
+	if count := defaultTenant().catalog.count(); count != 0 {
+		t.Errorf("Expected no books after timed-out insert, got %d", count)
+	}
+}
+
+func TestTimeoutMiddleware_Cancelled(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/api/v1/books", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
+
+func TestTimeoutMiddleware_Nested(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/", TimeoutMiddleware(10*time.Millisecond))
+
+	// Waits for the deadline, then returns without responding
+	slow := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(40 * time.Millisecond):
+			c.Status(http.StatusOK)
+		}
+	}
+	group.GET("/short", slow)
+	group.GET("/long", TimeoutMiddleware(time.Second), slow)
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{path: "/short", expectedStatus: http.StatusGatewayTimeout},
+		{path: "/long", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.expectedStatus, w.Code)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package ginapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// REVIEWS AND RATINGS
+// ============================================================
+// Reviews are a sub-resource of books:
+//   /api/v1/books/:id/reviews[/:reviewId]
+// Anyone can read them; writing requires auth and only the author of
+// a review may change or delete it. Ratings are aggregated per book
+// and embedded in Book responses as average_rating / rating_count.
+// ============================================================
+
+// Review is one user's rating of a book
+type Review struct {
+	ID        int       `json:"id"`
+	BookID    int       `json:"book_id"`
+	User      string    `json:"user"`
+	Rating    int       `json:"rating"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateReviewInput - input for creating a review
+type CreateReviewInput struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Comment string `json:"comment,omitempty" binding:"max=2000"`
+}
+
+// UpdateReviewInput - input for updating a review (all fields optional)
+type UpdateReviewInput struct {
+	Rating  *int    `json:"rating,omitempty" binding:"omitempty,min=1,max=5"`
+	Comment *string `json:"comment,omitempty" binding:"omitempty,max=2000"`
+}
+
+// ratingTotals - running rating sum and count for one book
+type ratingTotals struct {
+	sum   int
+	count int
+}
+
+var (
+	errBookNotFound    = errors.New("book not found")
+	errReviewNotFound  = errors.New("review not found")
+	errDuplicateReview = errors.New("user already reviewed this book")
+	errNotReviewAuthor = errors.New("only the author can change a review")
+)
+
+// ============================================================
+// CATALOG METHODS
+// ============================================================
+
+// listReviews returns the reviews of a book, oldest first
+func (cat *catalog) listReviews(ctx context.Context, bookID int) ([]Review, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return nil, errBookNotFound
+	}
+
+	reviews := make([]Review, 0)
+	for _, r := range cat.reviews {
+		if r.BookID == bookID {
+			reviews = append(reviews, r)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].ID < reviews[j].ID })
+	return reviews, nil
+}
+
+// reviewCount returns the number of reviews across all books
+func (cat *catalog) reviewCount() int {
+	cat.booksMu.RLock()
+	defer cat.booksMu.RUnlock()
+
+	return len(cat.reviews)
+}
+
+// findReview looks up one review of a book
+func (cat *catalog) findReview(ctx context.Context, bookID, reviewID int) (Review, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return Review{}, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	return cat.lookupReview(bookID, reviewID)
+}
+
+// lookupReview - findReview without locking. Callers must hold booksMu.
+func (cat *catalog) lookupReview(bookID, reviewID int) (Review, error) {
+	if _, exists := cat.books.get(bookID); !exists {
+		return Review{}, errBookNotFound
+	}
+	review, exists := cat.reviews[reviewID]
+	if !exists || review.BookID != bookID {
+		return Review{}, errReviewNotFound
+	}
+	return review, nil
+}
+
+// addReview stores a new review; each user may review a book once
+func (cat *catalog) addReview(ctx context.Context, review Review) (Review, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Review{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	if _, exists := cat.books.get(review.BookID); !exists {
+		return Review{}, errBookNotFound
+	}
+	for _, r := range cat.reviews {
+		if r.BookID == review.BookID && r.User == review.User {
+			return Review{}, errDuplicateReview
+		}
+	}
+
+	review.ID = cat.reviewID
+	review.CreatedAt = time.Now()
+	review.UpdatedAt = review.CreatedAt
+	cat.reviews[review.ID] = review
+	cat.reviewID++
+
+	totals := cat.ratings[review.BookID]
+	totals.sum += review.Rating
+	totals.count++
+	cat.ratings[review.BookID] = totals
+	cat.redecorate(review.BookID)
+	return review, nil
+}
+
+// updateReview applies input to a review owned by user
+func (cat *catalog) updateReview(ctx context.Context, bookID, reviewID int, user string, input UpdateReviewInput) (Review, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Review{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	review, err := cat.lookupReview(bookID, reviewID)
+	if err != nil {
+		return Review{}, err
+	}
+	if review.User != user {
+		return Review{}, errNotReviewAuthor
+	}
+
+	if input.Rating != nil {
+		totals := cat.ratings[bookID]
+		totals.sum += *input.Rating - review.Rating
+		cat.ratings[bookID] = totals
+		cat.redecorate(bookID)
+		review.Rating = *input.Rating
+	}
+	if input.Comment != nil {
+		review.Comment = *input.Comment
+	}
+	review.UpdatedAt = time.Now()
+	cat.reviews[reviewID] = review
+	return review, nil
+}
+
+// deleteReview removes a review owned by user
+func (cat *catalog) deleteReview(ctx context.Context, bookID, reviewID int, user string) error {
+	if err := cat.lock(ctx); err != nil {
+		return err
+	}
+	defer cat.booksMu.Unlock()
+
+	review, err := cat.lookupReview(bookID, reviewID)
+	if err != nil {
+		return err
+	}
+	if review.User != user {
+		return errNotReviewAuthor
+	}
+
+	delete(cat.reviews, reviewID)
+	totals := cat.ratings[bookID]
+	totals.sum -= review.Rating
+	totals.count--
+	if totals.count == 0 {
+		delete(cat.ratings, bookID)
+	} else {
+		cat.ratings[bookID] = totals
+	}
+	cat.redecorate(bookID)
+	return nil
+}
+
+// ============================================================
+// SORTING
+// ============================================================
+
+// sortBooks orders books in place: "id" (default) or "rating"
+// (highest average first, ties broken by number of ratings)
+func sortBooks(bookList []Book, by string) error {
+	switch by {
+	case "", "id":
+		sort.Slice(bookList, func(i, j int) bool { return bookList[i].ID < bookList[j].ID })
+	case "rating":
+		sort.SliceStable(bookList, func(i, j int) bool {
+			a, b := bookList[i], bookList[j]
+			if a.AverageRating != b.AverageRating {
+				return a.AverageRating > b.AverageRating
+			}
+			if a.RatingCount != b.RatingCount {
+				return a.RatingCount > b.RatingCount
+			}
+			return a.ID < b.ID
+		})
+	default:
+		return errors.New("sort must be one of: id, rating")
+	}
+	return nil
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+// reviewURI - path parameters of review routes
+type reviewURI struct {
+	ID       int `uri:"id" binding:"required,min=1"`
+	ReviewID int `uri:"reviewId" binding:"omitempty,min=1"`
+}
+
+// reviewError maps catalog errors to responses
+func reviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errBookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	case errors.Is(err, errReviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	case errors.Is(err, errDuplicateReview):
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this book"})
+	case errors.Is(err, errNotReviewAuthor):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author of a review can change it"})
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		timeoutError(c, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetReviews - GET /api/v1/books/:id/reviews
+func GetReviews(c *gin.Context) {
+	var uri reviewURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	reviews, err := catalogFrom(c).listReviews(c.Request.Context(), uri.ID)
+	if err != nil {
+		reviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  reviews,
+		"count": len(reviews),
+	})
+}
+
+// GetReview - GET /api/v1/books/:id/reviews/:reviewId
+func GetReview(c *gin.Context) {
+	var uri reviewURI
+	if err := c.ShouldBindUri(&uri); err != nil || uri.ReviewID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book or review ID"})
+		return
+	}
+
+	review, err := catalogFrom(c).findReview(c.Request.Context(), uri.ID, uri.ReviewID)
+	if err != nil {
+		reviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": review})
+}
+
+// CreateReview - POST /api/v1/books/:id/reviews (requires auth)
+func CreateReview(c *gin.Context) {
+	var uri reviewURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	var input CreateReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	review, err := catalogFrom(c).addReview(c.Request.Context(), Review{
+		BookID:  uri.ID,
+		User:    c.GetString("user"),
+		Rating:  input.Rating,
+		Comment: input.Comment,
+	})
+	if err != nil {
+		reviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": review})
+}
+
+// UpdateReview - PUT /api/v1/books/:id/reviews/:reviewId (author only)
+func UpdateReview(c *gin.Context) {
+	var uri reviewURI
+	if err := c.ShouldBindUri(&uri); err != nil || uri.ReviewID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book or review ID"})
+		return
+	}
+
+	var input UpdateReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	review, err := catalogFrom(c).updateReview(c.Request.Context(), uri.ID, uri.ReviewID, c.GetString("user"), input)
+	if err != nil {
+		reviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": review})
+}
+
+// DeleteReview - DELETE /api/v1/books/:id/reviews/:reviewId (author only)
+func DeleteReview(c *gin.Context) {
+	var uri reviewURI
+	if err := c.ShouldBindUri(&uri); err != nil || uri.ReviewID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book or review ID"})
+		return
+	}
+
+	if err := catalogFrom(c).deleteReview(c.Request.Context(), uri.ID, uri.ReviewID, c.GetString("user")); err != nil {
+		reviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
@@ -0,0 +1,211 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// postReview creates a review as the authenticated demo user
+func postReview(router http.Handler, bookID string, rating int) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(CreateReviewInput{Rating: rating, Comment: "Nice"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/books/"+bookID+"/reviews", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer mytoken")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateReview(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+
+	w := postReview(router, "1", 4)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"]["user"] != "demo_user" {
+		t.Errorf("Expected review by demo_user, got %v", response["data"]["user"])
+	}
+
+	// A second review by the same user is rejected
+	if w := postReview(router, "1", 5); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Reviews of missing books are rejected
+	if w := postReview(router, "99", 5); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCreateReview_Validation(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+
+	for _, rating := range []int{0, 6, -1} {
+		if w := postReview(router, "1", rating); w.Code != http.StatusBadRequest {
+			t.Errorf("Rating %d: expected status %d, got %d", rating, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	// Writing requires auth
+	body, _ := json.Marshal(CreateReviewInput{Rating: 3})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/books/1/reviews", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestRatingsEmbeddedInBook(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+	cat.addReview(context.Background(), Review{BookID: 1, User: "alice", Rating: 5})
+	cat.addReview(context.Background(), Review{BookID: 1, User: "bob", Rating: 4})
+	cat.addReview(context.Background(), Review{BookID: 1, User: "carol", Rating: 4})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/books/1", nil)
+	router.ServeHTTP(w, req)
+
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"]["average_rating"] != 4.33 {
+		t.Errorf("Expected average_rating 4.33, got %v", response["data"]["average_rating"])
+	}
+	if response["data"]["rating_count"] != float64(3) {
+		t.Errorf("Expected rating_count 3, got %v", response["data"]["rating_count"])
+	}
+}
+
+func TestReviewOwnership(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+	cat.addReview(context.Background(), Review{BookID: 1, User: "someone_else", Rating: 2})
+	postReview(router, "1", 3) // review 2, by demo_user
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "update other's review", method: "PUT", path: "/api/v1/books/1/reviews/1", body: `{"rating":5}`, expectedStatus: http.StatusForbidden},
+		{name: "delete other's review", method: "DELETE", path: "/api/v1/books/1/reviews/1", expectedStatus: http.StatusForbidden},
+		{name: "update own review", method: "PUT", path: "/api/v1/books/1/reviews/2", body: `{"rating":1}`, expectedStatus: http.StatusOK},
+		{name: "review of wrong book", method: "PUT", path: "/api/v1/books/2/reviews/2", body: `{"rating":1}`, expectedStatus: http.StatusNotFound},
+		{name: "delete own review", method: "DELETE", path: "/api/v1/books/1/reviews/2", expectedStatus: http.StatusOK},
+		{name: "get deleted review", method: "GET", path: "/api/v1/books/1/reviews/2", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer mytoken")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Only the other user's rating is left
+	book, _ := cat.find(context.Background(), 1)
+	if book.RatingCount != 1 || book.AverageRating != 2 {
+		t.Errorf("Expected 1 rating averaging 2, got %d averaging %v", book.RatingCount, book.AverageRating)
+	}
+}
+
+func TestSortBooksByRating(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "Unrated", Author: "A", Year: 2020})
+	cat.insert(context.Background(), Book{Title: "Good", Author: "A", Year: 2020})
+	cat.insert(context.Background(), Book{Title: "Best", Author: "A", Year: 2020})
+	cat.addReview(context.Background(), Review{BookID: 2, User: "alice", Rating: 3})
+	cat.addReview(context.Background(), Review{BookID: 3, User: "alice", Rating: 5})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/books?sort=rating", nil)
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Data []Book `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	titles := []string{response.Data[0].Title, response.Data[1].Title, response.Data[2].Title}
+	if titles[0] != "Best" || titles[1] != "Good" || titles[2] != "Unrated" {
+		t.Errorf("Unexpected order: %v", titles)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/search?sort=rating&limit=1", nil)
+	router.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Data) != 1 || response.Data[0].Title != "Best" {
+		t.Errorf("Expected top rated book only, got %+v", response.Data)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books?sort=popularity", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unknown sort, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestDeleteBook_CascadesReviews(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	cat.insert(context.Background(), Book{Title: "Go", Author: "A", Year: 2020})
+	cat.addReview(context.Background(), Review{BookID: 1, User: "alice", Rating: 5})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/v1/books/1", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if len(cat.reviews) != 0 || len(cat.ratings) != 0 {
+		t.Errorf("Expected reviews and ratings to be removed, got %d reviews", len(cat.reviews))
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1/reviews", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package ginapp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// CORS AND SECURITY HEADERS
+// ============================================================
+// Both middlewares take a config struct so each route group can choose
+// its own policy (see SetupRouter). The API groups use APICORSConfig and
+// APISecurityHeaders; change them before calling SetupRouter.
+//
+// CORS:
+//   - origins are matched against an allowlist; entries may be exact
+//     ("https://app.example.com"), wildcards ("https://*.example.com")
+//     or "*" for any origin
+//   - the allowed origin is echoed back with "Vary: Origin", so caches
+//     never serve one origin's response to another
+//   - preflight requests (OPTIONS + Access-Control-Request-Method) are
+//     answered by the middleware itself with 204, or 403 if the origin,
+//     method or headers are not allowed
+//
+// Gin only runs group middleware for matched routes, so a group that
+// wants preflights answered must also register CORSPreflightRoute.
+// ============================================================
+
+// CORSConfig - cross-origin policy for a route group
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration // how long browsers may cache a preflight
+}
+
+// SecurityHeadersConfig - response headers that harden browsers against
+// injection, sniffing and framing. Empty or zero fields are not sent.
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy string
+	FrameOptions          string // DENY or SAMEORIGIN
+	ContentTypeNosniff    bool
+	ReferrerPolicy        string
+
+	// HSTS is only sent on TLS connections, as browsers ignore it on
+	// plain HTTP anyway
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+}
+
+// APICORSConfig - CORS policy of /api/v1 and /api/v2. By default the
+// usual local frontend dev servers may call the API with credentials.
+var APICORSConfig = CORSConfig{
+	AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5173"},
+	AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
+	AllowHeaders:     []string{"Authorization", "Content-Type", "X-API-Key", "X-Tenant-ID", "X-Request-ID"},
+	ExposeHeaders:    []string{"X-Request-ID", "X-Response-Time", "X-Tenant-ID", "Deprecation", "Sunset", "Link", "ETag", "Retry-After"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+}
+
+// APISecurityHeaders - security headers of the root and API groups. The API only
+// serves JSON and images, so the CSP forbids everything.
+var APISecurityHeaders = SecurityHeadersConfig{
+	ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+	FrameOptions:          "DENY",
+	ContentTypeNosniff:    true,
+	ReferrerPolicy:        "no-referrer",
+	HSTSMaxAge:            365 * 24 * time.Hour,
+	HSTSIncludeSubdomains: true,
+}
+
+// ============================================================
+// CORS
+// ============================================================
+
+// originAllowed matches an origin against the allowlist, ignoring case.
+// In a wildcard entry "*" stands for one or more DNS labels, so
+// "https://*.example.com" matches "https://a.b.example.com" but not
+// "https://example.com" or "https://evil.com/.example.com".
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, entry := range allowed {
+		entry = strings.ToLower(entry)
+		if entry == "*" || entry == origin {
+			return true
+		}
+
+		prefix, suffix, isWildcard := strings.Cut(entry, "*")
+		if !isWildcard || len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+		if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+		middle := origin[len(prefix) : len(origin)-len(suffix)]
+		if strings.Trim(middle, "abcdefghijklmnopqrstuvwxyz0123456789-.") == "" &&
+			!strings.HasPrefix(middle, ".") && !strings.HasSuffix(middle, "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether list contains value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware - applies config to cross-origin requests and answers
+// preflights. Requests without an Origin header pass through untouched.
+func CORSMiddleware(config CORSConfig) gin.HandlerFunc {
+	allowMethods := strings.Join(config.AllowMethods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+	anyOrigin := containsFold(config.AllowOrigins, "*")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !originAllowed(config.AllowOrigins, origin) {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Origin not allowed"})
+				return
+			}
+			// Not our business to block: the browser will withhold the response
+			c.Next()
+			return
+		}
+
+		// "*" is not allowed together with credentials, so echo the origin
+		if anyOrigin && !config.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				c.Header("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		if !containsFold(config.AllowMethods, c.GetHeader("Access-Control-Request-Method")) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Method not allowed by CORS policy"})
+			return
+		}
+		for _, header := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+			header = strings.TrimSpace(header)
+			if header != "" && !containsFold(config.AllowHeaders, header) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Header %s not allowed by CORS policy", header)})
+				return
+			}
+		}
+
+		c.Header("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if config.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// CORSPreflightRoute - registers OPTIONS for every path of a group so
+// its middleware, including CORSMiddleware, runs for preflights. Plain
+// OPTIONS requests get 204 with an Allow header.
+func CORSPreflightRoute(group *gin.RouterGroup, config CORSConfig) {
+	allow := strings.Join(append([]string{"OPTIONS"}, config.AllowMethods...), ", ")
+	group.OPTIONS("/*path", func(c *gin.Context) {
+		c.Header("Allow", allow)
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// ============================================================
+// SECURITY HEADERS
+// ============================================================
+
+// SecurityHeadersMiddleware - sets the headers in config on every response
+func SecurityHeadersMiddleware(config SecurityHeadersConfig) gin.HandlerFunc {
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int(config.HSTSMaxAge.Seconds()))
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		if config.ContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", config.ContentSecurityPolicy)
+		}
+		if config.FrameOptions != "" {
+			h.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ContentTypeNosniff {
+			h.Set("X-Content-Type-Options", "nosniff")
+		}
+		if config.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		if hsts != "" && c.Request.TLS != nil {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,208 @@
+package ginapp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.example.org", "http://localhost:*"}
+
+	tests := []struct {
+		origin   string
+		expected bool
+	}{
+		{origin: "https://app.example.com", expected: true},
+		{origin: "HTTPS://APP.EXAMPLE.COM", expected: true},
+		{origin: "http://app.example.com", expected: false},
+		{origin: "https://a.example.org", expected: true},
+		{origin: "https://a.b.example.org", expected: true},
+		{origin: "https://example.org", expected: false},
+		{origin: "https://.example.org", expected: false},
+		{origin: "https://evil.com/.example.org", expected: false},
+		{origin: "https://evil.com?.example.org", expected: false},
+		{origin: "http://localhost:5173", expected: true},
+		{origin: "https://evil.example.com", expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := originAllowed(allowed, tt.origin); got != tt.expected {
+			t.Errorf("originAllowed(%q) = %v, expected %v", tt.origin, got, tt.expected)
+		}
+	}
+
+	if !originAllowed([]string{"*"}, "https://anything.test") {
+		t.Error("Expected * to allow any origin")
+	}
+}
+
+func TestCORSMiddleware_SimpleRequests(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+
+	tests := []struct {
+		name        string
+		origin      string
+		allowOrigin string
+	}{
+		{name: "allowed origin", origin: "http://localhost:3000", allowOrigin: "http://localhost:3000"},
+		{name: "unknown origin", origin: "https://evil.test", allowOrigin: ""},
+		{name: "same origin", origin: "", allowOrigin: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v2/books", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			router.ServeHTTP(w, req)
+
+			// The browser enforces CORS, the server still answers
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.allowOrigin, got)
+			}
+			if tt.allowOrigin == "" {
+				return
+			}
+			if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("Expected credentials to be allowed")
+			}
+			if w.Header().Get("Access-Control-Expose-Headers") == "" {
+				t.Error("Expected exposed headers")
+			}
+			if w.Header().Get("Vary") != "Origin" {
+				t.Errorf("Expected Vary: Origin, got %q", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	router := setupTestRouter()
+
+	tests := []struct {
+		name           string
+		path           string
+		origin         string
+		method         string
+		headers        string
+		expectedStatus int
+	}{
+		{name: "allowed", path: "/api/v1/books/1", origin: "http://localhost:5173", method: "PUT", headers: "content-type, x-api-key", expectedStatus: http.StatusNoContent},
+		{name: "v2", path: "/api/v2/books", origin: "http://localhost:3000", method: "POST", expectedStatus: http.StatusNoContent},
+		{name: "unknown origin", path: "/api/v1/books", origin: "https://evil.test", method: "GET", expectedStatus: http.StatusForbidden},
+		{name: "method not allowed", path: "/api/v1/books", origin: "http://localhost:3000", method: "PATCH", expectedStatus: http.StatusForbidden},
+		{name: "header not allowed", path: "/api/v1/books", origin: "http://localhost:3000", method: "GET", headers: "X-Secret", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("OPTIONS", tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusNoContent {
+				return
+			}
+			if w.Header().Get("Access-Control-Allow-Origin") != tt.origin {
+				t.Errorf("Expected origin to be echoed, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+			}
+			if w.Header().Get("Access-Control-Allow-Methods") == "" || w.Header().Get("Access-Control-Allow-Headers") == "" {
+				t.Error("Expected allowed methods and headers")
+			}
+			if w.Header().Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("Expected max-age 600, got %q", w.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware_AnyOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		credentials bool
+		allowOrigin string
+	}{
+		{name: "without credentials", credentials: false, allowOrigin: "*"},
+		{name: "with credentials", credentials: true, allowOrigin: "https://any.test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(CORSMiddleware(CORSConfig{AllowOrigins: []string{"*"}, AllowMethods: []string{"GET"}, AllowCredentials: tt.credentials}))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", "https://any.test")
+			router.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.allowOrigin, got)
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, path := range []string{"/health", "/api/v1/formats", "/api/v2/books"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+
+		expected := map[string]string{
+			"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+			"X-Frame-Options":           "DENY",
+			"X-Content-Type-Options":    "nosniff",
+			"Referrer-Policy":           "no-referrer",
+			"Strict-Transport-Security": "",
+		}
+		for header, value := range expected {
+			if got := w.Header().Get(header); got != value {
+				t.Errorf("%s: expected %s %q, got %q", path, header, value, got)
+			}
+		}
+	}
+}
+
+func TestSecurityHeadersMiddleware_HSTS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecurityHeadersMiddleware(SecurityHeadersConfig{HSTSMaxAge: time.Hour, HSTSIncludeSubdomains: true, HSTSPreload: true}))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains; preload" {
+		t.Errorf("Unexpected HSTS header %q", got)
+	}
+	if w.Header().Get("X-Frame-Options") != "" {
+		t.Error("Expected unset fields not to be sent")
+	}
+}
@@ -0,0 +1,287 @@
+package ginapp
+
+import (
+	"context"
+	"errors"
+	"go-learning/nethttp"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ============================================================
+// SERVER: HTTP/1.1, HTTP/2 AND HTTP/3
+// ============================================================
+// Server runs one handler (normally SetupRouter()) on up to three
+// listeners that share one configuration and one shutdown:
+//
+//   Addr     TCP  plain HTTP/1.1, e.g. for probes inside the cluster
+//   TLSAddr  TCP  HTTP/1.1 and HTTP/2 over TLS
+//   TLSAddr  UDP  HTTP/3 over QUIC, if HTTP3 is set
+//
+// Browsers first talk TCP; responses there carry an Alt-Svc header
+// announcing the QUIC port, and clients that speak HTTP/3 switch over.
+// The UDP port is the same number as the TLS port.
+//
+// Listeners are opened by NewServer, so ":0" addresses work and the
+// ports are known before Serve. The certificate files are watched while
+// serving and replaced certificates are used for new connections.
+// ============================================================
+
+// ServerConfig - listeners and timeouts of a Server
+type ServerConfig struct {
+	Addr     string // plain HTTP, empty to disable
+	TLSAddr  string // HTTPS and HTTP/3, empty to disable
+	CertFile string
+	KeyFile  string
+	HTTP3    bool // also serve HTTP/3 on TLSAddr (UDP)
+
+	// ClientCAFile verifies client certificates signed by this CA; see
+	// ClientCertMiddleware for the routes that require one
+	ClientCAFile string
+
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+}
+
+// DefaultServerConfig - settings used by cmd/ginapp
+var DefaultServerConfig = ServerConfig{
+	Addr:              ":8081",
+	ReadHeaderTimeout: 10 * time.Second,
+	IdleTimeout:       2 * time.Minute,
+}
+
+// Server is a running set of listeners for one handler
+type Server struct {
+	plain *http.Server
+	https *http.Server
+	h3    *http3.Server
+
+	plainListener net.Listener
+	tlsListener   net.Listener
+	udpConn       net.PacketConn
+
+	certs     *nethttp.CertReloader
+	watchCtx  context.Context
+	stopWatch context.CancelFunc
+}
+
+// NewServer opens the configured listeners. Nothing is served until Serve.
+func NewServer(handler http.Handler, config ServerConfig) (*Server, error) {
+	if config.Addr == "" && config.TLSAddr == "" {
+		return nil, errors.New("no listen address configured")
+	}
+	if config.HTTP3 && config.TLSAddr == "" {
+		return nil, errors.New("HTTP/3 requires TLS")
+	}
+
+	s := &Server{}
+	newHTTPServer := func(h http.Handler) *http.Server {
+		return &http.Server{
+			Handler:           h,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		}
+	}
+
+	if config.Addr != "" {
+		ln, err := net.Listen("tcp", config.Addr)
+		if err != nil {
+			return nil, err
+		}
+		s.plainListener = ln
+		s.plain = newHTTPServer(handler)
+	}
+
+	if config.TLSAddr != "" {
+		tlsConfig, certs, err := nethttp.NewTLSConfig(config.CertFile, config.KeyFile, config.ClientCAFile)
+		if err != nil {
+			s.closeListeners()
+			return nil, err
+		}
+		s.certs = certs
+		s.watchCtx, s.stopWatch = context.WithCancel(context.Background())
+
+		ln, err := net.Listen("tcp", config.TLSAddr)
+		if err != nil {
+			s.closeListeners()
+			return nil, err
+		}
+		s.tlsListener = ln
+
+		tlsHandler := handler
+		if config.HTTP3 {
+			// Same port number as the TCP listener, which may have been ":0"
+			udpAddr := &net.UDPAddr{
+				IP:   ln.Addr().(*net.TCPAddr).IP,
+				Port: ln.Addr().(*net.TCPAddr).Port,
+			}
+			conn, err := net.ListenUDP("udp", udpAddr)
+			if err != nil {
+				s.closeListeners()
+				return nil, err
+			}
+			s.udpConn = conn
+			s.h3 = &http3.Server{
+				Handler:     handler,
+				TLSConfig:   http3.ConfigureTLSConfig(tlsConfig),
+				IdleTimeout: config.IdleTimeout,
+			}
+			tlsHandler = s.altSvc(handler)
+		}
+
+		s.https = newHTTPServer(tlsHandler)
+		s.https.TLSConfig = tlsConfig
+	}
+
+	return s, nil
+}
+
+// altSvc announces HTTP/3 on responses sent over TCP
+func (s *Server) altSvc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			s.h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Addr returns the plain HTTP address, nil if disabled
+func (s *Server) Addr() net.Addr {
+	if s.plainListener == nil {
+		return nil
+	}
+	return s.plainListener.Addr()
+}
+
+// TLSAddr returns the HTTPS address, nil if disabled. HTTP/3 uses the
+// same port over UDP.
+func (s *Server) TLSAddr() net.Addr {
+	if s.tlsListener == nil {
+		return nil
+	}
+	return s.tlsListener.Addr()
+}
+
+// Serve serves all listeners until Shutdown, returning the first error
+// other than the one caused by shutting down
+func (s *Server) Serve() error {
+	errs := make(chan error, 3)
+	running := 0
+
+	if s.certs != nil {
+		go s.certs.Watch(s.watchCtx, nethttp.CertReloadInterval)
+	}
+
+	if s.plain != nil {
+		running++
+		go func() { errs <- s.plain.Serve(s.plainListener) }()
+	}
+	if s.https != nil {
+		running++
+		go func() { errs <- s.https.ServeTLS(s.tlsListener, "", "") }()
+	}
+	if s.h3 != nil {
+		running++
+		go func() { errs <- s.h3.Serve(s.udpConn) }()
+	}
+
+	var firstErr error
+	for ; running > 0; running-- {
+		err := <-errs
+		if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) && firstErr == nil {
+			firstErr = err
+			// One listener failing takes the others down too
+			go s.Close()
+		}
+	}
+	return firstErr
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests
+// on every listener until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopWatching()
+	var errs []error
+	if s.plain != nil {
+		errs = append(errs, s.plain.Shutdown(ctx))
+	}
+	if s.https != nil {
+		errs = append(errs, s.https.Shutdown(ctx))
+	}
+	if s.h3 != nil {
+		errs = append(errs, s.h3.Shutdown(ctx))
+		// http3.Server does not close connections it did not open
+		errs = append(errs, s.udpConn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops all listeners immediately
+func (s *Server) Close() error {
+	s.stopWatching()
+	var errs []error
+	if s.plain != nil {
+		errs = append(errs, s.plain.Close())
+	}
+	if s.https != nil {
+		errs = append(errs, s.https.Close())
+	}
+	if s.h3 != nil {
+		errs = append(errs, s.h3.Close(), s.udpConn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) stopWatching() {
+	if s.stopWatch != nil {
+		s.stopWatch()
+	}
+}
+
+// closeListeners releases listeners opened by a failed NewServer
+func (s *Server) closeListeners() {
+	s.stopWatching()
+	for _, ln := range []net.Listener{s.plainListener, s.tlsListener} {
+		if ln != nil {
+			ln.Close()
+		}
+	}
+	if s.udpConn != nil {
+		s.udpConn.Close()
+	}
+}
+
+// ============================================================
+// CLIENT CERTIFICATES (mTLS)
+// ============================================================
+
+// RequireAdminClientCert - admin routes reject requests without a
+// verified client certificate. cmd/ginapp turns it on together with
+// ServerConfig.ClientCAFile.
+var RequireAdminClientCert = false
+
+// ClientCertMiddleware makes the subject of a verified client
+// certificate available as "client_cert_subject" and, if required,
+// rejects requests without one
+func ClientCertMiddleware(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cert := nethttp.VerifiedClientCert(c.Request)
+		if cert == nil {
+			if required {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Client certificate required"})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Set("client_cert_subject", cert.Subject.String())
+		c.Next()
+	}
+}
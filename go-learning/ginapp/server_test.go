@@ -0,0 +1,197 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"go-learning/nethttp"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// devCerts bootstraps a development CA for the test and returns its
+// files and a pool that trusts it
+func devCerts(t *testing.T) (nethttp.DevCertificates, *x509.CertPool) {
+	t.Helper()
+
+	certs, err := nethttp.EnsureDevCertificates(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(certs.CAFile)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(data)
+	return certs, pool
+}
+
+// startTestServer serves the router on loopback until the test ends
+func startTestServer(t *testing.T, config ServerConfig) *Server {
+	t.Helper()
+
+	server, err := NewServer(setupTestRouter(), config)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve() }()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return server
+}
+
+func TestServer_HTTP3(t *testing.T) {
+	resetBooks()
+	certs, pool := devCerts(t)
+	server := startTestServer(t, ServerConfig{
+		Addr:     "127.0.0.1:0",
+		TLSAddr:  "127.0.0.1:0",
+		CertFile: certs.CertFile,
+		KeyFile:  certs.KeyFile,
+		HTTP3:    true,
+	})
+	base := "https://" + server.TLSAddr().String()
+
+	h3 := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	defer h3.Close()
+	client := &http.Client{Transport: h3, Timeout: 5 * time.Second}
+
+	// Create and fetch a book over QUIC
+	body, _ := json.Marshal(CreateBookInput{Title: "QUIC Book", Author: "Author", Year: 2024})
+	resp, err := client.Post(base+"/api/v1/books", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST over HTTP/3: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || resp.Proto != "HTTP/3.0" {
+		t.Fatalf("Expected 201 over HTTP/3.0, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+
+	resp, err = client.Get(base + "/api/v1/books/1")
+	if err != nil {
+		t.Fatalf("GET over HTTP/3: %v", err)
+	}
+	var response struct {
+		Data Book `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || response.Data.Title != "QUIC Book" {
+		t.Errorf("Unexpected response %d: %+v", resp.StatusCode, response.Data)
+	}
+	if resp.Header.Get("Alt-Svc") != "" {
+		t.Errorf("Alt-Svc sent over HTTP/3: %q", resp.Header.Get("Alt-Svc"))
+	}
+
+	// HTTPS over TCP negotiates HTTP/2 and announces the QUIC port
+	tcp := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err = tcp.Get(base + "/api/v1/books")
+	if err != nil {
+		t.Fatalf("GET over TCP: %v", err)
+	}
+	resp.Body.Close()
+	port := strconv.Itoa(server.TLSAddr().(*net.TCPAddr).Port)
+	if resp.ProtoMajor != 2 || !strings.Contains(resp.Header.Get("Alt-Svc"), `h3=":`+port+`"`) {
+		t.Errorf("Expected HTTP/2 with Alt-Svc for port %s, got %s %q", port, resp.Proto, resp.Header.Get("Alt-Svc"))
+	}
+
+	// Plain HTTP serves the same router without Alt-Svc
+	resp, err = http.Get("http://" + server.Addr().String() + "/livez")
+	if err != nil {
+		t.Fatalf("GET over plain HTTP: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Alt-Svc") != "" {
+		t.Errorf("Alt-Svc sent over plain HTTP: %q", resp.Header.Get("Alt-Svc"))
+	}
+}
+
+func TestNewServer_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ServerConfig
+	}{
+		{name: "no address", config: ServerConfig{}},
+		{name: "HTTP/3 without TLS", config: ServerConfig{Addr: "127.0.0.1:0", HTTP3: true}},
+		{name: "missing certificate", config: ServerConfig{TLSAddr: "127.0.0.1:0", CertFile: "missing.pem", KeyFile: "missing.pem"}},
+	}
+	for _, tt := range tests {
+		if server, err := NewServer(http.NotFoundHandler(), tt.config); err == nil {
+			server.Close()
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestServer_AdminClientCert(t *testing.T) {
+	resetBooks()
+	resetAccounts(t)
+	defer func(old bool) { RequireAdminClientCert = old }(RequireAdminClientCert)
+	RequireAdminClientCert = true
+
+	certs, pool := devCerts(t)
+	server := startTestServer(t, ServerConfig{
+		TLSAddr:      "127.0.0.1:0",
+		CertFile:     certs.CertFile,
+		KeyFile:      certs.KeyFile,
+		ClientCAFile: certs.CAFile,
+	})
+	base := "https://" + server.TLSAddr().String()
+
+	clientCert, err := tls.LoadX509KeyPair(certs.ClientCertFile, certs.ClientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+
+	token := adminToken(t)
+	get := func(client *http.Client, path string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("GET", base+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var response map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&response)
+		return resp.StatusCode, response
+	}
+
+	// Public routes need no client certificate
+	if status, _ := get(anonymous, "/api/v1/books"); status != http.StatusOK {
+		t.Errorf("Expected public route to pass without certificate, got %d", status)
+	}
+	if status, response := get(anonymous, "/api/v1/admin/stats"); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without client certificate, got %d: %v", status, response)
+	}
+	status, response := get(withCert, "/api/v1/admin/stats")
+	if status != http.StatusOK || !strings.Contains(fmt.Sprint(response["client_cert"]), "CN=dev-admin") {
+		t.Errorf("Expected stats with client subject, got %d: %v", status, response)
+	}
+}
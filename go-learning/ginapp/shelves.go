@@ -0,0 +1,613 @@
+package ginapp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// SHELVES
+// ============================================================
+// Shelves are named, ordered reading lists owned by a user:
+//   GET    /api/v1/shelves[?owner=ann]          own shelves, or ann's public ones
+//   POST   /api/v1/shelves
+//   GET    /api/v1/shelves/:shelfId             with its books
+//   PUT    /api/v1/shelves/:shelfId             rename, describe, change visibility
+//   DELETE /api/v1/shelves/:shelfId
+//   POST   /api/v1/shelves/:shelfId/books       add {"book_id": 3, "position": 1}
+//   PUT    /api/v1/shelves/:shelfId/books       reorder {"book_ids": [3, 1, 2]}
+//   DELETE /api/v1/shelves/:shelfId/books/:id
+//   POST   /api/v1/shelves/:shelfId/share       create a sharing link
+//   DELETE /api/v1/shelves/:shelfId/share       revoke it
+//   GET    /api/v1/shared/shelves/:token        anyone with the link
+//
+// Private shelves are only visible to their owner (others get a 404);
+// public ones can be read by anyone. A sharing link works regardless
+// of visibility until it is revoked. Shelves belong to the tenant's
+// catalog, and deleting a book takes it off every shelf.
+// ============================================================
+
+// Shelf limits; tests may override them
+var (
+	MaxShelvesPerUser = 50
+	MaxBooksPerShelf  = 500
+)
+
+// Shelf visibilities
+const (
+	ShelfPrivate = "private"
+	ShelfPublic  = "public"
+)
+
+// Shelf is a user's ordered list of books
+type Shelf struct {
+	ID          int       `json:"id"`
+	Owner       string    `json:"owner"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Visibility  string    `json:"visibility"`
+	BookIDs     []int     `json:"book_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	shareToken string
+
+	// Filled in responses
+	ShareURL string `json:"share_url,omitempty"` // owner only
+	Books    []Book `json:"books,omitempty"`     // single shelf reads
+}
+
+// CreateShelfInput - input for creating a shelf
+type CreateShelfInput struct {
+	Name        string `json:"name" binding:"required,min=1,max=100"`
+	Description string `json:"description,omitempty" binding:"max=500"`
+	Visibility  string `json:"visibility,omitempty" binding:"omitempty,oneof=private public"`
+}
+
+// UpdateShelfInput - input for updating a shelf (all fields optional)
+type UpdateShelfInput struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description,omitempty" binding:"omitempty,max=500"`
+	Visibility  *string `json:"visibility,omitempty" binding:"omitempty,oneof=private public"`
+}
+
+// AddShelfBookInput - input for putting a book on a shelf. Position is
+// 1-based; 0 appends.
+type AddShelfBookInput struct {
+	BookID   int `json:"book_id" binding:"required,min=1"`
+	Position int `json:"position,omitempty" binding:"min=0"`
+}
+
+// ReorderShelfInput - the shelf's books in their new order
+type ReorderShelfInput struct {
+	BookIDs []int `json:"book_ids" binding:"required"`
+}
+
+var (
+	errShelfNotFound  = errors.New("shelf not found")
+	errNotShelfOwner  = errors.New("only the owner can change a shelf")
+	errShelfName      = errors.New("user already has a shelf with this name")
+	errShelfLimit     = errors.New("shelf limit reached")
+	errShelfFull      = errors.New("shelf is full")
+	errAlreadyShelved = errors.New("book is already on the shelf")
+	errNotShelved     = errors.New("book is not on the shelf")
+	errShelfOrder     = errors.New("book_ids must list the shelf's books exactly once")
+)
+
+// ============================================================
+// CATALOG METHODS
+// ============================================================
+
+// visibleShelf returns a shelf user may read. Private shelves of
+// others are reported as not found. Callers must hold booksMu.
+func (cat *catalog) visibleShelf(id int, user string) (*Shelf, error) {
+	shelf, exists := cat.shelves[id]
+	if !exists || shelf.Owner != user && shelf.Visibility != ShelfPublic {
+		return nil, errShelfNotFound
+	}
+	return shelf, nil
+}
+
+// ownShelf returns a shelf user may change. Callers must hold booksMu.
+func (cat *catalog) ownShelf(id int, user string) (*Shelf, error) {
+	shelf, err := cat.visibleShelf(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if shelf.Owner != user {
+		return nil, errNotShelfOwner
+	}
+	return shelf, nil
+}
+
+// shelfView copies a shelf for a response. Callers must hold booksMu.
+func (cat *catalog) shelfView(shelf *Shelf, user string, withBooks bool) Shelf {
+	view := *shelf
+	view.BookIDs = slices.Clone(shelf.BookIDs)
+	if shelf.Owner == user && shelf.shareToken != "" {
+		view.ShareURL = "/api/v1/shared/shelves/" + shelf.shareToken
+	}
+	if withBooks {
+		view.Books = make([]Book, 0, len(shelf.BookIDs))
+		for _, id := range shelf.BookIDs {
+			book, _ := cat.books.get(id)
+			view.Books = append(view.Books, book)
+		}
+	}
+	return view
+}
+
+// listShelves returns owner's shelves that user may read, oldest first
+func (cat *catalog) listShelves(ctx context.Context, owner, user string) ([]Shelf, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	shelves := make([]Shelf, 0)
+	for _, shelf := range cat.shelves {
+		if shelf.Owner != owner {
+			continue
+		}
+		if _, err := cat.visibleShelf(shelf.ID, user); err == nil {
+			shelves = append(shelves, cat.shelfView(shelf, user, false))
+		}
+	}
+	sort.Slice(shelves, func(i, j int) bool { return shelves[i].ID < shelves[j].ID })
+	return shelves, nil
+}
+
+// findShelf returns a shelf with its books, if user may read it
+func (cat *catalog) findShelf(ctx context.Context, id int, user string) (Shelf, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return Shelf{}, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	shelf, err := cat.visibleShelf(id, user)
+	if err != nil {
+		return Shelf{}, err
+	}
+	return cat.shelfView(shelf, user, true), nil
+}
+
+// sharedShelf returns the shelf a sharing link points to
+func (cat *catalog) sharedShelf(ctx context.Context, token string) (Shelf, error) {
+	if err := cat.rlock(ctx); err != nil {
+		return Shelf{}, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	for _, shelf := range cat.shelves {
+		if shelf.shareToken != "" && subtle.ConstantTimeCompare([]byte(shelf.shareToken), []byte(token)) == 1 {
+			return cat.shelfView(shelf, "", true), nil
+		}
+	}
+	return Shelf{}, errShelfNotFound
+}
+
+// createShelf stores a new shelf; names are unique per owner
+func (cat *catalog) createShelf(ctx context.Context, shelf Shelf) (Shelf, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Shelf{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	owned := 0
+	for _, s := range cat.shelves {
+		if s.Owner != shelf.Owner {
+			continue
+		}
+		if s.Name == shelf.Name {
+			return Shelf{}, errShelfName
+		}
+		owned++
+	}
+	if owned >= MaxShelvesPerUser {
+		return Shelf{}, errShelfLimit
+	}
+
+	now := time.Now()
+	shelf.ID = cat.shelfID
+	shelf.BookIDs = []int{}
+	shelf.CreatedAt = now
+	shelf.UpdatedAt = now
+	if shelf.Visibility == "" {
+		shelf.Visibility = ShelfPrivate
+	}
+	cat.shelves[shelf.ID] = &shelf
+	cat.shelfID++
+	return cat.shelfView(&shelf, shelf.Owner, false), nil
+}
+
+// changeShelf applies fn to a shelf user owns
+func (cat *catalog) changeShelf(ctx context.Context, id int, user string, fn func(*Shelf) error) (Shelf, error) {
+	if err := cat.lock(ctx); err != nil {
+		return Shelf{}, err
+	}
+	defer cat.booksMu.Unlock()
+
+	shelf, err := cat.ownShelf(id, user)
+	if err != nil {
+		return Shelf{}, err
+	}
+	if err := fn(shelf); err != nil {
+		return Shelf{}, err
+	}
+	shelf.UpdatedAt = time.Now()
+	return cat.shelfView(shelf, user, false), nil
+}
+
+// updateShelf renames, describes or changes the visibility of a shelf
+func (cat *catalog) updateShelf(ctx context.Context, id int, user string, input UpdateShelfInput) (Shelf, error) {
+	return cat.changeShelf(ctx, id, user, func(shelf *Shelf) error {
+		if input.Name != nil && *input.Name != shelf.Name {
+			for _, s := range cat.shelves {
+				if s.Owner == user && s.Name == *input.Name {
+					return errShelfName
+				}
+			}
+			shelf.Name = *input.Name
+		}
+		if input.Description != nil {
+			shelf.Description = *input.Description
+		}
+		if input.Visibility != nil {
+			shelf.Visibility = *input.Visibility
+		}
+		return nil
+	})
+}
+
+// deleteShelf removes a shelf user owns
+func (cat *catalog) deleteShelf(ctx context.Context, id int, user string) error {
+	if err := cat.lock(ctx); err != nil {
+		return err
+	}
+	defer cat.booksMu.Unlock()
+
+	if _, err := cat.ownShelf(id, user); err != nil {
+		return err
+	}
+	delete(cat.shelves, id)
+	return nil
+}
+
+// addToShelf puts a book on a shelf at a 1-based position, or last
+func (cat *catalog) addToShelf(ctx context.Context, id int, user string, bookID, position int) (Shelf, error) {
+	return cat.changeShelf(ctx, id, user, func(shelf *Shelf) error {
+		if _, exists := cat.books.get(bookID); !exists {
+			return errBookNotFound
+		}
+		if slices.Contains(shelf.BookIDs, bookID) {
+			return errAlreadyShelved
+		}
+		if len(shelf.BookIDs) >= MaxBooksPerShelf {
+			return errShelfFull
+		}
+		if position == 0 || position > len(shelf.BookIDs) {
+			position = len(shelf.BookIDs) + 1
+		}
+		shelf.BookIDs = slices.Insert(shelf.BookIDs, position-1, bookID)
+		return nil
+	})
+}
+
+// removeFromShelf takes a book off a shelf
+func (cat *catalog) removeFromShelf(ctx context.Context, id int, user string, bookID int) (Shelf, error) {
+	return cat.changeShelf(ctx, id, user, func(shelf *Shelf) error {
+		i := slices.Index(shelf.BookIDs, bookID)
+		if i < 0 {
+			return errNotShelved
+		}
+		shelf.BookIDs = slices.Delete(shelf.BookIDs, i, i+1)
+		return nil
+	})
+}
+
+// reorderShelf replaces the order of a shelf's books
+func (cat *catalog) reorderShelf(ctx context.Context, id int, user string, bookIDs []int) (Shelf, error) {
+	return cat.changeShelf(ctx, id, user, func(shelf *Shelf) error {
+		current := slices.Sorted(slices.Values(shelf.BookIDs))
+		proposed := slices.Sorted(slices.Values(bookIDs))
+		if !slices.Equal(current, proposed) {
+			return errShelfOrder
+		}
+		shelf.BookIDs = slices.Clone(bookIDs)
+		return nil
+	})
+}
+
+// shareShelf sets the shelf's sharing token; "" revokes the link
+func (cat *catalog) shareShelf(ctx context.Context, id int, user, token string) (Shelf, error) {
+	return cat.changeShelf(ctx, id, user, func(shelf *Shelf) error {
+		shelf.shareToken = token
+		return nil
+	})
+}
+
+// shelvedAt returns a book's index on each shelf holding it, by shelf
+// ID. Callers must hold booksMu.
+func (cat *catalog) shelvedAt(bookID int) map[int]int {
+	positions := make(map[int]int)
+	for _, shelf := range cat.shelves {
+		if i := slices.Index(shelf.BookIDs, bookID); i >= 0 {
+			positions[shelf.ID] = i
+		}
+	}
+	return positions
+}
+
+// unshelve takes a book off every shelf. Callers must hold booksMu for
+// writing.
+func (cat *catalog) unshelve(bookID int) {
+	for _, shelf := range cat.shelves {
+		shelf.BookIDs = slices.DeleteFunc(shelf.BookIDs, func(id int) bool { return id == bookID })
+	}
+}
+
+// reshelve puts a book back where shelvedAt found it. Callers must hold
+// booksMu for writing.
+func (cat *catalog) reshelve(bookID int, positions map[int]int) {
+	for shelfID, i := range positions {
+		if shelf, exists := cat.shelves[shelfID]; exists {
+			shelf.BookIDs = slices.Insert(shelf.BookIDs, i, bookID)
+		}
+	}
+}
+
+// pruneShelves takes books that no longer exist off all shelves, e.g.
+// after a restore. Callers must hold booksMu for writing.
+func (cat *catalog) pruneShelves() {
+	for _, shelf := range cat.shelves {
+		shelf.BookIDs = slices.DeleteFunc(shelf.BookIDs, func(id int) bool {
+			_, exists := cat.books.get(id)
+			return !exists
+		})
+	}
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+type shelfURI struct {
+	ShelfID int `uri:"shelfId" binding:"required,min=1"`
+	BookID  int `uri:"id" binding:"omitempty,min=1"`
+}
+
+// shelfError writes the response for a failed shelf call
+func shelfError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errShelfNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shelf not found"})
+	case errors.Is(err, errBookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	case errors.Is(err, errNotShelved):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book is not on this shelf"})
+	case errors.Is(err, errNotShelfOwner):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change a shelf"})
+	case errors.Is(err, errShelfLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Shelf limit reached"})
+	case errors.Is(err, errShelfName):
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a shelf with this name"})
+	case errors.Is(err, errAlreadyShelved):
+		c.JSON(http.StatusConflict, gin.H{"error": "Book is already on this shelf"})
+	case errors.Is(err, errShelfFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "Shelf is full"})
+	case errors.Is(err, errShelfOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errShelfOrder.Error()})
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		timeoutError(c, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// bindShelfURI binds :shelfId (and :id), answering 400 if invalid
+func bindShelfURI(c *gin.Context) (shelfURI, bool) {
+	var uri shelfURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shelf or book ID"})
+		return uri, false
+	}
+	return uri, true
+}
+
+// ListShelves - GET /api/v1/shelves?owner=...
+func ListShelves(c *gin.Context) {
+	user := c.GetString("user")
+	owner := c.DefaultQuery("owner", user)
+
+	shelves, err := catalogFrom(c).listShelves(c.Request.Context(), owner, user)
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  shelves,
+		"count": len(shelves),
+	})
+}
+
+// CreateShelf - POST /api/v1/shelves
+func CreateShelf(c *gin.Context) {
+	var input CreateShelfInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	shelf, err := catalogFrom(c).createShelf(c.Request.Context(), Shelf{
+		Owner:       c.GetString("user"),
+		Name:        input.Name,
+		Description: input.Description,
+		Visibility:  input.Visibility,
+	})
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": shelf})
+}
+
+// GetShelf - GET /api/v1/shelves/:shelfId (auth optional for public shelves)
+func GetShelf(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	user, _ := authenticate(c)
+	shelf, err := catalogFrom(c).findShelf(c.Request.Context(), uri.ShelfID, user)
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
+
+// UpdateShelf - PUT /api/v1/shelves/:shelfId (owner only)
+func UpdateShelf(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateShelfInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	shelf, err := catalogFrom(c).updateShelf(c.Request.Context(), uri.ShelfID, c.GetString("user"), input)
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
+
+// DeleteShelf - DELETE /api/v1/shelves/:shelfId (owner only)
+func DeleteShelf(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	if err := catalogFrom(c).deleteShelf(c.Request.Context(), uri.ShelfID, c.GetString("user")); err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shelf deleted successfully"})
+}
+
+// AddShelfBook - POST /api/v1/shelves/:shelfId/books (owner only)
+func AddShelfBook(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	var input AddShelfBookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	shelf, err := catalogFrom(c).addToShelf(c.Request.Context(), uri.ShelfID, c.GetString("user"), input.BookID, input.Position)
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
+
+// ReorderShelf - PUT /api/v1/shelves/:shelfId/books (owner only)
+func ReorderShelf(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	var input ReorderShelfInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	shelf, err := catalogFrom(c).reorderShelf(c.Request.Context(), uri.ShelfID, c.GetString("user"), input.BookIDs)
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
+
+// RemoveShelfBook - DELETE /api/v1/shelves/:shelfId/books/:id (owner only)
+func RemoveShelfBook(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	shelf, err := catalogFrom(c).removeFromShelf(c.Request.Context(), uri.ShelfID, c.GetString("user"), uri.BookID)
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
+
+// ShareShelf - POST /api/v1/shelves/:shelfId/share (owner only). A new
+// link replaces the previous one.
+func ShareShelf(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sharing link"})
+		return
+	}
+	shelf, err := catalogFrom(c).shareShelf(c.Request.Context(), uri.ShelfID, c.GetString("user"), token)
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
+
+// UnshareShelf - DELETE /api/v1/shelves/:shelfId/share (owner only)
+func UnshareShelf(c *gin.Context) {
+	uri, ok := bindShelfURI(c)
+	if !ok {
+		return
+	}
+
+	shelf, err := catalogFrom(c).shareShelf(c.Request.Context(), uri.ShelfID, c.GetString("user"), "")
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
+
+// GetSharedShelf - GET /api/v1/shared/shelves/:token
+func GetSharedShelf(c *gin.Context) {
+	shelf, err := catalogFrom(c).sharedShelf(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		shelfError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": shelf})
+}
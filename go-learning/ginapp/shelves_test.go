@@ -0,0 +1,159 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// decodeShelf parses a {"data": shelf} response
+func decodeShelf(t *testing.T, w *httptest.ResponseRecorder) Shelf {
+	t.Helper()
+
+	var response struct{ Data Shelf }
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response.Data
+}
+
+func setupShelvesTest(t *testing.T) (http.Handler, string, string) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	cat := defaultTenant().catalog
+	for i := 1; i <= 3; i++ {
+		cat.insert(context.Background(), Book{Title: fmt.Sprintf("Book %d", i), Author: "A", Year: 2000 + i})
+	}
+	return router, loginToken(t, router, "ann", "correct-horse-42"), loginToken(t, router, "bob", "battery-staple-7")
+}
+
+func TestShelves(t *testing.T) {
+	router, ann, bob := setupShelvesTest(t)
+
+	w := apiRequest(router, "POST", "/api/v1/shelves", `{"name": "To read"}`, "Authorization", "Bearer "+ann)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	shelf := decodeShelf(t, w)
+	if shelf.Owner != "ann" || shelf.Visibility != ShelfPrivate {
+		t.Errorf("Unexpected shelf: %+v", shelf)
+	}
+	if w := apiRequest(router, "POST", "/api/v1/shelves", `{"name": "To read"}`, "Authorization", "Bearer "+ann); w.Code != http.StatusConflict {
+		t.Errorf("Expected duplicate name to be %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	path := fmt.Sprintf("/api/v1/shelves/%d", shelf.ID)
+	apiRequest(router, "POST", path+"/books", `{"book_id": 1}`, "Authorization", "Bearer "+ann)
+	apiRequest(router, "POST", path+"/books", `{"book_id": 2}`, "Authorization", "Bearer "+ann)
+	w = apiRequest(router, "POST", path+"/books", `{"book_id": 3, "position": 1}`, "Authorization", "Bearer "+ann)
+	if got := fmt.Sprint(decodeShelf(t, w).BookIDs); got != "[3 1 2]" {
+		t.Errorf("Expected [3 1 2], got %s", got)
+	}
+	if w := apiRequest(router, "POST", path+"/books", `{"book_id": 1}`, "Authorization", "Bearer "+ann); w.Code != http.StatusConflict {
+		t.Errorf("Expected duplicate book to be %d, got %d", http.StatusConflict, w.Code)
+	}
+	if w := apiRequest(router, "POST", path+"/books", `{"book_id": 99}`, "Authorization", "Bearer "+ann); w.Code != http.StatusNotFound {
+		t.Errorf("Expected missing book to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = apiRequest(router, "PUT", path+"/books", `{"book_ids": [2, 3, 1]}`, "Authorization", "Bearer "+ann)
+	if got := fmt.Sprint(decodeShelf(t, w).BookIDs); got != "[2 3 1]" {
+		t.Errorf("Expected [2 3 1], got %s", got)
+	}
+	if w := apiRequest(router, "PUT", path+"/books", `{"book_ids": [2, 3]}`, "Authorization", "Bearer "+ann); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected incomplete order to be %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = apiRequest(router, "DELETE", path+"/books/3", "", "Authorization", "Bearer "+ann)
+	if got := fmt.Sprint(decodeShelf(t, w).BookIDs); got != "[2 1]" {
+		t.Errorf("Expected [2 1], got %s", got)
+	}
+
+	// Private shelves are hidden from others, public ones readable but
+	// only changed by the owner
+	if w := apiRequest(router, "GET", path, "", "Authorization", "Bearer "+bob); w.Code != http.StatusNotFound {
+		t.Errorf("Expected private shelf to be %d for others, got %d", http.StatusNotFound, w.Code)
+	}
+	apiRequest(router, "PUT", path, `{"visibility": "public"}`, "Authorization", "Bearer "+ann)
+	w = apiRequest(router, "GET", path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected public shelf to be readable, got %d: %s", w.Code, w.Body.String())
+	}
+	if books := decodeShelf(t, w).Books; len(books) != 2 || books[0].Title != "Book 2" {
+		t.Errorf("Expected books in shelf order, got %+v", books)
+	}
+	if w := apiRequest(router, "POST", path+"/books", `{"book_id": 3}`, "Authorization", "Bearer "+bob); w.Code != http.StatusForbidden {
+		t.Errorf("Expected others' change to be %d, got %d", http.StatusForbidden, w.Code)
+	}
+	w = apiRequest(router, "GET", "/api/v1/shelves?owner=ann", "", "Authorization", "Bearer "+bob)
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"count":1`)) {
+		t.Errorf("Expected ann's public shelf listed, got %s", w.Body.String())
+	}
+
+	if w := apiRequest(router, "DELETE", path, "", "Authorization", "Bearer "+ann); w.Code != http.StatusOK {
+		t.Errorf("Expected delete to succeed, got %d", w.Code)
+	}
+	if w := apiRequest(router, "GET", path, "", "Authorization", "Bearer "+ann); w.Code != http.StatusNotFound {
+		t.Errorf("Expected deleted shelf to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestShelves_SharingLink(t *testing.T) {
+	router, ann, bob := setupShelvesTest(t)
+
+	shelf := decodeShelf(t, apiRequest(router, "POST", "/api/v1/shelves", `{"name": "Gift ideas"}`, "Authorization", "Bearer "+ann))
+	path := fmt.Sprintf("/api/v1/shelves/%d", shelf.ID)
+	apiRequest(router, "POST", path+"/books", `{"book_id": 2}`, "Authorization", "Bearer "+ann)
+
+	shared := decodeShelf(t, apiRequest(router, "POST", path+"/share", "", "Authorization", "Bearer "+ann))
+	if shared.ShareURL == "" {
+		t.Fatal("Expected a sharing link")
+	}
+
+	// The link works without auth even though the shelf is private, and
+	// does not reveal itself to viewers
+	w := apiRequest(router, "GET", shared.ShareURL, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected shared shelf, got %d: %s", w.Code, w.Body.String())
+	}
+	if viewed := decodeShelf(t, w); len(viewed.Books) != 1 || viewed.ShareURL != "" {
+		t.Errorf("Unexpected shared view: %+v", viewed)
+	}
+	if w := apiRequest(router, "POST", path+"/share", "", "Authorization", "Bearer "+bob); w.Code != http.StatusNotFound {
+		t.Errorf("Expected others to be unable to share, got %d", w.Code)
+	}
+
+	apiRequest(router, "DELETE", path+"/share", "", "Authorization", "Bearer "+ann)
+	if w := apiRequest(router, "GET", shared.ShareURL, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected revoked link to be %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestShelves_BookDeleted(t *testing.T) {
+	router, ann, _ := setupShelvesTest(t)
+	cat := defaultTenant().catalog
+
+	for _, name := range []string{"One", "Two"} {
+		shelf := decodeShelf(t, apiRequest(router, "POST", "/api/v1/shelves", `{"name": "`+name+`"}`, "Authorization", "Bearer "+ann))
+		for _, id := range []int{1, 2, 3} {
+			apiRequest(router, "POST", fmt.Sprintf("/api/v1/shelves/%d/books", shelf.ID), fmt.Sprintf(`{"book_id": %d}`, id), "Authorization", "Bearer "+ann)
+		}
+	}
+
+	// A rolled back batch puts the book back in place
+	apiRequest(router, "POST", "/api/v1/batch", `{"operations": [{"op": "delete", "id": 2}, {"op": "delete", "id": 99}]}`)
+	if got := fmt.Sprint(cat.shelves[1].BookIDs, cat.shelves[2].BookIDs); got != "[1 2 3] [1 2 3]" {
+		t.Errorf("Expected shelves restored after rollback, got %s", got)
+	}
+
+	if w := apiRequest(router, "DELETE", "/api/v1/books/2", "", "Authorization", "Bearer "+ann); w.Code != http.StatusOK {
+		t.Fatalf("Delete failed: %d: %s", w.Code, w.Body.String())
+	}
+	if got := fmt.Sprint(cat.shelves[1].BookIDs, cat.shelves[2].BookIDs); got != "[1 3] [1 3]" {
+		t.Errorf("Expected book removed from all shelves, got %s", got)
+	}
+}
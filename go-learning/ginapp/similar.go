@@ -0,0 +1,469 @@
+package ginapp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-learning/algorithms"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// SIMILAR BOOKS
+// ============================================================
+// GET /api/v1/books/:id/similar ranks the other books of the catalog by
+// a similarity score between 0 and 1, the weighted sum of:
+//
+//   - author:  1 if the books share an author
+//   - year:    1 for the same year, falling to 0 at SimilarYearWindow
+//   - words:   Jaccard overlap of the title words, ignoring stop words
+//   - title:   mean of the LCS ratio and the edit similarity of the
+//              lowercased titles (algorithms.LongestCommonSubsequence,
+//              algorithms.EditDistance)
+//
+// Comparing titles is quadratic in their length, so the catalog keeps a
+// neighbor index: the SimilarNeighbors best matches of every book. Writes
+// mark the books they change; the index catches up outside the catalog
+// lock (see refreshSimilar) and reads look it up. Each match carries the
+// reasons it scored.
+// ============================================================
+
+// Similarity settings. Changes apply to books indexed afterwards.
+var (
+	SimilarNeighbors  = 10   // matches kept per book
+	MinSimilarity     = 0.25 // weaker matches are not recommended
+	SimilarYearWindow = 10   // years apart at which year proximity is 0
+)
+
+// Weights of the similarity components, summing to 1
+const (
+	authorWeight = 0.40
+	yearWeight   = 0.15
+	wordsWeight  = 0.25
+	titleWeight  = 0.20
+)
+
+// titleStopWords do not count as shared title words
+var titleStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true,
+	"in": true, "on": true, "to": true, "for": true, "with": true,
+}
+
+// SimilarBook - one recommendation with the reasons behind it
+type SimilarBook struct {
+	Book    Book     `json:"book"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// neighbor is an entry of the index: a similar book and why
+type neighbor struct {
+	id      int
+	score   float64
+	reasons []string
+}
+
+// ============================================================
+// SCORING
+// ============================================================
+
+// compareBooks scores how similar b is to a and explains the score
+func compareBooks(a, b Book) neighbor {
+	var score float64
+	var reasons []string
+
+	if shared := sharedAuthors(a, b); len(shared) > 0 {
+		score += authorWeight
+		reasons = append(reasons, "same author: "+strings.Join(shared, ", "))
+	}
+
+	yearsApart := a.Year - b.Year
+	if yearsApart < 0 {
+		yearsApart = -yearsApart
+	}
+	if yearsApart < SimilarYearWindow {
+		score += yearWeight * (1 - float64(yearsApart)/float64(SimilarYearWindow))
+		switch {
+		case yearsApart == 0:
+			reasons = append(reasons, fmt.Sprintf("published the same year (%d)", a.Year))
+		case yearsApart <= SimilarYearWindow/2:
+			reasons = append(reasons, fmt.Sprintf("published %d years apart", yearsApart))
+		}
+	}
+
+	if words, overlap := sharedTitleWords(a.Title, b.Title); len(words) > 0 {
+		score += wordsWeight * overlap
+		reasons = append(reasons, "shared title words: "+strings.Join(words, ", "))
+	}
+
+	if alike := titleSimilarity(a.Title, b.Title); alike > 0 {
+		score += titleWeight * alike
+		if alike >= 0.5 {
+			reasons = append(reasons, fmt.Sprintf("similar titles (%d%% alike)", int(math.Round(alike*100))))
+		}
+	}
+
+	return neighbor{id: b.ID, score: math.Round(score*1000) / 1000, reasons: reasons}
+}
+
+// bookAuthors returns the author names of a book, lowercased
+func bookAuthors(b Book) []string {
+	authors := b.Authors
+	if len(authors) == 0 {
+		authors = splitAuthors(b.Author)
+	}
+	names := make([]string, len(authors))
+	for i, a := range authors {
+		names[i] = strings.ToLower(a.Name)
+	}
+	return names
+}
+
+// sharedAuthors returns the authors of b that also wrote a
+func sharedAuthors(a, b Book) []string {
+	names := make(map[string]bool)
+	for _, name := range bookAuthors(a) {
+		names[name] = true
+	}
+
+	var shared []string
+	authors := b.Authors
+	if len(authors) == 0 {
+		authors = splitAuthors(b.Author)
+	}
+	for _, author := range authors {
+		if names[strings.ToLower(author.Name)] {
+			shared = append(shared, author.Name)
+		}
+	}
+	return shared
+}
+
+// titleWords splits a title into lowercase words without stop words
+func titleWords(title string) map[string]bool {
+	words := make(map[string]bool)
+	fields := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range fields {
+		if !titleStopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// sharedTitleWords returns the words both titles contain, sorted, and
+// their Jaccard overlap
+func sharedTitleWords(a, b string) ([]string, float64) {
+	wordsA, wordsB := titleWords(a), titleWords(b)
+
+	var shared []string
+	for word := range wordsA {
+		if wordsB[word] {
+			shared = append(shared, word)
+		}
+	}
+	sort.Strings(shared)
+
+	union := len(wordsA) + len(wordsB) - len(shared)
+	if union == 0 {
+		return nil, 0
+	}
+	return shared, float64(len(shared)) / float64(union)
+}
+
+// titleSimilarity compares two titles character by character: the mean
+// of the LCS ratio and one minus the normalized edit distance
+func titleSimilarity(a, b string) float64 {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	lcsRatio := 2 * float64(algorithms.LongestCommonSubsequence(a, b)) / float64(len(a)+len(b))
+
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	editRatio := 1 - float64(algorithms.EditDistance(a, b))/float64(longest)
+
+	return (lcsRatio + editRatio) / 2
+}
+
+// ============================================================
+// NEIGHBOR INDEX
+// ============================================================
+// Writers never update the index themselves: they mark the book stale
+// (markSimilarStale), which costs a map insert, and only when the title,
+// author or year changed. refreshSimilar catches up for all stale books
+// at once, from a snapshot of the books, holding similarMu but not
+// booksMu. It runs before every similar-books read and from the
+// "similar-indexer" worker, so writes never wait for the comparisons.
+//
+// Lock order: similarMu, then booksMu. staleMu is only ever held on its
+// own.
+
+// SimilarIndexInterval - how often the worker refreshes stale indexes
+var SimilarIndexInterval = time.Second
+
+// similarFieldsChanged reports whether an update changes what the index
+// compares
+func similarFieldsChanged(before, after Book) bool {
+	return before.Title != after.Title || before.Author != after.Author ||
+		before.Year != after.Year || !slices.Equal(before.Authors, after.Authors)
+}
+
+// markSimilarStale queues a book for the next refreshSimilar
+func (cat *catalog) markSimilarStale(bookID int) {
+	cat.staleMu.Lock()
+	cat.stale[bookID] = true
+	cat.staleMu.Unlock()
+}
+
+// refreshSimilar brings the index up to date for the stale books. The
+// stale set is taken before the snapshot: a writer marks a book after
+// storing it, so every book taken is in the snapshot as written.
+func (cat *catalog) refreshSimilar() {
+	cat.similarMu.Lock()
+	defer cat.similarMu.Unlock()
+
+	cat.staleMu.Lock()
+	stale := make([]int, 0, len(cat.stale))
+	for id := range cat.stale {
+		stale = append(stale, id)
+	}
+	cat.stale = make(map[int]bool)
+	cat.staleMu.Unlock()
+	if len(stale) == 0 {
+		return
+	}
+	sort.Ints(stale)
+
+	// The read lock keeps half-applied batches out of the snapshot
+	cat.booksMu.RLock()
+	books, _ := cat.books.snapshot(context.Background())
+	cat.booksMu.RUnlock()
+
+	byID := make(map[int]Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+	for _, id := range stale {
+		if book, exists := byID[id]; exists {
+			cat.indexBook(book, books)
+		} else {
+			cat.unindexBook(id, books, byID)
+		}
+	}
+}
+
+// refreshAllSimilar refreshes the index of every tenant, see
+// StartBackgroundWorkers
+func refreshAllSimilar(time.Time) {
+	tenantsMu.RLock()
+	cats := make([]*catalog, 0, len(tenants))
+	for _, t := range tenants {
+		cats = append(cats, t.catalog)
+	}
+	tenantsMu.RUnlock()
+
+	for _, cat := range cats {
+		cat.refreshSimilar()
+	}
+}
+
+// The methods below work on a snapshot of the books and must be called
+// with similarMu held.
+
+// sortNeighbors orders neighbors best first, ties by book ID
+func sortNeighbors(neighbors []neighbor) {
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].score != neighbors[j].score {
+			return neighbors[i].score > neighbors[j].score
+		}
+		return neighbors[i].id < neighbors[j].id
+	})
+}
+
+// neighborsOf computes the best matches of a book from scratch
+func neighborsOf(book Book, books []Book) []neighbor {
+	neighbors := make([]neighbor, 0)
+	for _, other := range books {
+		if other.ID == book.ID {
+			continue
+		}
+		if n := compareBooks(book, other); n.score >= MinSimilarity {
+			neighbors = append(neighbors, n)
+		}
+	}
+	sortNeighbors(neighbors)
+	if len(neighbors) > SimilarNeighbors {
+		neighbors = neighbors[:SimilarNeighbors]
+	}
+	return neighbors
+}
+
+// indexBook (re)computes the neighbors of a stored book and updates it
+// in the neighbor lists of every other book
+func (cat *catalog) indexBook(book Book, books []Book) {
+	cat.similar[book.ID] = neighborsOf(book, books)
+
+	for _, other := range books {
+		id := other.ID
+		if id == book.ID {
+			continue
+		}
+
+		neighbors := cat.similar[id]
+		full := len(neighbors) >= SimilarNeighbors
+		var previous *neighbor
+		for i := range neighbors {
+			if neighbors[i].id == book.ID {
+				previous = &neighbors[i]
+				break
+			}
+		}
+
+		n := compareBooks(other, book)
+		switch {
+		case previous != nil && full && n.score < previous.score:
+			// It may drop below a book that is not in the list
+			cat.similar[id] = neighborsOf(other, books)
+		case previous != nil:
+			*previous = n
+			if n.score < MinSimilarity {
+				neighbors = withoutNeighbor(neighbors, book.ID)
+			}
+			sortNeighbors(neighbors)
+			cat.similar[id] = neighbors
+		case n.score >= MinSimilarity:
+			neighbors = append(neighbors, n)
+			sortNeighbors(neighbors)
+			if len(neighbors) > SimilarNeighbors {
+				neighbors = neighbors[:SimilarNeighbors]
+			}
+			cat.similar[id] = neighbors
+		}
+	}
+}
+
+// unindexBook drops a deleted book from the index
+func (cat *catalog) unindexBook(bookID int, books []Book, byID map[int]Book) {
+	delete(cat.similar, bookID)
+
+	for id, neighbors := range cat.similar {
+		full := len(neighbors) >= SimilarNeighbors
+		remaining := withoutNeighbor(neighbors, bookID)
+		switch {
+		case len(remaining) == len(neighbors):
+			continue
+		case full:
+			// A book outside the list may move up
+			cat.similar[id] = neighborsOf(byID[id], books)
+		default:
+			cat.similar[id] = remaining
+		}
+	}
+}
+
+// reindex rebuilds the whole index, e.g. after a restore
+func (cat *catalog) reindex(books []Book) {
+	cat.similar = make(map[int][]neighbor, len(books))
+	for _, book := range books {
+		cat.similar[book.ID] = neighborsOf(book, books)
+	}
+}
+
+// withoutNeighbor returns neighbors without the entry for bookID
+func withoutNeighbor(neighbors []neighbor, bookID int) []neighbor {
+	remaining := make([]neighbor, 0, len(neighbors))
+	for _, n := range neighbors {
+		if n.id != bookID {
+			remaining = append(remaining, n)
+		}
+	}
+	return remaining
+}
+
+// similarBooks returns up to limit recommendations for a book
+func (cat *catalog) similarBooks(ctx context.Context, bookID, limit int) ([]SimilarBook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cat.refreshSimilar()
+	cat.similarMu.Lock()
+	neighbors := slices.Clone(cat.similar[bookID]) // refreshes sort in place
+	cat.similarMu.Unlock()
+
+	if err := cat.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer cat.booksMu.RUnlock()
+
+	if _, exists := cat.books.get(bookID); !exists {
+		return nil, errBookNotFound
+	}
+
+	results := make([]SimilarBook, 0, limit)
+	for _, n := range neighbors {
+		if len(results) == limit {
+			break
+		}
+		// Deleted since the refresh; the next one drops it
+		book, exists := cat.books.get(n.id)
+		if !exists {
+			continue
+		}
+		results = append(results, SimilarBook{
+			Book:    book,
+			Score:   n.score,
+			Reasons: n.reasons,
+		})
+	}
+	return results, nil
+}
+
+// ============================================================
+// HANDLERS
+// ============================================================
+
+// GetSimilarBooks - GET /api/v1/books/:id/similar?limit=5
+func GetSimilarBooks(c *gin.Context) {
+	var uri struct {
+		ID int `uri:"id" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	var query struct {
+		Limit int `form:"limit,default=5"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil || query.Limit < 1 || query.Limit > SimilarNeighbors {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", SimilarNeighbors)})
+		return
+	}
+
+	results, err := catalogFrom(c).similarBooks(c.Request.Context(), uri.ID, query.Limit)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"book_id": uri.ID,
+		"data":    results,
+		"count":   len(results),
+	})
+}
@@ -0,0 +1,182 @@
+package ginapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareBooks(t *testing.T) {
+	gopl := Book{ID: 1, Title: "The Go Programming Language", Author: "Donovan & Kernighan", Year: 2015}
+	cpl := Book{ID: 2, Title: "The C Programming Language", Author: "Kernighan & Ritchie", Year: 1978}
+	goInAction := Book{ID: 3, Title: "Go in Action", Author: "Kennedy", Year: 2015}
+	cooking := Book{ID: 4, Title: "Salt Fat Acid Heat", Author: "Nosrat", Year: 2017}
+
+	n := compareBooks(gopl, cpl)
+	expected := []string{
+		"same author: Kernighan",
+		"shared title words: language, programming",
+		"similar titles (93% alike)",
+	}
+	if !reflect.DeepEqual(n.reasons, expected) {
+		t.Errorf("Unexpected reasons %q", n.reasons)
+	}
+
+	// Sharing an author beats sharing a year
+	if other := compareBooks(gopl, goInAction); other.score >= n.score {
+		t.Errorf("Expected %v < %v", other.score, n.score)
+	}
+	if n := compareBooks(gopl, cooking); n.score >= MinSimilarity {
+		t.Errorf("Expected unrelated books below %v, got %v (%q)", MinSimilarity, n.score, n.reasons)
+	}
+
+	// Author matching ignores case and order
+	a := Book{ID: 5, Title: "X", Author: "ann LEE & Bob", Year: 2000}
+	b := Book{ID: 6, Title: "Y", Author: "Ann Lee", Year: 1900}
+	if shared := sharedAuthors(a, b); !reflect.DeepEqual(shared, []string{"Ann Lee"}) {
+		t.Errorf("Unexpected shared authors %q", shared)
+	}
+}
+
+func TestGetSimilarBooks(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	cat := defaultTenant().catalog
+	ctx := context.Background()
+	cat.insert(ctx, Book{Title: "The Go Programming Language", Author: "Donovan & Kernighan", Year: 2015})
+	cat.insert(ctx, Book{Title: "The C Programming Language", Author: "Kernighan & Ritchie", Year: 1978})
+	cat.insert(ctx, Book{Title: "Go in Action", Author: "Kennedy", Year: 2015})
+	cat.insert(ctx, Book{Title: "Salt Fat Acid Heat", Author: "Nosrat", Year: 2017})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/books/1/similar", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Data  []SimilarBook `json:"data"`
+		Count int           `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Count != 2 || response.Data[0].Book.ID != 2 || response.Data[1].Book.ID != 3 {
+		t.Fatalf("Unexpected recommendations: %+v", response.Data)
+	}
+	if len(response.Data[0].Reasons) == 0 || response.Data[0].Score <= response.Data[1].Score {
+		t.Errorf("Expected explained, ranked results: %+v", response.Data)
+	}
+
+	// Writes update the index: after deleting book 2 it is no longer recommended
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/v1/books/2", nil)
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1/similar", nil)
+	router.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), "The C Programming Language") {
+		t.Errorf("Deleted book still recommended: %s", w.Body.String())
+	}
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{path: "/api/v1/books/99/similar", expectedStatus: http.StatusNotFound},
+		{path: "/api/v1/books/1/similar?limit=0", expectedStatus: http.StatusBadRequest},
+		{path: "/api/v1/books/1/similar?limit=1", expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		router.ServeHTTP(w, req)
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.expectedStatus, w.Code)
+		}
+	}
+}
+
+// The incrementally maintained index must always equal a full rebuild
+func TestSimilarIndex_MatchesRebuild(t *testing.T) {
+	defer func(old int) { SimilarNeighbors = old }(SimilarNeighbors)
+	SimilarNeighbors = 3
+
+	ctx := context.Background()
+	cat := newCatalog(0)
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"Go", "Rust", "Programming", "Language", "Patterns", "Systems", "Concurrency"}
+	authors := []string{"Ann", "Bob", "Cid", "Ann & Bob"}
+	randomBook := func() Book {
+		title := words[rng.Intn(len(words))] + " " + words[rng.Intn(len(words))]
+		return Book{Title: title, Author: authors[rng.Intn(len(authors))], Year: 2000 + rng.Intn(12)}
+	}
+
+	for step := 0; step < 300; step++ {
+		ids := make([]int, 0)
+		cat.books.each(func(b Book) bool {
+			ids = append(ids, b.ID)
+			return true
+		})
+
+		switch op := rng.Intn(3); {
+		case op == 0 || len(ids) < 5:
+			cat.insert(ctx, randomBook())
+		case op == 1:
+			changed := randomBook()
+			cat.modify(ctx, ids[rng.Intn(len(ids))], func(b *Book) {
+				b.Title, b.Author, b.Year = changed.Title, changed.Author, changed.Year
+			})
+		default:
+			cat.remove(ctx, ids[rng.Intn(len(ids))])
+		}
+
+		// Let stale books pile up now and then, so refreshes catch up
+		// for several at once
+		if rng.Intn(3) > 0 && step < 299 {
+			continue
+		}
+		cat.refreshSimilar()
+		books, _ := cat.list(ctx)
+		for _, book := range books {
+			got, expected := cat.similar[book.ID], neighborsOf(book, books)
+			if fmt.Sprint(got) != fmt.Sprint(expected) {
+				t.Fatalf("Step %d, book %d: index %v, rebuild %v", step, book.ID, got, expected)
+			}
+		}
+	}
+}
+
+func TestSimilarIndex_StaleMarks(t *testing.T) {
+	ctx := context.Background()
+	cat := newCatalog(0)
+	cat.insert(ctx, Book{Title: "Go in Action", Author: "Kennedy", Year: 2015})
+	cat.insert(ctx, Book{Title: "Go in Practice", Author: "Kennedy", Year: 2016})
+
+	// Writes leave the index alone until it is refreshed
+	if len(cat.similar) != 0 || len(cat.stale) != 2 {
+		t.Fatalf("Expected 2 stale books and no index yet, got %v and %v", cat.stale, cat.similar)
+	}
+	cat.refreshSimilar()
+	if len(cat.stale) != 0 || len(cat.similar[1]) != 1 {
+		t.Fatalf("Expected the refresh to index both books, got %v", cat.similar)
+	}
+
+	// Fields the index ignores do not make a book stale
+	cat.modify(ctx, 1, func(b *Book) { b.ISBN = "978-1617291784" })
+	if len(cat.stale) != 0 {
+		t.Errorf("Expected an ISBN change to leave the index alone, got %v", cat.stale)
+	}
+	cat.modify(ctx, 1, func(b *Book) { b.Year = 2014 })
+	if !cat.stale[1] {
+		t.Errorf("Expected a year change to mark the book stale, got %v", cat.stale)
+	}
+}
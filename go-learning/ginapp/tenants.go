@@ -0,0 +1,320 @@
+package ginapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// MULTI-TENANCY
+// ============================================================
+// One deployment serves several isolated catalogs. Every request is
+// resolved to a tenant by TenantMiddleware, and handlers only ever see
+// that tenant's catalog: books, IDs and stats never leak between tenants.
+//
+// Resolution order:
+//  1. X-Tenant-ID header
+//  2. "tenant_id" claim of a JWT bearer token signed with TenantJWTSecret
+//  3. subdomain of TenantBaseDomain (acme.localhost -> acme)
+//  4. DefaultTenantID
+//
+// Anyone may pick a tenant, but credentials are bound to one: accounts
+// to the tenant they registered with, API keys to the tenant they were
+// minted for. Using them with another tenant is refused with 403
+// (see authenticate and APIKeyMiddleware).
+// ============================================================
+
+// DefaultTenantID is used when a request does not name a tenant
+const DefaultTenantID = "default"
+
+// TenantBaseDomain - hosts of the form <tenant>.<TenantBaseDomain> select
+// a tenant by subdomain
+var TenantBaseDomain = "localhost"
+
+// TenantJWTSecret - HMAC key of the identity provider issuing JWTs with a
+// tenant_id claim. Claims of tokens not signed with it (HS256) are
+// ignored; while it is empty, JWTs never select a tenant.
+var TenantJWTSecret []byte
+
+// errWrongTenant - credentials used with a tenant they do not belong to
+var errWrongTenant = errors.New("Credentials belong to another tenant")
+
+// Tenant is an isolated book catalog with its own quota
+type Tenant struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	MaxBooks  int       `json:"max_books"`
+	CreatedAt time.Time `json:"created_at"`
+
+	catalog *catalog
+}
+
+// CreateTenantInput - input for creating a tenant
+type CreateTenantInput struct {
+	ID       string `json:"id" binding:"required"`
+	Name     string `json:"name" binding:"required,min=1,max=100"`
+	MaxBooks int    `json:"max_books" binding:"gte=0"`
+}
+
+// Tenant registry
+var (
+	tenants   = map[string]*Tenant{DefaultTenantID: newTenant(DefaultTenantID, "Default", 0)}
+	tenantsMu sync.RWMutex
+)
+
+// tenantIDPattern keeps tenant IDs usable as DNS labels
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// newTenant creates a tenant with an empty catalog
+func newTenant(id, name string, maxBooks int) *Tenant {
+	cat := newCatalog(maxBooks)
+	cat.onChange = func(event string, book Book) {
+		publishWebhookEvent(id, event, book)
+	}
+	return &Tenant{
+		ID:        id,
+		Name:      name,
+		MaxBooks:  maxBooks,
+		CreatedAt: time.Now(),
+		catalog:   cat,
+	}
+}
+
+// lookupTenant finds a registered tenant by ID
+func lookupTenant(id string) (*Tenant, bool) {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+
+	tenant, exists := tenants[id]
+	return tenant, exists
+}
+
+// defaultTenant returns the tenant used when none is requested
+func defaultTenant() *Tenant {
+	tenant, _ := lookupTenant(DefaultTenantID)
+	return tenant
+}
+
+// tenantFrom returns the tenant resolved for this request
+func tenantFrom(c *gin.Context) *Tenant {
+	if value, exists := c.Get("tenant"); exists {
+		return value.(*Tenant)
+	}
+	return defaultTenant()
+}
+
+// catalogFrom returns the catalog of the tenant resolved for this request
+func catalogFrom(c *gin.Context) *catalog {
+	return tenantFrom(c).catalog
+}
+
+// ============================================================
+// TENANT RESOLUTION
+// ============================================================
+
+// TenantMiddleware - resolves the tenant for each request and stores it
+// in the context under "tenant"
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := resolveTenantID(c.Request)
+		if !tenantIDPattern.MatchString(id) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+			c.Abort()
+			return
+		}
+
+		tenant, exists := lookupTenant(id)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+			c.Abort()
+			return
+		}
+
+		c.Set("tenant", tenant)
+		c.Header("X-Tenant-ID", tenant.ID)
+		c.Next()
+	}
+}
+
+// resolveTenantID applies the resolution order described above
+func resolveTenantID(r *http.Request) string {
+	if id := r.Header.Get("X-Tenant-ID"); id != "" {
+		return strings.ToLower(id)
+	}
+	if id := tenantFromJWT(r.Header.Get("Authorization")); id != "" {
+		return strings.ToLower(id)
+	}
+	if id := tenantFromHost(r.Host); id != "" {
+		return id
+	}
+	return DefaultTenantID
+}
+
+// tenantFromJWT reads the "tenant_id" claim from a bearer JWT, once its
+// HS256 signature checks out against TenantJWTSecret and it has not
+// expired. Anything else yields "".
+func tenantFromJWT(header string) string {
+	if len(TenantJWTSecret) == 0 {
+		return ""
+	}
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return ""
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	if !decodeJWTPart(parts[0], &head) || head.Alg != "HS256" {
+		return ""
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, TenantJWTSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ""
+	}
+
+	var claims struct {
+		TenantID string `json:"tenant_id"`
+		Expires  int64  `json:"exp"`
+	}
+	if !decodeJWTPart(parts[1], &claims) {
+		return ""
+	}
+	if claims.Expires != 0 && time.Now().Unix() >= claims.Expires {
+		return ""
+	}
+	return claims.TenantID
+}
+
+// decodeJWTPart decodes a base64url JSON segment of a JWT into v
+func decodeJWTPart(part string, v interface{}) bool {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
+
+// tenantFromHost extracts "acme" from "acme.<TenantBaseDomain>[:port]"
+func tenantFromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	if TenantBaseDomain == "" {
+		return ""
+	}
+	prefix, found := strings.CutSuffix(host, "."+TenantBaseDomain)
+	if !found || strings.Contains(prefix, ".") {
+		return ""
+	}
+	return prefix
+}
+
+// ============================================================
+// TENANT ADMIN HANDLERS
+// ============================================================
+
+// tenantSummary - tenant details plus live catalog stats
+func tenantSummary(t *Tenant) gin.H {
+	return gin.H{
+		"id":          t.ID,
+		"name":        t.Name,
+		"max_books":   t.MaxBooks,
+		"total_books": t.catalog.count(),
+		"created_at":  t.CreatedAt,
+	}
+}
+
+// CreateTenant - POST /api/v1/admin/tenants
+func CreateTenant(c *gin.Context) {
+	var input CreateTenantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	input.ID = strings.ToLower(input.ID)
+	if !tenantIDPattern.MatchString(input.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tenant ID must be 1-32 lowercase letters, digits or hyphens"})
+		return
+	}
+
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+
+	if _, exists := tenants[input.ID]; exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tenant already exists"})
+		return
+	}
+
+	tenant := newTenant(input.ID, input.Name, input.MaxBooks)
+	tenants[tenant.ID] = tenant
+	c.JSON(http.StatusCreated, gin.H{"data": tenantSummary(tenant)})
+}
+
+// ListTenants - GET /api/v1/admin/tenants
+func ListTenants(c *gin.Context) {
+	tenantsMu.RLock()
+	list := make([]*Tenant, 0, len(tenants))
+	for _, t := range tenants {
+		list = append(list, t)
+	}
+	tenantsMu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	data := make([]gin.H, len(list))
+	for i, t := range list {
+		data[i] = tenantSummary(t)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  data,
+		"count": len(data),
+	})
+}
+
+// DeleteTenant - DELETE /api/v1/admin/tenants/:tenant
+// Its covers, webhooks, accounts and API keys go with it.
+func DeleteTenant(c *gin.Context) {
+	id := strings.ToLower(c.Param("tenant"))
+	if id == DefaultTenantID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default tenant cannot be deleted"})
+		return
+	}
+
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+
+	if _, exists := tenants[id]; !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+
+	delete(tenants, id)
+	removeTenantCovers(id)
+	deleteTenantWebhooks(id)
+	deleteTenantAccounts(id)
+	revokeTenantAPIKeys(id)
+	c.JSON(http.StatusOK, gin.H{"message": "Tenant deleted successfully"})
+}
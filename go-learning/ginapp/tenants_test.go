@@ -0,0 +1,330 @@
+package ginapp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// createTestTenant registers a tenant through the admin API
+func createTestTenant(t *testing.T, router http.Handler, id string, maxBooks int) {
+	t.Helper()
+
+	body, _ := json.Marshal(CreateTenantInput{ID: id, Name: id, MaxBooks: maxBooks})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/tenants", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create tenant %q: %d %s", id, w.Code, w.Body.String())
+	}
+}
+
+// createTenantBook posts a book for the given tenant and returns the status code
+func createTenantBook(router http.Handler, tenant, title string) int {
+	body, _ := json.Marshal(CreateBookInput{Title: title, Author: "Author", Year: 2024})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/books", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	if tenant != "" {
+		req.Header.Set("X-Tenant-ID", tenant)
+	}
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestTenantIsolation(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	createTestTenant(t, router, "acme", 0)
+
+	createTenantBook(router, "", "Default Book")
+	createTenantBook(router, "acme", "Acme Book 1")
+	createTenantBook(router, "acme", "Acme Book 2")
+
+	// IDs are allocated per tenant
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/books/2", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	router.ServeHTTP(w, req)
+
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"]["title"] != "Acme Book 2" {
+		t.Errorf("Expected 'Acme Book 2', got %v", response["data"]["title"])
+	}
+
+	// The default tenant never sees acme's books
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/2", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for other tenant's book, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	createTestTenant(t, router, "small", 1)
+
+	if code := createTenantBook(router, "small", "First"); code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, code)
+	}
+	if code := createTenantBook(router, "small", "Second"); code != http.StatusForbidden {
+		t.Errorf("Expected status %d once quota is reached, got %d", http.StatusForbidden, code)
+	}
+}
+
+func TestTenantMiddleware_Resolution(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	createTestTenant(t, router, "acme", 0)
+
+	defer func(old []byte) { TenantJWTSecret = old }(TenantJWTSecret)
+	TenantJWTSecret = []byte("test-secret")
+	jwt := signTestJWT(TenantJWTSecret, `{"sub":"bob","tenant_id":"acme"}`)
+	unsignedJWT := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"tenant_id":"acme"}`)) + "."
+
+	tests := []struct {
+		name           string
+		header         string
+		value          string
+		host           string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{name: "default", expectedStatus: http.StatusOK, expectedTenant: DefaultTenantID},
+		{name: "header", header: "X-Tenant-ID", value: "acme", expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "jwt claim", header: "Authorization", value: "Bearer " + jwt, expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "jwt other key", header: "Authorization", value: "Bearer " + signTestJWT([]byte("forged"), `{"tenant_id":"acme"}`), expectedStatus: http.StatusOK, expectedTenant: DefaultTenantID},
+		{name: "jwt unsigned", header: "Authorization", value: "Bearer " + unsignedJWT, expectedStatus: http.StatusOK, expectedTenant: DefaultTenantID},
+		{name: "jwt expired", header: "Authorization", value: "Bearer " + signTestJWT(TenantJWTSecret, `{"tenant_id":"acme","exp":1}`), expectedStatus: http.StatusOK, expectedTenant: DefaultTenantID},
+		{name: "subdomain", host: "acme.localhost:8081", expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "unknown tenant", header: "X-Tenant-ID", value: "nobody", expectedStatus: http.StatusNotFound},
+		{name: "invalid tenant", header: "X-Tenant-ID", value: "../etc", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/books", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedTenant != "" && w.Header().Get("X-Tenant-ID") != tt.expectedTenant {
+				t.Errorf("Expected tenant %q, got %q", tt.expectedTenant, w.Header().Get("X-Tenant-ID"))
+			}
+		})
+	}
+}
+
+// signTestJWT returns an HS256 JWT with the given claims
+func signTestJWT(secret []byte, claims string) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestTenantBinding(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	resetAPIKeys()
+	createTestTenant(t, router, "acme", 0)
+
+	// Accounts belong to the tenant they registered with
+	w := httptest.NewRecorder()
+	body, _ := json.Marshal(CredentialsInput{Username: "dana", Password: "paper-lantern-3"})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "acme")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"tenant":"acme"`) {
+		t.Fatalf("Expected dana registered with acme, got %d: %s", w.Code, w.Body.String())
+	}
+	var login struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	w = postCredentials(router, "/api/v1/auth/login", "dana", "paper-lantern-3")
+	if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil || login.Data.Token == "" {
+		t.Fatalf("Login failed: %d: %s", w.Code, w.Body.String())
+	}
+	acmeUser := login.Data.Token
+	defaultUser := loginToken(t, router, "erin", "quiet-harbor-8")
+
+	_, acmeKey, err := mintAPIKey(CreateAPIKeyInput{Name: "acme-job", Scopes: []string{ScopeBooksRead}}, testAdmin, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		tenant         string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{name: "acme user in acme", tenant: "acme", header: "Authorization", value: "Bearer " + acmeUser, expectedStatus: http.StatusOK},
+		{name: "acme user in default", header: "Authorization", value: "Bearer " + acmeUser, expectedStatus: http.StatusForbidden},
+		{name: "default user in default", header: "Authorization", value: "Bearer " + defaultUser, expectedStatus: http.StatusOK},
+		{name: "default user in acme", tenant: "acme", header: "Authorization", value: "Bearer " + defaultUser, expectedStatus: http.StatusForbidden},
+		{name: "admin in acme", tenant: "acme", header: "Authorization", value: "Bearer " + adminToken(t), expectedStatus: http.StatusOK},
+		{name: "acme key in acme", tenant: "acme", header: "X-API-Key", value: acmeKey, expectedStatus: http.StatusOK},
+		{name: "acme key in default", header: "X-API-Key", value: acmeKey, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/auth/me", nil)
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant-ID", tt.tenant)
+			}
+			req.Header.Set(tt.header, tt.value)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTenantAdmin(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	createTestTenant(t, router, "acme", 10)
+
+	// Duplicate IDs are rejected
+	body, _ := json.Marshal(CreateTenantInput{ID: "acme", Name: "Again"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/tenants", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	// List shows both tenants
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/admin/tenants", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if int(response["count"].(float64)) != 2 {
+		t.Errorf("Expected 2 tenants, got %v", response["count"])
+	}
+
+	// The default tenant is permanent
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/v1/admin/tenants/default", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Deleting a tenant makes it unresolvable
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/v1/admin/tenants/acme", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if code := createTenantBook(router, "acme", "Gone"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for deleted tenant, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestDeleteTenant_RevokesCredentials(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	resetAPIKeys()
+	createTestTenant(t, router, "acme", 0)
+	defer func(old bool) { AllowDemoTokens = old }(AllowDemoTokens)
+	AllowDemoTokens = false
+
+	credentials := `{"username": "dana", "password": "paper-lantern-3"}`
+	if w := apiRequest(router, "POST", "/api/v1/auth/register", credentials, "X-Tenant-ID", "acme"); w.Code != http.StatusCreated {
+		t.Fatalf("Register failed: %d: %s", w.Code, w.Body.String())
+	}
+	var login struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	w := postCredentials(router, "/api/v1/auth/login", "dana", "paper-lantern-3")
+	if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil || login.Data.Token == "" {
+		t.Fatalf("Login failed: %d: %s", w.Code, w.Body.String())
+	}
+	_, key, err := mintAPIKey(CreateAPIKeyInput{Name: "acme-job", Scopes: []string{ScopeBooksRead}}, testAdmin, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A tenant recreated under the same ID starts without credentials
+	if w := apiRequest(router, "DELETE", "/api/v1/admin/tenants/acme", "", "Authorization", "Bearer "+adminToken(t)); w.Code != http.StatusOK {
+		t.Fatalf("Delete failed: %d: %s", w.Code, w.Body.String())
+	}
+	createTestTenant(t, router, "acme", 0)
+
+	tests := []struct {
+		name           string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{name: "old session", header: "Authorization", value: "Bearer " + login.Data.Token, expectedStatus: http.StatusUnauthorized},
+		{name: "old key", header: "X-API-Key", value: key, expectedStatus: http.StatusUnauthorized},
+		{name: "admin", header: "Authorization", value: "Bearer " + adminToken(t), expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := apiRequest(router, "GET", "/api/v1/auth/me", "", "X-Tenant-ID", "acme", tt.header, tt.value)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// The old password no longer logs in and the name is free again
+	if w := postCredentials(router, "/api/v1/auth/login", "dana", "paper-lantern-3"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for the old account, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := apiRequest(router, "POST", "/api/v1/auth/register", credentials, "X-Tenant-ID", "acme"); w.Code != http.StatusCreated {
+		t.Errorf("Expected the username to be free, got %d: %s", w.Code, w.Body.String())
+	}
+}
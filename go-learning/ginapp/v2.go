@@ -0,0 +1,345 @@
+package ginapp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// API V2 - Richer Book Representation
+// ============================================================
+// v2 replaces the single "author" string with structured authors and
+// adds publisher, language and tags. Both versions are served from the
+// same store: v1 handlers keep reading and writing Book.Author, while
+// v2 handlers translate to and from BookV2 below.
+// ============================================================
+
+// Author is a structured author record (v2)
+type Author struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+	Role string `json:"role,omitempty" binding:"omitempty,oneof=author editor translator illustrator"`
+}
+
+// BookV2 is the v2 representation of a book
+type BookV2 struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Authors   []Author  `json:"authors"`
+	Publisher string    `json:"publisher,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	Tags      []string  `json:"tags"`
+	Year      int       `json:"year"`
+	ISBN      string    `json:"isbn,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AverageRating float64 `json:"average_rating"`
+	RatingCount   int     `json:"rating_count"`
+}
+
+// CreateBookV2Input - input for creating a book through v2
+type CreateBookV2Input struct {
+	Title     string   `json:"title" binding:"required,min=1,max=200"`
+	Authors   []Author `json:"authors" binding:"required,min=1,dive"`
+	Publisher string   `json:"publisher,omitempty" binding:"omitempty,max=200"`
+	Language  string   `json:"language,omitempty" binding:"omitempty,bcp47_language_tag"`
+	Tags      []string `json:"tags,omitempty" binding:"omitempty,max=20,dive,min=1,max=50"`
+	Year      int      `json:"year" binding:"required,gte=1000,lte=2100"`
+	ISBN      string   `json:"isbn,omitempty"`
+}
+
+// UpdateBookV2Input - input for updating a book through v2 (all fields optional)
+type UpdateBookV2Input struct {
+	Title     *string   `json:"title,omitempty" binding:"omitempty,min=1,max=200"`
+	Authors   *[]Author `json:"authors,omitempty" binding:"omitempty,min=1,dive"`
+	Publisher *string   `json:"publisher,omitempty" binding:"omitempty,max=200"`
+	Language  *string   `json:"language,omitempty" binding:"omitempty,bcp47_language_tag"`
+	Tags      *[]string `json:"tags,omitempty" binding:"omitempty,max=20,dive,min=1,max=50"`
+	Year      *int      `json:"year,omitempty" binding:"omitempty,gte=1000,lte=2100"`
+	ISBN      *string   `json:"isbn,omitempty"`
+}
+
+// ============================================================
+// TRANSLATION BETWEEN V1 AND V2
+// ============================================================
+
+// splitAuthors turns a v1 author string like "Donovan & Kernighan"
+// into structured v2 authors
+func splitAuthors(author string) []Author {
+	normalized := strings.ReplaceAll(author, " and ", " & ")
+	authors := make([]Author, 0)
+	for _, name := range strings.Split(normalized, "&") {
+		if name = strings.TrimSpace(name); name != "" {
+			authors = append(authors, Author{Name: name})
+		}
+	}
+	return authors
+}
+
+// joinAuthors builds the v1 author string from structured v2 authors
+func joinAuthors(authors []Author) string {
+	names := make([]string, len(authors))
+	for i, a := range authors {
+		names[i] = a.Name
+	}
+	return strings.Join(names, " & ")
+}
+
+// toBookV2 converts a stored book to its v2 representation
+func toBookV2(b Book) BookV2 {
+	authors := b.Authors
+	if len(authors) == 0 {
+		// Books written before v2 existed only carry the v1 string
+		authors = splitAuthors(b.Author)
+	}
+	tags := b.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return BookV2{
+		ID:        b.ID,
+		Title:     b.Title,
+		Authors:   authors,
+		Publisher: b.Publisher,
+		Language:  b.Language,
+		Tags:      tags,
+		Year:      b.Year,
+		ISBN:      b.ISBN,
+		CreatedAt: b.CreatedAt,
+
+		AverageRating: b.AverageRating,
+		RatingCount:   b.RatingCount,
+	}
+}
+
+// toBook converts v2 create input to a storable book
+func (in CreateBookV2Input) toBook() Book {
+	return Book{
+		Title:     in.Title,
+		Author:    joinAuthors(in.Authors),
+		Authors:   in.Authors,
+		Publisher: in.Publisher,
+		Language:  in.Language,
+		Tags:      normalizeTags(in.Tags),
+		Year:      in.Year,
+		ISBN:      in.ISBN,
+	}
+}
+
+// apply copies the provided v2 fields onto a stored book
+func (in UpdateBookV2Input) apply(book *Book) {
+	if in.Title != nil {
+		book.Title = *in.Title
+	}
+	if in.Authors != nil {
+		book.Authors = *in.Authors
+		book.Author = joinAuthors(*in.Authors)
+	}
+	if in.Publisher != nil {
+		book.Publisher = *in.Publisher
+	}
+	if in.Language != nil {
+		book.Language = *in.Language
+	}
+	if in.Tags != nil {
+		book.Tags = normalizeTags(*in.Tags)
+	}
+	if in.Year != nil {
+		book.Year = *in.Year
+	}
+	if in.ISBN != nil {
+		book.ISBN = *in.ISBN
+	}
+}
+
+// normalizeTags lowercases tags and drops duplicates, keeping order
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// ============================================================
+// V2 HANDLERS
+// ============================================================
+
+// GetBooksV2 - GET /api/v2/books?tag=...&language=...&sort=...
+func GetBooksV2(c *gin.Context) {
+	tag := strings.ToLower(c.Query("tag"))
+	language := c.Query("language")
+
+	stored, err := catalogFrom(c).list(c.Request.Context())
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if err := sortBooks(stored, c.Query("sort")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookList := make([]BookV2, 0)
+	for _, b := range stored {
+		v2 := toBookV2(b)
+		if language != "" && !strings.EqualFold(v2.Language, language) {
+			continue
+		}
+		if tag != "" && !containsString(v2.Tags, tag) {
+			continue
+		}
+		bookList = append(bookList, v2)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  bookList,
+		"count": len(bookList),
+	})
+}
+
+// GetBookV2 - GET /api/v2/books/:id
+func GetBookV2(c *gin.Context) {
+	var uri struct {
+		ID int `uri:"id" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	book, err := catalogFrom(c).find(c.Request.Context(), uri.ID)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": toBookV2(book)})
+}
+
+// CreateBookV2 - POST /api/v2/books
+func CreateBookV2(c *gin.Context) {
+	var input CreateBookV2Input
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	book, err := catalogFrom(c).insert(c.Request.Context(), input.toBook())
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": toBookV2(book)})
+}
+
+// UpdateBookV2 - PUT /api/v2/books/:id
+func UpdateBookV2(c *gin.Context) {
+	var uri struct {
+		ID int `uri:"id" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	var input UpdateBookV2Input
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	book, err := catalogFrom(c).modify(c.Request.Context(), uri.ID, input.apply)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": toBookV2(book)})
+}
+
+// containsString reports whether s is in list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================
+// DEPRECATION AND USAGE TRACKING
+// ============================================================
+
+// Deprecation schedule for API v1
+var (
+	V1DeprecatedAt = time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	V1SunsetAt     = time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// DeprecationMiddleware - announces that a route group is deprecated.
+// Deprecation uses the RFC 9745 "@<unix-seconds>" form, Sunset (RFC 8594)
+// an HTTP-date, and Link points clients at the successor version.
+func DeprecationMiddleware(deprecatedAt, sunsetAt time.Time, successor string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", deprecatedAt.Unix())
+	sunset := sunsetAt.UTC().Format(http.TimeFormat)
+	link := fmt.Sprintf("<%s>; rel=\"successor-version\"", successor)
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunset)
+		c.Header("Link", link)
+		c.Next()
+	}
+}
+
+// VersionUsage - request counters for one API version
+type VersionUsage struct {
+	Requests int64     `json:"requests"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+var (
+	apiUsage   = make(map[string]VersionUsage)
+	apiUsageMu sync.Mutex
+)
+
+// APIUsageMiddleware - counts requests per API version so we can tell
+// when an old version is no longer used
+func APIUsageMiddleware(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiUsageMu.Lock()
+		usage := apiUsage[version]
+		usage.Requests++
+		usage.LastSeen = time.Now()
+		apiUsage[version] = usage
+		apiUsageMu.Unlock()
+
+		c.Next()
+	}
+}
+
+// APIUsageSnapshot returns a copy of the per-version usage counters
+func APIUsageSnapshot() map[string]VersionUsage {
+	apiUsageMu.Lock()
+	defer apiUsageMu.Unlock()
+
+	snapshot := make(map[string]VersionUsage, len(apiUsage))
+	for version, usage := range apiUsage {
+		snapshot[version] = usage
+	}
+	return snapshot
+}
@@ -0,0 +1,214 @@
+package ginapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateBookV2_VisibleInV1(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"title":     "The Go Programming Language",
+		"authors":   []map[string]string{{"name": "Alan Donovan"}, {"name": "Brian Kernighan"}},
+		"publisher": "Addison-Wesley",
+		"language":  "en",
+		"tags":      []string{"Go", "programming", "go"},
+		"year":      2015,
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v2/books", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var created struct {
+		Data BookV2 `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(created.Data.Tags) != 2 || created.Data.Tags[0] != "go" {
+		t.Errorf("Expected normalized tags [go programming], got %v", created.Data.Tags)
+	}
+
+	// The same book read through v1 has a flat author string
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/books/1", nil)
+	router.ServeHTTP(w, req)
+
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"]["author"] != "Alan Donovan & Brian Kernighan" {
+		t.Errorf("Unexpected v1 author: %v", response["data"]["author"])
+	}
+	if _, exists := response["data"]["publisher"]; exists {
+		t.Error("v1 response must not expose v2-only fields")
+	}
+}
+
+func TestCreateBookV1_VisibleInV2(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+
+	body, _ := json.Marshal(CreateBookInput{Title: "Go", Author: "Donovan & Kernighan", Year: 2015})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/books", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v2/books/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Data BookV2 `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Data.Authors) != 2 || response.Data.Authors[1].Name != "Kernighan" {
+		t.Errorf("Expected two structured authors, got %+v", response.Data.Authors)
+	}
+}
+
+func TestUpdateBookV2(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "Old", Author: "Someone", Year: 2020})
+
+	body := []byte(`{"authors":[{"name":"New Author","role":"editor"}],"tags":["Rust"]}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v2/books/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	book, _ := defaultTenant().catalog.find(context.Background(), 1)
+	if book.Author != "New Author" {
+		t.Errorf("Expected v1 author to follow v2 update, got %q", book.Author)
+	}
+	if book.Title != "Old" {
+		t.Errorf("Title should be unchanged, got %q", book.Title)
+	}
+}
+
+func TestCreateBookV2_ValidationError(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing authors", body: `{"title":"T","year":2020}`},
+		{name: "empty authors", body: `{"title":"T","authors":[],"year":2020}`},
+		{name: "author without name", body: `{"title":"T","authors":[{"role":"editor"}],"year":2020}`},
+		{name: "bad role", body: `{"title":"T","authors":[{"name":"A","role":"ghost"}],"year":2020}`},
+		{name: "bad language", body: `{"title":"T","authors":[{"name":"A"}],"language":"not a tag","year":2020}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v2/books", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetBooksV2_Filters(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "A", Author: "X", Year: 2020, Language: "en", Tags: []string{"go"}})
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "B", Author: "Y", Year: 2021, Language: "de", Tags: []string{"go"}})
+	defaultTenant().catalog.insert(context.Background(), Book{Title: "C", Author: "Z", Year: 2022, Language: "en", Tags: []string{"rust"}})
+
+	tests := []struct {
+		query         string
+		expectedCount int
+	}{
+		{query: "", expectedCount: 3},
+		{query: "?tag=go", expectedCount: 2},
+		{query: "?language=en", expectedCount: 2},
+		{query: "?tag=go&language=en", expectedCount: 1},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v2/books"+tt.query, nil)
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if int(response["count"].(float64)) != tt.expectedCount {
+			t.Errorf("%q: expected count %d, got %v", tt.query, tt.expectedCount, response["count"])
+		}
+	}
+}
+
+func TestV1DeprecationHeaders(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/books", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Deprecation") == "" {
+		t.Error("Expected Deprecation header on v1 response")
+	}
+	if w.Header().Get("Sunset") != V1SunsetAt.Format(http.TimeFormat) {
+		t.Errorf("Unexpected Sunset header: %q", w.Header().Get("Sunset"))
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v2/books", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Deprecation") != "" {
+		t.Error("v2 responses must not be marked deprecated")
+	}
+}
+
+func TestAPIUsageCounters(t *testing.T) {
+	router := setupTestRouter()
+	before := APIUsageSnapshot()
+
+	for _, path := range []string{"/api/v1/books", "/api/v2/books", "/api/v2/books"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+	}
+
+	after := APIUsageSnapshot()
+	if after["v1"].Requests-before["v1"].Requests != 1 {
+		t.Errorf("Expected 1 new v1 request, got %d", after["v1"].Requests-before["v1"].Requests)
+	}
+	if after["v2"].Requests-before["v2"].Requests != 2 {
+		t.Errorf("Expected 2 new v2 requests, got %d", after["v2"].Requests-before["v2"].Requests)
+	}
+}
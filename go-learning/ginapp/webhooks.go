@@ -0,0 +1,638 @@
+package ginapp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// WEBHOOKS
+// ============================================================
+// Admins subscribe URLs to book events under /api/v1/admin/webhooks.
+// Every committed create, update and delete in a tenant's catalog
+// becomes an event, queued for each active subscription of that tenant
+// that wants it, and POSTed by the "webhook-dispatcher" worker:
+//
+//   POST <url>
+//   X-Webhook-Event: book.updated
+//   X-Webhook-Timestamp: 1767225600
+//   X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+//   {"id":"evt_...","type":"book.updated","tenant":"default","created_at":"...","data":{...book}}
+//
+// The signing secret is shown once, when the webhook is created;
+// receivers check it with VerifyWebhookSignature. A non-2xx answer or a
+// network error is retried with exponential backoff and jitter, up to
+// WebhookMaxAttempts. After WebhookFailureThreshold deliveries in a row
+// have failed the webhook is marked "failing" and gets no new events
+// until it is re-enabled. Each webhook keeps a log of its latest
+// deliveries with every attempt. A webhook whose receiver falls behind
+// keeps at most WebhookMaxPending deliveries waiting; beyond that the
+// oldest waiting one is dropped and counted in dropped_deliveries.
+// ============================================================
+
+// Book events
+const (
+	EventBookCreated = "book.created"
+	EventBookUpdated = "book.updated"
+	EventBookDeleted = "book.deleted"
+)
+
+// Webhook settings
+var (
+	WebhookTimeout          = 10 * time.Second
+	WebhookMaxAttempts      = 6
+	WebhookRetryBase        = 10 * time.Second // doubled after every failed attempt
+	WebhookRetryMax         = 10 * time.Minute
+	WebhookFailureThreshold = 5    // failed deliveries in a row before a webhook is marked failing
+	WebhookLogSize          = 100  // deliveries kept per webhook
+	WebhookMaxPending       = 1000 // undelivered deliveries kept per webhook
+	WebhookDispatchInterval = time.Second
+	WebhookConcurrency      = 8 // deliveries in flight at once
+	WebhookSignatureMaxAge  = 5 * time.Minute
+)
+
+// Webhook statuses
+const (
+	webhookActive  = "active"
+	webhookFailing = "failing"
+)
+
+// Delivery statuses
+const (
+	deliveryPending   = "pending"
+	deliverySucceeded = "succeeded"
+	deliveryFailed    = "failed"
+)
+
+// Webhook - public view of a subscription; the secret is never part of it
+type Webhook struct {
+	ID                  string     `json:"id"`
+	TenantID            string     `json:"tenant"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Status              string     `json:"status"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	CreatedBy           string     `json:"created_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+	LastDeliveryAt      *time.Time `json:"last_delivery_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	DroppedDeliveries   int        `json:"dropped_deliveries"`
+}
+
+// CreateWebhookInput - input for subscribing a URL. Without events, all
+// events are sent.
+type CreateWebhookInput struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"omitempty,dive,oneof=book.created book.updated book.deleted"`
+}
+
+// WebhookEvent - the JSON body of a delivery
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	TenantID  string      `json:"tenant"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDelivery - one event sent to one webhook
+type WebhookDelivery struct {
+	ID            string           `json:"id"`
+	EventID       string           `json:"event_id"`
+	Event         string           `json:"event"`
+	Status        string           `json:"status"`
+	Attempts      []WebhookAttempt `json:"attempts"`
+	NextAttemptAt *time.Time       `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+
+	webhook *storedWebhook
+	payload []byte
+	sending bool
+}
+
+// WebhookAttempt - one POST of a delivery
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+// storedWebhook is a Webhook with its secret and delivery log
+type storedWebhook struct {
+	Webhook
+	secret     string
+	deliveries []*WebhookDelivery // oldest first
+}
+
+// Webhook store
+var (
+	webhooks        = make(map[string]*storedWebhook) // by ID
+	webhooksMu      sync.Mutex
+	webhookInFlight int
+	webhookSends    sync.WaitGroup
+)
+
+var (
+	errWebhookNotFound = errors.New("Webhook not found")
+	errWebhookURL      = errors.New("url must be an http or https URL")
+)
+
+// newWebhookID returns a random identifier with a prefix
+func newWebhookID(prefix string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// ============================================================
+// SIGNATURES
+// ============================================================
+
+// SignWebhookPayload returns the X-Webhook-Signature value for a body
+// sent at the given Unix time
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the X-Webhook-Timestamp and
+// X-Webhook-Signature headers of a received delivery. Old timestamps are
+// rejected so a captured request cannot be replayed later.
+func VerifyWebhookSignature(secret, timestamp, signature string, payload []byte) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid webhook timestamp")
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > WebhookSignatureMaxAge || age < -WebhookSignatureMaxAge {
+		return errors.New("webhook timestamp too old")
+	}
+	expected := SignWebhookPayload(secret, ts, payload)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("invalid webhook signature")
+	}
+	return nil
+}
+
+// ============================================================
+// STORE
+// ============================================================
+
+// createWebhook stores a subscription and returns it with its secret
+func createWebhook(tenantID string, input CreateWebhookInput, createdBy string) (Webhook, string, error) {
+	target, err := url.Parse(input.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return Webhook{}, "", errWebhookURL
+	}
+
+	events := normalizeScopes(input.Events)
+	if len(events) == 0 {
+		events = []string{EventBookCreated, EventBookDeleted, EventBookUpdated}
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return Webhook{}, "", err
+	}
+	secret := "whsec_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+	id, err := newWebhookID("wh_")
+	if err != nil {
+		return Webhook{}, "", err
+	}
+
+	hook := &storedWebhook{
+		Webhook: Webhook{
+			ID:        id,
+			TenantID:  tenantID,
+			URL:       input.URL,
+			Events:    events,
+			Status:    webhookActive,
+			CreatedBy: createdBy,
+			CreatedAt: time.Now(),
+		},
+		secret: secret,
+	}
+
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	webhooks[hook.ID] = hook
+	return hook.Webhook, secret, nil
+}
+
+// lookupWebhook finds a tenant's webhook. Callers must hold webhooksMu.
+func lookupWebhook(tenantID, id string) (*storedWebhook, error) {
+	hook, exists := webhooks[id]
+	if !exists || hook.TenantID != tenantID {
+		return nil, errWebhookNotFound
+	}
+	return hook, nil
+}
+
+// listWebhooks returns a tenant's webhooks, oldest first
+func listWebhooks(tenantID string) []Webhook {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	list := make([]Webhook, 0)
+	for _, hook := range webhooks {
+		if hook.TenantID == tenantID {
+			list = append(list, hook.Webhook)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// deleteWebhook removes a subscription and drops its pending deliveries
+func deleteWebhook(tenantID, id string) error {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	if _, err := lookupWebhook(tenantID, id); err != nil {
+		return err
+	}
+	delete(webhooks, id)
+	return nil
+}
+
+// deleteTenantWebhooks removes all subscriptions of a deleted tenant
+func deleteTenantWebhooks(tenantID string) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	for id, hook := range webhooks {
+		if hook.TenantID == tenantID {
+			delete(webhooks, id)
+		}
+	}
+}
+
+// enableWebhook makes a failing webhook receive events again
+func enableWebhook(tenantID, id string) (Webhook, error) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	hook, err := lookupWebhook(tenantID, id)
+	if err != nil {
+		return Webhook{}, err
+	}
+	hook.Status = webhookActive
+	hook.ConsecutiveFailures = 0
+	return hook.Webhook, nil
+}
+
+// webhookDeliveries returns copies of a webhook's delivery log, newest
+// first
+func webhookDeliveries(tenantID, id string) ([]WebhookDelivery, error) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	hook, err := lookupWebhook(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]WebhookDelivery, 0, len(hook.deliveries))
+	for i := len(hook.deliveries) - 1; i >= 0; i-- {
+		d := *hook.deliveries[i]
+		d.Attempts = append([]WebhookAttempt(nil), d.Attempts...)
+		list = append(list, d)
+	}
+	return list, nil
+}
+
+// ============================================================
+// DELIVERY
+// ============================================================
+
+// publishWebhookEvent queues an event for every active webhook of the
+// tenant that subscribed to it. It never blocks on the network.
+func publishWebhookEvent(tenantID, eventType string, data interface{}) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	var targets []*storedWebhook
+	for _, hook := range webhooks {
+		if hook.TenantID == tenantID && hook.Status == webhookActive && containsString(hook.Events, eventType) {
+			targets = append(targets, hook)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	eventID, err := newWebhookID("evt_")
+	if err != nil {
+		fmt.Printf("webhook event %s not sent: %v\n", eventType, err)
+		return
+	}
+	event := WebhookEvent{
+		ID:        eventID,
+		Type:      eventType,
+		TenantID:  tenantID,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("webhook event %s not sent: %v\n", eventType, err)
+		return
+	}
+
+	for _, hook := range targets {
+		deliveryID, err := newWebhookID("dlv_")
+		if err != nil {
+			fmt.Printf("webhook event %s not sent to %s: %v\n", eventType, hook.ID, err)
+			continue
+		}
+		now := event.CreatedAt
+		hook.deliveries = append(hook.deliveries, &WebhookDelivery{
+			ID:            deliveryID,
+			EventID:       event.ID,
+			Event:         eventType,
+			Status:        deliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+			webhook:       hook,
+			payload:       payload,
+		})
+		hook.dropPending()
+		hook.trimLog()
+	}
+}
+
+// dropPending drops the oldest waiting deliveries beyond
+// WebhookMaxPending, so a receiver that is down cannot make the queue
+// grow without bound. Deliveries being sent are left alone. Callers must
+// hold webhooksMu.
+func (hook *storedWebhook) dropPending() {
+	pending := 0
+	for _, d := range hook.deliveries {
+		if d.Status == deliveryPending {
+			pending++
+		}
+	}
+	for i := 0; pending > WebhookMaxPending && i < len(hook.deliveries); {
+		if d := hook.deliveries[i]; d.Status != deliveryPending || d.sending {
+			i++
+			continue
+		}
+		hook.deliveries = append(hook.deliveries[:i], hook.deliveries[i+1:]...)
+		hook.DroppedDeliveries++
+		pending--
+	}
+}
+
+// trimLog drops the oldest finished deliveries beyond WebhookLogSize.
+// Callers must hold webhooksMu.
+func (hook *storedWebhook) trimLog() {
+	for len(hook.deliveries) > WebhookLogSize {
+		i := 0
+		for i < len(hook.deliveries) && hook.deliveries[i].Status == deliveryPending {
+			i++
+		}
+		if i == len(hook.deliveries) {
+			return
+		}
+		hook.deliveries = append(hook.deliveries[:i], hook.deliveries[i+1:]...)
+	}
+}
+
+// dispatchWebhooks starts sending every delivery that is due, at most
+// WebhookConcurrency at a time. It returns without waiting for them.
+func dispatchWebhooks(now time.Time) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	var due []*WebhookDelivery
+	for _, hook := range webhooks {
+		for _, d := range hook.deliveries {
+			if d.Status == deliveryPending && !d.sending && !d.NextAttemptAt.After(now) {
+				due = append(due, d)
+			}
+		}
+	}
+	// Oldest first, so a backlog drains in order
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+
+	for _, d := range due {
+		if webhookInFlight >= WebhookConcurrency {
+			return
+		}
+		webhookInFlight++
+		d.sending = true
+		webhookSends.Add(1)
+		go sendWebhook(d, d.webhook.URL, d.webhook.secret)
+	}
+}
+
+// sendWebhook makes one attempt at a delivery
+func sendWebhook(d *WebhookDelivery, target, secret string) {
+	defer webhookSends.Done()
+
+	start := time.Now()
+	attempt := WebhookAttempt{At: start}
+	err := postWebhook(d, target, secret, &attempt)
+	attempt.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	webhookInFlight--
+	d.sending = false
+	d.Attempts = append(d.Attempts, attempt)
+	hook := d.webhook
+	hook.LastDeliveryAt = &attempt.At
+
+	if err == nil {
+		d.Status = deliverySucceeded
+		d.NextAttemptAt = nil
+		hook.ConsecutiveFailures = 0
+		hook.LastError = ""
+		return
+	}
+
+	hook.LastError = attempt.Error
+	if len(d.Attempts) < WebhookMaxAttempts {
+		next := time.Now().Add(webhookBackoff(len(d.Attempts)))
+		d.NextAttemptAt = &next
+		return
+	}
+
+	d.Status = deliveryFailed
+	d.NextAttemptAt = nil
+	hook.ConsecutiveFailures++
+	if hook.ConsecutiveFailures >= WebhookFailureThreshold {
+		hook.Status = webhookFailing
+	}
+}
+
+// postWebhook sends the signed payload and records the response status
+func postWebhook(d *WebhookDelivery, target, secret string, attempt *WebhookAttempt) error {
+	timestamp := attempt.At.Unix()
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(d.payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ginapp-webhooks/1.0")
+	req.Header.Set("X-Webhook-ID", d.webhook.ID)
+	req.Header.Set("X-Webhook-Delivery", d.ID)
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", SignWebhookPayload(secret, timestamp, d.payload))
+
+	client := &http.Client{Timeout: WebhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookBackoff returns the wait after the given number of failed
+// attempts: WebhookRetryBase doubled each time, capped at
+// WebhookRetryMax, with "equal jitter" so receivers that failed together
+// are not retried together
+func webhookBackoff(failedAttempts int) time.Duration {
+	wait := WebhookRetryBase
+	for i := 1; i < failedAttempts && wait < WebhookRetryMax; i++ {
+		wait *= 2
+	}
+	if wait > WebhookRetryMax {
+		wait = WebhookRetryMax
+	}
+	half := wait / 2
+	if half <= 0 {
+		return wait
+	}
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(half)+1))
+	if err != nil {
+		return wait
+	}
+	return half + time.Duration(jitter.Int64())
+}
+
+// ============================================================
+// ADMIN HANDLERS
+// ============================================================
+
+// CreateWebhook - POST /api/v1/admin/webhooks
+func CreateWebhook(c *gin.Context) {
+	var input CreateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	hook, secret, err := createWebhook(tenantFrom(c).ID, input, c.GetString("user"))
+	if errors.Is(err, errWebhookURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    hook,
+		"secret":  secret,
+		"message": "Store this secret now, it will not be shown again",
+	})
+}
+
+// ListWebhooks - GET /api/v1/admin/webhooks
+func ListWebhooks(c *gin.Context) {
+	list := listWebhooks(tenantFrom(c).ID)
+	c.JSON(http.StatusOK, gin.H{
+		"data":  list,
+		"count": len(list),
+	})
+}
+
+// GetWebhook - GET /api/v1/admin/webhooks/:webhookId
+func GetWebhook(c *gin.Context) {
+	webhooksMu.Lock()
+	hook, err := lookupWebhook(tenantFrom(c).ID, c.Param("webhookId"))
+	var view Webhook
+	if err == nil {
+		view = hook.Webhook
+	}
+	webhooksMu.Unlock()
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": view})
+}
+
+// DeleteWebhook - DELETE /api/v1/admin/webhooks/:webhookId
+func DeleteWebhook(c *gin.Context) {
+	if err := deleteWebhook(tenantFrom(c).ID, c.Param("webhookId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// EnableWebhook - POST /api/v1/admin/webhooks/:webhookId/enable
+func EnableWebhook(c *gin.Context) {
+	hook, err := enableWebhook(tenantFrom(c).ID, c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": hook})
+}
+
+// ListWebhookDeliveries - GET /api/v1/admin/webhooks/:webhookId/deliveries
+func ListWebhookDeliveries(c *gin.Context) {
+	list, err := webhookDeliveries(tenantFrom(c).ID, c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":  list,
+		"count": len(list),
+	})
+}
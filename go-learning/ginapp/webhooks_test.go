@@ -0,0 +1,346 @@
+package ginapp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resetWebhooks clears subscriptions and makes retries fast
+func resetWebhooks(t *testing.T) {
+	t.Helper()
+
+	webhookSends.Wait()
+	webhooksMu.Lock()
+	webhooks = make(map[string]*storedWebhook)
+	webhooksMu.Unlock()
+
+	oldBase, oldMax, oldAttempts := WebhookRetryBase, WebhookRetryMax, WebhookMaxAttempts
+	oldThreshold, oldConcurrency := WebhookFailureThreshold, WebhookConcurrency
+	WebhookRetryBase, WebhookRetryMax, WebhookMaxAttempts = time.Millisecond, 4*time.Millisecond, 3
+	WebhookFailureThreshold, WebhookConcurrency = 2, 1
+
+	t.Cleanup(func() {
+		webhookSends.Wait()
+		WebhookRetryBase, WebhookRetryMax, WebhookMaxAttempts = oldBase, oldMax, oldAttempts
+		WebhookFailureThreshold, WebhookConcurrency = oldThreshold, oldConcurrency
+	})
+}
+
+// drainWebhooks runs the dispatcher until no delivery is pending
+func drainWebhooks(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		dispatchWebhooks(time.Now())
+		webhookSends.Wait()
+
+		pending := 0
+		webhooksMu.Lock()
+		for _, hook := range webhooks {
+			for _, d := range hook.deliveries {
+				if d.Status == deliveryPending {
+					pending++
+				}
+			}
+		}
+		webhooksMu.Unlock()
+		if pending == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Webhook deliveries still pending")
+}
+
+// receivedWebhook is a request seen by a test receiver
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver answers deliveries with the statuses from respond
+func webhookReceiver(t *testing.T, respond func(n int) int) (*httptest.Server, func() []receivedWebhook) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []receivedWebhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, receivedWebhook{header: r.Header.Clone(), body: body})
+		n := len(received)
+		mu.Unlock()
+		w.WriteHeader(respond(n))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []receivedWebhook {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedWebhook(nil), received...)
+	}
+}
+
+// subscribe creates a webhook through the admin API and returns its ID
+// and secret
+func subscribe(t *testing.T, router http.Handler, token, body string) (string, string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/webhooks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Subscribe failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data   Webhook `json:"data"`
+		Secret string  `json:"secret"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data.ID, response.Secret
+}
+
+func TestWebhooks_SignedDeliveries(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	resetWebhooks(t)
+
+	receiver, received := webhookReceiver(t, func(int) int { return http.StatusNoContent })
+	token := adminToken(t)
+	id, secret := subscribe(t, router, token, `{"url": "`+receiver.URL+`"}`)
+	if !strings.HasPrefix(secret, "whsec_") {
+		t.Fatalf("Unexpected secret %q", secret)
+	}
+
+	apiRequest(router, "POST", "/api/v1/books", `{"title": "Hooked", "author": "Ann", "year": 2024}`)
+	apiRequest(router, "PUT", "/api/v1/books/1", `{"title": "Hooked Again"}`)
+	apiRequest(router, "DELETE", "/api/v1/books/1", "")
+	drainWebhooks(t)
+
+	deliveries := received()
+	if len(deliveries) != 3 {
+		t.Fatalf("Expected 3 deliveries, got %d", len(deliveries))
+	}
+	expected := []struct{ event, title string }{
+		{EventBookCreated, "Hooked"},
+		{EventBookUpdated, "Hooked Again"},
+		{EventBookDeleted, "Hooked Again"},
+	}
+	for i, d := range deliveries {
+		if err := VerifyWebhookSignature(secret, d.header.Get("X-Webhook-Timestamp"), d.header.Get("X-Webhook-Signature"), d.body); err != nil {
+			t.Errorf("Delivery %d: %v", i, err)
+		}
+		var event struct {
+			Type     string `json:"type"`
+			TenantID string `json:"tenant"`
+			Data     Book   `json:"data"`
+		}
+		json.Unmarshal(d.body, &event)
+		if event.Type != expected[i].event || d.header.Get("X-Webhook-Event") != expected[i].event ||
+			event.Data.Title != expected[i].title || event.TenantID != DefaultTenantID {
+			t.Errorf("Delivery %d: unexpected event %s", i, d.body)
+		}
+	}
+
+	// The log lists the newest delivery first
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/admin/webhooks/"+id+"/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	var log struct {
+		Data []WebhookDelivery `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &log)
+	if len(log.Data) != 3 || log.Data[0].Event != EventBookDeleted || log.Data[0].Status != deliverySucceeded ||
+		len(log.Data[0].Attempts) != 1 || log.Data[0].Attempts[0].StatusCode != http.StatusNoContent {
+		t.Errorf("Unexpected delivery log: %s", w.Body.String())
+	}
+}
+
+func TestWebhooks_RetryAndFailing(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	resetWebhooks(t)
+
+	// The first two attempts fail, then the receiver recovers
+	failUntil := 2
+	receiver, received := webhookReceiver(t, func(n int) int {
+		if n <= failUntil {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	token := adminToken(t)
+	id, _ := subscribe(t, router, token, `{"url": "`+receiver.URL+`", "events": ["book.created"]}`)
+
+	apiRequest(router, "POST", "/api/v1/books", `{"title": "Retry", "author": "Ann", "year": 2024}`)
+	drainWebhooks(t)
+	log, _ := webhookDeliveries(DefaultTenantID, id)
+	if len(log) != 1 || log[0].Status != deliverySucceeded || len(log[0].Attempts) != 3 {
+		t.Fatalf("Expected success on the third attempt, got %+v", log)
+	}
+
+	// Two deliveries that exhaust their attempts mark the webhook failing
+	failUntil = 1 << 30
+	apiRequest(router, "POST", "/api/v1/books", `{"title": "Lost 1", "author": "Ann", "year": 2024}`)
+	apiRequest(router, "POST", "/api/v1/books", `{"title": "Lost 2", "author": "Ann", "year": 2024}`)
+	drainWebhooks(t)
+	log, _ = webhookDeliveries(DefaultTenantID, id)
+	if log[0].Status != deliveryFailed || len(log[0].Attempts) != WebhookMaxAttempts {
+		t.Errorf("Expected a failed delivery after %d attempts, got %+v", WebhookMaxAttempts, log[0])
+	}
+	if hooks := listWebhooks(DefaultTenantID); hooks[0].Status != webhookFailing || hooks[0].LastError == "" {
+		t.Fatalf("Expected a failing webhook, got %+v", hooks[0])
+	}
+
+	// A failing webhook gets no new events until it is re-enabled
+	before := len(received())
+	apiRequest(router, "POST", "/api/v1/books", `{"title": "Skipped", "author": "Ann", "year": 2024}`)
+	drainWebhooks(t)
+	if len(received()) != before {
+		t.Error("Failing webhook received an event")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/webhooks/"+id+"/enable", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"active"`) {
+		t.Errorf("Expected webhook re-enabled, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestWebhooks_PendingCap(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	resetWebhooks(t)
+
+	oldPending := WebhookMaxPending
+	WebhookMaxPending = 2
+	t.Cleanup(func() { WebhookMaxPending = oldPending })
+
+	receiver, received := webhookReceiver(t, func(int) int { return http.StatusOK })
+	token := adminToken(t)
+	id, _ := subscribe(t, router, token, `{"url": "`+receiver.URL+`", "events": ["book.created"]}`)
+
+	// Nothing is dispatched meanwhile, so the oldest two are dropped
+	for _, title := range []string{"One", "Two", "Three", "Four"} {
+		apiRequest(router, "POST", "/api/v1/books", `{"title": "`+title+`", "author": "Ann", "year": 2024}`)
+	}
+	drainWebhooks(t)
+
+	got := received()
+	if len(got) != 2 || !strings.Contains(string(got[0].body), `"Three"`) || !strings.Contains(string(got[1].body), `"Four"`) {
+		t.Fatalf("Expected only the two newest events, got %d deliveries", len(got))
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/admin/webhooks/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"dropped_deliveries":2`) {
+		t.Errorf("Expected 2 dropped deliveries, got %s", w.Body.String())
+	}
+}
+
+func TestWebhooks_Filtering(t *testing.T) {
+	router := setupTestRouter()
+	resetBooks()
+	resetAccounts(t)
+	resetWebhooks(t)
+
+	receiver, received := webhookReceiver(t, func(int) int { return http.StatusOK })
+	token := adminToken(t)
+	subscribe(t, router, token, `{"url": "`+receiver.URL+`", "events": ["book.deleted"]}`)
+
+	// Unsubscribed events, other tenants and rolled back batches send nothing
+	apiRequest(router, "POST", "/api/v1/books", `{"title": "Kept", "author": "Ann", "year": 2024}`)
+	publishWebhookEvent("other", EventBookDeleted, Book{ID: 1})
+	apiRequest(router, "POST", "/api/v1/batch", `{"operations": [{"op": "delete", "id": 1}, {"op": "delete", "id": 99}]}`)
+	drainWebhooks(t)
+	if n := len(received()); n != 0 {
+		t.Fatalf("Expected no deliveries, got %d", n)
+	}
+
+	// A committed batch sends its events
+	apiRequest(router, "POST", "/api/v1/batch", `{"operations": [{"op": "delete", "id": 1}]}`)
+	drainWebhooks(t)
+	if n := len(received()); n != 1 {
+		t.Errorf("Expected 1 delivery, got %d", n)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing url", body: `{}`},
+		{name: "not http", body: `{"url": "ftp://example.com/hook"}`},
+		{name: "unknown event", body: `{"url": "https://example.com/hook", "events": ["book.read"]}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/admin/webhooks", strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	payload := []byte(`{"type":"book.created"}`)
+	now := time.Now().Unix()
+	signature := SignWebhookPayload("whsec_test", now, payload)
+	ts := strconv.FormatInt(now, 10)
+
+	if err := VerifyWebhookSignature("whsec_test", ts, signature, payload); err != nil {
+		t.Errorf("Expected valid signature: %v", err)
+	}
+	if err := VerifyWebhookSignature("whsec_test", ts, signature, []byte(`{"type":"book.deleted"}`)); err == nil {
+		t.Error("Expected tampered body to fail")
+	}
+	if err := VerifyWebhookSignature("whsec_other", ts, signature, payload); err == nil {
+		t.Error("Expected wrong secret to fail")
+	}
+	old := now - int64(2*WebhookSignatureMaxAge/time.Second)
+	if err := VerifyWebhookSignature("whsec_test", strconv.FormatInt(old, 10), SignWebhookPayload("whsec_test", old, payload), payload); err == nil {
+		t.Error("Expected old timestamp to fail")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	defer func(base, max time.Duration) { WebhookRetryBase, WebhookRetryMax = base, max }(WebhookRetryBase, WebhookRetryMax)
+	WebhookRetryBase, WebhookRetryMax = 10*time.Second, time.Minute
+
+	tests := []struct {
+		failed   int
+		min, max time.Duration
+	}{
+		{failed: 1, min: 5 * time.Second, max: 10 * time.Second},
+		{failed: 2, min: 10 * time.Second, max: 20 * time.Second},
+		{failed: 3, min: 20 * time.Second, max: 40 * time.Second},
+		{failed: 10, min: 30 * time.Second, max: time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if wait := webhookBackoff(tt.failed); wait < tt.min || wait > tt.max {
+				t.Errorf("After %d failures: wait %v outside [%v, %v]", tt.failed, wait, tt.min, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ginapp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================
+// BACKGROUND WORKERS
+// ============================================================
+// Periodic jobs run in their own goroutine and record a heartbeat on
+// every tick. The "workers" liveness check fails when a worker has not
+// beaten for three intervals: it is stuck, or it panicked and stopped,
+// and restarting the process is the way out.
+// ============================================================
+
+// SessionPruneInterval - how often expired sessions are dropped
+var SessionPruneInterval = time.Minute
+
+// worker is a running periodic job
+type worker struct {
+	interval time.Duration
+	lastBeat atomic.Int64 // unix nanoseconds
+}
+
+// Running workers by name
+var (
+	workers   = make(map[string]*worker)
+	workersMu sync.Mutex
+)
+
+// StartBackgroundWorkers starts the periodic jobs. They stop when ctx is
+// cancelled.
+func StartBackgroundWorkers(ctx context.Context) {
+	runWorker(ctx, "session-pruner", SessionPruneInterval, func(now time.Time) {
+		accountsMu.Lock()
+		defer accountsMu.Unlock()
+		pruneSessions(now)
+	})
+	runWorker(ctx, "webhook-dispatcher", WebhookDispatchInterval, dispatchWebhooks)
+	runWorker(ctx, "reservation-expirer", ReservationSweepInterval, expireAllReservations)
+	runWorker(ctx, "similar-indexer", SimilarIndexInterval, refreshAllSimilar)
+}
+
+// runWorker calls job every interval until ctx is done. A panicking job
+// stops its worker, which then shows up as stalled.
+func runWorker(ctx context.Context, name string, interval time.Duration, job func(now time.Time)) {
+	w := &worker{interval: interval}
+	w.lastBeat.Store(time.Now().UnixNano())
+
+	workersMu.Lock()
+	workers[name] = w
+	workersMu.Unlock()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("worker %s stopped: %v\n", name, r)
+			}
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				workersMu.Lock()
+				delete(workers, name)
+				workersMu.Unlock()
+				return
+			case now := <-ticker.C:
+				job(now)
+				w.lastBeat.Store(time.Now().UnixNano())
+			}
+		}
+	}()
+}
+
+// checkWorkers fails if any worker missed three heartbeats
+func checkWorkers(context.Context) error {
+	workersMu.Lock()
+	defer workersMu.Unlock()
+
+	var stalled []string
+	for name, w := range workers {
+		if time.Since(time.Unix(0, w.lastBeat.Load())) > 3*w.interval {
+			stalled = append(stalled, name)
+		}
+	}
+	if len(stalled) > 0 {
+		sort.Strings(stalled)
+		return fmt.Errorf("stalled: %s", strings.Join(stalled, ", "))
+	}
+	return nil
+}
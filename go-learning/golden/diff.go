@@ -0,0 +1,72 @@
+package golden
+
+import "strings"
+
+// diffContext is how many unchanged lines Diff shows around a change
+const diffContext = 3
+
+// Diff returns a line diff of want and got. Lines only in want start
+// with "-", lines only in got with "+"; unchanged lines far from any
+// change are replaced by "...".
+func Diff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+
+	// Keep the changes and the lines around them
+	keep := make([]bool, len(lines))
+	for k, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		for c := max(0, k-diffContext); c <= min(len(lines)-1, k+diffContext); c++ {
+			keep[c] = true
+		}
+	}
+
+	var out strings.Builder
+	skipped := false
+	for k, line := range lines {
+		if !keep[k] {
+			if !skipped {
+				out.WriteString("  ...\n")
+				skipped = true
+			}
+			continue
+		}
+		skipped = false
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,382 @@
+// Package golden records HTTP exchanges into golden files and replays
+// them as contract tests.
+//
+// A golden file is a JSON list of requests, each with the response it is
+// expected to get. Record one from a running server by wrapping its
+// handler in a Recorder, or write only the requests by hand and run the
+// tests with UPDATE_GOLDEN=1 to fill in the responses. Check replays the
+// requests in order against a handler and fails with a diff for every
+// response that changed. Values that differ on every run, such as
+// created_at or X-Request-ID, are replaced with placeholders first.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// UpdateEnv is the environment variable that makes Check rewrite golden
+// files from the current responses, e.g. UPDATE_GOLDEN=1 go test ./...
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// Headers a Recorder leaves out because clients and servers add them
+// on their own
+var (
+	ignoredRequestHeaders  = []string{"Accept-Encoding", "Connection", "Content-Length", "User-Agent"}
+	ignoredResponseHeaders = []string{"Content-Length"}
+)
+
+// Recording is the contents of a golden file
+type Recording struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange is one request and the response it got
+type Exchange struct {
+	Name     string   `json:"name,omitempty"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request; URL is the path and query
+type Request struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Host   string            `json:"host,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
+	Body
+}
+
+// Response is a recorded response
+type Response struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body
+}
+
+// Body is a message body. JSON stays JSON so golden files are readable,
+// other text is a string and anything else is base64.
+type Body struct {
+	JSON   json.RawMessage `json:"json,omitempty"`
+	Text   string          `json:"text,omitempty"`
+	Binary []byte          `json:"binary,omitempty"`
+}
+
+// newBody picks the representation of a body by its content type
+func newBody(contentType string, data []byte) Body {
+	switch {
+	case len(data) == 0:
+		return Body{}
+	case strings.Contains(contentType, "json") && json.Valid(data):
+		return Body{JSON: json.RawMessage(data)}
+	case utf8.Valid(data):
+		return Body{Text: string(data)}
+	default:
+		return Body{Binary: data}
+	}
+}
+
+// Bytes returns the body as sent
+func (b Body) Bytes() []byte {
+	switch {
+	case b.JSON != nil:
+		return b.JSON
+	case b.Text != "":
+		return []byte(b.Text)
+	default:
+		return b.Binary
+	}
+}
+
+func (e Exchange) String() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%q (%s %s)", e.Name, e.Request.Method, e.Request.URL)
+	}
+	return e.Request.Method + " " + e.Request.URL
+}
+
+// headerMap flattens a header, joining repeated values with ", "
+func headerMap(h http.Header, ignored []string) map[string]string {
+	m := make(map[string]string)
+	for name, values := range h {
+		if !containsFold(ignored, name) {
+			m[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================
+// GOLDEN FILES
+// ============================================================
+
+// Load reads a golden file. Unknown fields are errors so that typos in
+// hand-written requests do not go unnoticed.
+func Load(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var recording Recording
+	if err := dec.Decode(&recording); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &recording, nil
+}
+
+// Save writes the recording as indented JSON, creating the directory
+func (r *Recording) Save(path string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // keep placeholders like "<created_at>" readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// ============================================================
+// RECORDING
+// ============================================================
+
+// Recorder is a handler that passes requests on and keeps a copy of
+// every exchange, e.g. to turn a manual session against a running
+// server into a golden file
+type Recorder struct {
+	Normalizer Normalizer
+
+	handler   http.Handler
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// NewRecorder wraps handler, normalizing with DefaultNormalizer
+func NewRecorder(handler http.Handler) *Recorder {
+	return &Recorder{Normalizer: DefaultNormalizer, handler: handler}
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	request := Request{
+		Method: r.Method,
+		URL:    r.URL.RequestURI(),
+		Host:   r.Host,
+		Header: headerMap(r.Header, ignoredRequestHeaders),
+		Body:   newBody(r.Header.Get("Content-Type"), body),
+	}
+
+	cw := &captureWriter{ResponseWriter: w}
+	rec.handler.ServeHTTP(cw, r)
+
+	exchange := Exchange{Request: request, Response: newResponse(cw.statusCode(), w.Header(), cw.body.Bytes())}
+	rec.mu.Lock()
+	rec.exchanges = append(rec.exchanges, exchange)
+	rec.mu.Unlock()
+}
+
+// Recording returns the exchanges so far, normalized
+func (rec *Recorder) Recording() *Recording {
+	rec.mu.Lock()
+	exchanges := append([]Exchange(nil), rec.exchanges...)
+	rec.mu.Unlock()
+	return rec.Normalizer.Recording(&Recording{Exchanges: exchanges})
+}
+
+// Save writes the exchanges so far to a golden file
+func (rec *Recorder) Save(path string) error {
+	return rec.Recording().Save(path)
+}
+
+func newResponse(status int, header http.Header, body []byte) Response {
+	return Response{
+		Status: status,
+		Header: headerMap(header, ignoredResponseHeaders),
+		Body:   newBody(header.Get("Content-Type"), body),
+	}
+}
+
+// captureWriter copies the status and body written to a ResponseWriter
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ============================================================
+// REPLAYING
+// ============================================================
+
+// Replay sends the recorded requests in order to handler and returns
+// the exchanges with the responses it gave, normalized
+func Replay(handler http.Handler, recording *Recording, n Normalizer) *Recording {
+	actual := &Recording{Exchanges: make([]Exchange, 0, len(recording.Exchanges))}
+	for _, e := range recording.Exchanges {
+		req := httptest.NewRequest(e.Request.Method, e.Request.URL, bytes.NewReader(e.Request.Bytes()))
+		if e.Request.Host != "" {
+			req.Host = e.Request.Host
+		}
+		for name, value := range e.Request.Header {
+			req.Header.Set(name, value)
+		}
+		if e.Request.JSON != nil && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		e.Response = newResponse(w.Code, w.Header(), w.Body.Bytes())
+		actual.Exchanges = append(actual.Exchanges, e)
+	}
+	return n.Recording(actual)
+}
+
+// Mismatch is an exchange whose response differs from the golden file
+type Mismatch struct {
+	Index    int
+	Exchange Exchange
+	Diff     string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("exchange %d %s changed (- golden, + got):\n%s", m.Index, m.Exchange, m.Diff)
+}
+
+// Compare lists the responses in got that differ from want. Only the
+// headers present in want are compared, so a golden file can leave out
+// headers it does not care about.
+func Compare(want, got *Recording) []Mismatch {
+	var mismatches []Mismatch
+	for i, w := range want.Exchanges {
+		if i >= len(got.Exchanges) {
+			mismatches = append(mismatches, Mismatch{Index: i, Exchange: w, Diff: "- (no response)"})
+			continue
+		}
+		wantText := renderResponse(w.Response, w.Response.Header)
+		gotText := renderResponse(got.Exchanges[i].Response, w.Response.Header)
+		if wantText != gotText {
+			mismatches = append(mismatches, Mismatch{Index: i, Exchange: w, Diff: Diff(wantText, gotText)})
+		}
+	}
+	return mismatches
+}
+
+// renderResponse formats a response for diffing: the status, the
+// headers named in only and the body, with JSON indented
+func renderResponse(r Response, only map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s\n", r.Status, http.StatusText(r.Status))
+
+	names := make([]string, 0, len(only))
+	for name := range only {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := r.Header[name]
+		if !ok {
+			value = "(missing)"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", name, value)
+	}
+
+	b.WriteString("\n")
+	switch {
+	case r.JSON != nil:
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, r.JSON, "", "  "); err != nil {
+			b.Write(r.JSON)
+		} else {
+			b.Write(indented.Bytes())
+		}
+	case r.Binary != nil:
+		fmt.Fprintf(&b, "(%d bytes of binary data)", len(r.Binary))
+	default:
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+// Check replays the golden file at path against handler and reports
+// every response that changed. With UPDATE_GOLDEN set it rewrites the
+// file from the current responses instead, keeping the requests.
+func Check(t testing.TB, handler http.Handler, path string, n Normalizer) {
+	t.Helper()
+
+	want, err := Load(path)
+	if err != nil {
+		t.Fatalf("%v (write the requests and run with %s=1 to record the responses)", err, UpdateEnv)
+	}
+	got := Replay(handler, want, n)
+	if os.Getenv(UpdateEnv) != "" {
+		if err := got.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("Updated %s", path)
+		return
+	}
+
+	for _, m := range Compare(n.Recording(want), got) {
+		t.Errorf("%s: %s", path, m)
+	}
+}
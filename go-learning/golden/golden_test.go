@@ -0,0 +1,70 @@
+package golden
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// counter answers with a JSON count that grows by step on each POST
+func counter(step int) http.Handler {
+	n := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			n += step
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-ID", fmt.Sprint(time.Now().UnixNano()))
+		fmt.Fprintf(w, `{"count": %d, "updated_at": %q, "deleted_at": null}`, n, time.Now().Format(time.RFC3339Nano))
+	})
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	rec := NewRecorder(counter(1))
+	for _, method := range []string{"POST", "POST", "GET"} {
+		rec.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/count", strings.NewReader(`{"by": 1}`)))
+	}
+	path := filepath.Join(t.TempDir(), "count.json")
+	if err := rec.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(want.Exchanges[2].Response.JSON)
+	if !strings.Contains(body, `"updated_at": "<updated_at>"`) || !strings.Contains(body, `"deleted_at": null`) {
+		t.Errorf("Expected timestamps replaced and nulls kept, got %s", body)
+	}
+	if got := want.Exchanges[2].Response.Header["X-Request-Id"]; got != "<X-Request-Id>" {
+		t.Errorf("Expected X-Request-Id normalized, got %q", got)
+	}
+
+	// The same handler replays cleanly despite new timestamps and IDs
+	if m := Compare(want, Replay(counter(1), want, DefaultNormalizer)); len(m) != 0 {
+		t.Errorf("Expected no mismatches, got %v", m)
+	}
+
+	// A changed one is reported for every exchange it affects
+	mismatches := Compare(want, Replay(counter(2), want, DefaultNormalizer))
+	if len(mismatches) != 3 {
+		t.Fatalf("Expected 3 mismatches, got %d", len(mismatches))
+	}
+	if diff := mismatches[1].Diff; !strings.Contains(diff, `-   "count": 2,`) || !strings.Contains(diff, `+   "count": 4,`) {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want := "a\nb\nc\nd\ne\nf\ng\nh\ni"
+	got := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj"
+	expected := "  ...\n  b\n  c\n  d\n- e\n+ E\n  f\n  g\n  h\n  i\n+ j\n"
+	if diff := Diff(want, got); diff != expected {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+}
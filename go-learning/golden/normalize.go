@@ -0,0 +1,116 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Normalizer replaces values that differ between runs with placeholders
+// so that recorded and replayed responses can be compared
+type Normalizer struct {
+	// Fields are JSON object keys, matched with path.Match, whose
+	// non-null values become "<key>"
+	Fields []string
+	// Headers are response headers whose values become "<header>"
+	Headers []string
+	// Text is applied to bodies that are not JSON, e.g. XML or YAML
+	Text []Replacement
+}
+
+// Replacement replaces every match of Pattern with With
+type Replacement struct {
+	Pattern *regexp.Regexp
+	With    string
+}
+
+// DefaultNormalizer covers the timestamps, request IDs and session
+// tokens of the APIs in this repo
+var DefaultNormalizer = Normalizer{
+	Fields:  []string{"*_at", "reserved_until", "last_seen", "request_id", "token"},
+	Headers: []string{"Date", "X-Request-Id", "X-Response-Time"},
+	Text: []Replacement{
+		{Pattern: regexp.MustCompile(`\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)`), With: "<time>"},
+	},
+}
+
+// Recording returns a normalized copy of r
+func (n Normalizer) Recording(r *Recording) *Recording {
+	normalized := &Recording{Exchanges: make([]Exchange, len(r.Exchanges))}
+	for i, e := range r.Exchanges {
+		normalized.Exchanges[i] = n.Exchange(e)
+	}
+	return normalized
+}
+
+// Exchange normalizes the response of e; requests are kept as they are
+// because they are replayed
+func (n Normalizer) Exchange(e Exchange) Exchange {
+	if e.Response.Header != nil {
+		header := make(map[string]string, len(e.Response.Header))
+		for name, value := range e.Response.Header {
+			if containsFold(n.Headers, name) {
+				value = "<" + http.CanonicalHeaderKey(name) + ">"
+			}
+			header[name] = value
+		}
+		e.Response.Header = header
+	}
+	e.Response.Body = n.body(e.Response.Body)
+	return e
+}
+
+func (n Normalizer) body(b Body) Body {
+	if b.JSON != nil {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(b.JSON))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return b
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(n.value(v)); err != nil {
+			return b
+		}
+		b.JSON = json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+		return b
+	}
+	for _, r := range n.Text {
+		b.Text = r.Pattern.ReplaceAllString(b.Text, r.With)
+	}
+	return b
+}
+
+// value walks decoded JSON and replaces the values of matching fields
+func (n Normalizer) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if item != nil && n.volatile(key) {
+				v[key] = "<" + key + ">"
+			} else {
+				v[key] = n.value(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = n.value(item)
+		}
+	}
+	return v
+}
+
+func (n Normalizer) volatile(key string) bool {
+	for _, pattern := range n.Fields {
+		if ok, _ := path.Match(pattern, strings.ToLower(key)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package loadgen
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBuckets is the number of linear buckets per power of two. Values
+// are kept with a relative error below 1/subBuckets (under 1%), like an
+// HDR histogram with two significant digits, whatever their magnitude.
+const (
+	subBucketBits = 7
+	subBuckets    = 1 << subBucketBits
+)
+
+// Histogram records latencies in log-linear buckets: exact below
+// subBuckets nanoseconds, then subBuckets buckets for every doubling.
+// Memory stays small for any range, and percentiles are accurate to
+// the bucket width. It is not safe for concurrent use; give each worker
+// its own and Merge them.
+type Histogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// bucketIndex returns the bucket of a value in nanoseconds
+func bucketIndex(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits - 1
+	return (shift+1)*subBuckets + int(v>>shift) - subBuckets
+}
+
+// bucketUpper returns the largest value that falls into bucket i
+func bucketUpper(i int) int64 {
+	if i < subBuckets {
+		return int64(i)
+	}
+	shift := i/subBuckets - 1
+	sub := int64(i%subBuckets + subBuckets)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds one latency; negative values count as zero
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := bucketIndex(int64(d))
+	if i >= len(h.counts) {
+		counts := make([]int64, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds the values recorded in other
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		counts := make([]int64, len(other.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() int64 { return h.count }
+
+// Min returns the smallest recorded value
+func (h *Histogram) Min() time.Duration { return h.min }
+
+// Max returns the largest recorded value
+func (h *Histogram) Max() time.Duration { return h.max }
+
+// Mean returns the average of the recorded values
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns the value below which p percent (0-100) of the
+// recorded values fall, rounded up to its bucket and capped at Max
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return min(time.Duration(bucketUpper(i)), h.max)
+		}
+	}
+	return h.max
+}
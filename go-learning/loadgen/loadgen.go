@@ -0,0 +1,436 @@
+// Package loadgen drives HTTP load against the servers in this repo and
+// measures throughput and latency.
+//
+// Two workload models are supported. In the closed model a fixed number
+// of workers each send a request, wait for the answer and send the next,
+// so the offered load drops when the server slows down. In the open
+// model requests arrive at a fixed rate whatever the server does, like
+// independent users; latency is measured from when a request was due,
+// so time spent queueing behind a slow server counts (no coordinated
+// omission). Latencies go into log-linear histograms for percentiles.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Model is how requests are issued
+type Model string
+
+const (
+	Closed Model = "closed"
+	Open   Model = "open"
+)
+
+// Config describes a run
+type Config struct {
+	Mix         Mix
+	Model       Model
+	Rate        float64       // open: requests per second
+	Poisson     bool          // open: random (exponential) gaps instead of even ones
+	Concurrency int           // closed: workers; open: most requests in flight
+	Duration    time.Duration // measured part of the run
+	Warmup      time.Duration // run before measuring, e.g. to fill caches
+	ThinkTime   time.Duration // closed: pause between a response and the next request
+	Client      *http.Client
+	Seed        int64
+}
+
+// opStats are one worker's results for one operation
+type opStats struct {
+	latency  Histogram
+	requests int64
+	errors   int64
+	status   map[int]int64
+}
+
+// workerStats are one worker's results by operation
+type workerStats map[string]*opStats
+
+func (ws workerStats) get(name string) *opStats {
+	s, ok := ws[name]
+	if !ok {
+		s = &opStats{status: make(map[int]int64)}
+		ws[name] = s
+	}
+	return s
+}
+
+// run holds what the workers of one Run share
+type run struct {
+	ctx          context.Context
+	cfg          Config
+	measureFrom  time.Time
+	end          time.Time
+	mu           sync.Mutex
+	stats        []workerStats
+	lastResponse time.Time
+	dropped      int64
+}
+
+// Run generates load until cfg.Warmup plus cfg.Duration have passed or
+// ctx is done, then waits for requests in flight and reports
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	switch {
+	case len(cfg.Mix) == 0:
+		return nil, errors.New("empty mix")
+	case cfg.Duration <= 0:
+		return nil, errors.New("duration must be positive")
+	case cfg.Concurrency < 1:
+		return nil, errors.New("concurrency must be at least 1")
+	case cfg.Model == Open && cfg.Rate <= 0:
+		return nil, errors.New("the open model needs a positive rate")
+	case cfg.Model != Open && cfg.Model != Closed:
+		return nil, fmt.Errorf("unknown model %q (use %s or %s)", cfg.Model, Open, Closed)
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	start := time.Now()
+	r := &run{ctx: ctx, cfg: cfg, measureFrom: start.Add(cfg.Warmup)}
+	r.end = r.measureFrom.Add(cfg.Duration)
+	r.lastResponse = r.measureFrom
+
+	if cfg.Model == Open {
+		r.open(start)
+	} else {
+		r.closed()
+	}
+	return r.report(), nil
+}
+
+// closed runs cfg.Concurrency workers that each wait for a response
+// before sending the next request
+func (r *run) closed() {
+	var wg sync.WaitGroup
+	for i := 0; i < r.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(rnd *rand.Rand) {
+			defer wg.Done()
+			stats := make(workerStats)
+			defer r.collect(stats)
+
+			for r.ctx.Err() == nil {
+				now := time.Now()
+				if !now.Before(r.end) {
+					return
+				}
+				r.do(rnd, now, stats)
+				if r.cfg.ThinkTime > 0 {
+					select {
+					case <-time.After(r.cfg.ThinkTime):
+					case <-r.ctx.Done():
+					}
+				}
+			}
+		}(rand.New(rand.NewSource(r.cfg.Seed + int64(i))))
+	}
+	wg.Wait()
+}
+
+// open schedules requests at cfg.Rate and hands them to up to
+// cfg.Concurrency workers. A request that finds every worker busy and
+// the queue full is dropped and counted rather than delayed, which
+// would hide the overload.
+func (r *run) open(start time.Time) {
+	due := make(chan time.Time, r.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < r.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(rnd *rand.Rand) {
+			defer wg.Done()
+			stats := make(workerStats)
+			defer r.collect(stats)
+
+			for at := range due {
+				if r.ctx.Err() == nil {
+					r.do(rnd, at, stats)
+				}
+			}
+		}(rand.New(rand.NewSource(r.cfg.Seed + int64(i))))
+	}
+
+	rnd := rand.New(rand.NewSource(r.cfg.Seed - 1))
+	interval := float64(time.Second) / r.cfg.Rate
+	next := start
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+schedule:
+	for {
+		gap := interval
+		if r.cfg.Poisson {
+			gap = rnd.ExpFloat64() * interval
+		}
+		next = next.Add(time.Duration(gap))
+		if !next.Before(r.end) {
+			break
+		}
+
+		// Sleep until the request is due; when behind, send at once
+		if wait := time.Until(next); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-r.ctx.Done():
+				break schedule
+			}
+		}
+		select {
+		case due <- next:
+		default:
+			if !next.Before(r.measureFrom) {
+				r.mu.Lock()
+				r.dropped++
+				r.mu.Unlock()
+			}
+		}
+	}
+	close(due)
+	wg.Wait()
+}
+
+// do sends one request of a random operation and records its latency
+// from intended, the time it was due
+func (r *run) do(rnd *rand.Rand, intended time.Time, stats workerStats) {
+	op := r.cfg.Mix.pick(rnd)
+	status, err := r.send(op, rnd)
+	done := time.Now()
+	if err != nil && r.ctx.Err() != nil {
+		return // interrupted, not a failure of the server
+	}
+	if intended.Before(r.measureFrom) {
+		return // warmup
+	}
+
+	s := stats.get(op.Name)
+	s.requests++
+	s.status[status]++
+	if err != nil || status >= 400 {
+		s.errors++
+	}
+	s.latency.Record(done.Sub(intended))
+
+	r.mu.Lock()
+	if done.After(r.lastResponse) {
+		r.lastResponse = done
+	}
+	r.mu.Unlock()
+}
+
+// send performs a request and reads the whole body; status is 0 when
+// there was no response
+func (r *run) send(op Operation, rnd *rand.Rand) (int, error) {
+	req, err := op.Request(rnd)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.cfg.Client.Do(req.WithContext(r.ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// collect hands a finished worker's stats to the run
+func (r *run) collect(stats workerStats) {
+	r.mu.Lock()
+	r.stats = append(r.stats, stats)
+	r.mu.Unlock()
+}
+
+// ============================================================
+// REPORT
+// ============================================================
+
+// Millis is a duration shown in milliseconds in JSON
+type Millis time.Duration
+
+func (m Millis) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, float64(m)/float64(time.Millisecond), 'f', 3, 64), nil
+}
+
+func (m Millis) String() string {
+	d := time.Duration(m)
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// Latency summarizes a histogram
+type Latency struct {
+	Min  Millis `json:"min_ms"`
+	Mean Millis `json:"mean_ms"`
+	P50  Millis `json:"p50_ms"`
+	P90  Millis `json:"p90_ms"`
+	P95  Millis `json:"p95_ms"`
+	P99  Millis `json:"p99_ms"`
+	P999 Millis `json:"p99_9_ms"`
+	Max  Millis `json:"max_ms"`
+}
+
+func newLatency(h *Histogram) Latency {
+	return Latency{
+		Min:  Millis(h.Min()),
+		Mean: Millis(h.Mean()),
+		P50:  Millis(h.Percentile(50)),
+		P90:  Millis(h.Percentile(90)),
+		P95:  Millis(h.Percentile(95)),
+		P99:  Millis(h.Percentile(99)),
+		P999: Millis(h.Percentile(99.9)),
+		Max:  Millis(h.Max()),
+	}
+}
+
+// OperationReport is the result of one operation of the mix
+type OperationReport struct {
+	Name       string        `json:"name"`
+	Requests   int64         `json:"requests"`
+	Errors     int64         `json:"errors"`
+	Throughput float64       `json:"throughput"`
+	Latency    Latency       `json:"latency"`
+	Status     map[int]int64 `json:"status"` // 0 is no response
+}
+
+// Report is the result of a run. Throughput is completed requests per
+// second over the measured part of the run.
+type Report struct {
+	Model       Model   `json:"model"`
+	TargetRate  float64 `json:"target_rate,omitempty"`
+	Concurrency int     `json:"concurrency"`
+	Elapsed     float64 `json:"elapsed_seconds"`
+	OperationReport
+	Dropped    int64             `json:"dropped"`
+	Operations []OperationReport `json:"operations"`
+}
+
+func (r *run) report() *Report {
+	elapsed := r.lastResponse.Sub(r.measureFrom).Seconds()
+	report := &Report{
+		Model:           r.cfg.Model,
+		Concurrency:     r.cfg.Concurrency,
+		Elapsed:         elapsed,
+		OperationReport: OperationReport{Name: "all", Status: make(map[int]int64)},
+		Dropped:         r.dropped,
+	}
+	if r.cfg.Model == Open {
+		report.TargetRate = r.cfg.Rate
+	}
+
+	var all Histogram
+	byOp := make(map[string]*opStats)
+	for _, ws := range r.stats {
+		for name, s := range ws {
+			merged, ok := byOp[name]
+			if !ok {
+				merged = &opStats{status: make(map[int]int64)}
+				byOp[name] = merged
+			}
+			merged.latency.Merge(&s.latency)
+			merged.requests += s.requests
+			merged.errors += s.errors
+			for code, n := range s.status {
+				merged.status[code] += n
+			}
+		}
+	}
+
+	for _, op := range r.cfg.Mix {
+		s, ok := byOp[op.Name]
+		if !ok {
+			continue
+		}
+		report.Operations = append(report.Operations, OperationReport{
+			Name:       op.Name,
+			Requests:   s.requests,
+			Errors:     s.errors,
+			Throughput: perSecond(s.requests, elapsed),
+			Latency:    newLatency(&s.latency),
+			Status:     s.status,
+		})
+		all.Merge(&s.latency)
+		report.Requests += s.requests
+		report.Errors += s.errors
+		for code, n := range s.status {
+			report.Status[code] += n
+		}
+	}
+	report.Throughput = perSecond(report.Requests, elapsed)
+	report.Latency = newLatency(&all)
+	return report
+}
+
+func perSecond(n int64, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(n) / seconds
+}
+
+// WriteText writes the report as a table
+func (r *Report) WriteText(w io.Writer) error {
+	if r.Model == Open {
+		fmt.Fprintf(w, "Model:      open, %.0f req/s target, up to %d in flight\n", r.TargetRate, r.Concurrency)
+	} else {
+		fmt.Fprintf(w, "Model:      closed, %d workers\n", r.Concurrency)
+	}
+	fmt.Fprintf(w, "Elapsed:    %.2fs\n", r.Elapsed)
+	fmt.Fprintf(w, "Requests:   %d (%d errors", r.Requests, r.Errors)
+	if r.Dropped > 0 {
+		fmt.Fprintf(w, ", %d dropped: server could not keep up", r.Dropped)
+	}
+	fmt.Fprintln(w, ")")
+	fmt.Fprintf(w, "Throughput: %.1f req/s\n", r.Throughput)
+	fmt.Fprintf(w, "Status:     %s\n\n", formatStatus(r.Status))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\treq/s\terrors\tmin\tmean\tp50\tp90\tp95\tp99\tp99.9\tmax\t")
+	for _, op := range append(r.Operations, r.OperationReport) {
+		l := op.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			op.Name, op.Requests, op.Throughput, op.Errors,
+			l.Min, l.Mean, l.P50, l.P90, l.P95, l.P99, l.P999, l.Max)
+	}
+	return tw.Flush()
+}
+
+// formatStatus lists status counts like "200=950 404=50 error=3"
+func formatStatus(status map[int]int64) string {
+	codes := make([]int, 0, len(status))
+	for code := range status {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	s := ""
+	for i, code := range codes {
+		if i > 0 {
+			s += " "
+		}
+		if code == 0 {
+			s += fmt.Sprintf("error=%d", status[code])
+		} else {
+			s += fmt.Sprintf("%d=%d", code, status[code])
+		}
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHistogram_Percentiles(t *testing.T) {
+	var h Histogram
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 5 * time.Millisecond},
+		{99, 9900 * time.Microsecond},
+		{100, 10 * time.Millisecond},
+	} {
+		got := h.Percentile(tc.p)
+		if diff := got - tc.want; diff < 0 || diff > tc.want/subBuckets {
+			t.Errorf("p%v = %v, want %v within 1%%", tc.p, got, tc.want)
+		}
+	}
+	if h.Min() != time.Microsecond || h.Max() != 10*time.Millisecond || h.Count() != 10000 {
+		t.Errorf("Unexpected min %v, max %v, count %d", h.Min(), h.Max(), h.Count())
+	}
+
+	var merged Histogram
+	merged.Merge(&h)
+	merged.Merge(&h)
+	if merged.Count() != 20000 || merged.Percentile(50) != h.Percentile(50) {
+		t.Errorf("Unexpected merge: count %d, p50 %v", merged.Count(), merged.Percentile(50))
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	noop := func(*rand.Rand) (*http.Request, error) { return nil, nil }
+	ops := map[string]RequestFunc{"read": noop, "write": noop}
+
+	mix, err := ParseMix("read=3, write=1", ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		counts[mix.pick(rnd).Name]++
+	}
+	if counts["read"] < 2800 || counts["read"] > 3200 {
+		t.Errorf("Expected about 3000 reads, got %v", counts)
+	}
+
+	for _, spec := range []string{"read", "read=x", "delete=1", "read=0"} {
+		if _, err := ParseMix(spec, ops); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var served atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		time.Sleep(time.Millisecond)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	get := func(path string) RequestFunc {
+		return func(*rand.Rand) (*http.Request, error) { return http.NewRequest("GET", server.URL+path, nil) }
+	}
+	mix := Mix{{Name: "ok", Weight: 9, Request: get("/")}, {Name: "missing", Weight: 1, Request: get("/missing")}}
+
+	t.Run("closed", func(t *testing.T) {
+		report, err := Run(context.Background(), Config{Mix: mix, Model: Closed, Concurrency: 4, Duration: 200 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Requests < 100 || report.Errors != report.Status[http.StatusNotFound] {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+		if report.Latency.P50 < Millis(time.Millisecond) {
+			t.Errorf("Expected latency of at least the handler's 1ms, got %v", report.Latency.P50)
+		}
+	})
+
+	t.Run("open", func(t *testing.T) {
+		before := served.Load()
+		report, err := Run(context.Background(), Config{
+			Mix: mix, Model: Open, Rate: 200, Concurrency: 8,
+			Duration: 500 * time.Millisecond, Warmup: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// About 100 measured requests, plus about 20 in the warmup that
+		// are served but not counted
+		if report.Requests < 80 || report.Requests > 110 || served.Load()-before <= report.Requests {
+			t.Errorf("Expected about 100 measured requests, got %d of %d served", report.Requests, served.Load()-before)
+		}
+
+		var text, data bytes.Buffer
+		report.WriteText(&text)
+		if !strings.Contains(text.String(), "open, 200 req/s target") || !strings.Contains(text.String(), "missing") {
+			t.Errorf("Unexpected text report:\n%s", text.String())
+		}
+		json.NewEncoder(&data).Encode(report)
+		if !strings.Contains(data.String(), `"p99_ms":`) {
+			t.Errorf("Unexpected JSON report: %s", data.String())
+		}
+	})
+
+	if _, err := Run(context.Background(), Config{Mix: mix, Model: Open, Concurrency: 1, Duration: time.Second}); err == nil {
+		t.Error("Expected the open model to require a rate")
+	}
+}
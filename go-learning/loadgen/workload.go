@@ -0,0 +1,254 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RequestFunc builds the next request of an operation. rnd belongs to
+// the calling worker, so implementations need no locking for it.
+type RequestFunc func(rnd *rand.Rand) (*http.Request, error)
+
+// Operation is one kind of request in a mix with its relative weight
+type Operation struct {
+	Name    string
+	Weight  int
+	Request RequestFunc
+}
+
+// Mix is a weighted set of operations
+type Mix []Operation
+
+// ParseMix parses a spec like "read=80,create=15,search=5" against the
+// operations an API offers
+func ParseMix(spec string, ops map[string]RequestFunc) (Mix, error) {
+	var mix Mix
+	for _, part := range strings.Split(spec, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("mix entry %q: want name=weight", part)
+		}
+		request, found := ops[name]
+		if !found {
+			return nil, fmt.Errorf("unknown operation %q (available: %s)", name, strings.Join(operationNames(ops), ", "))
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("mix entry %q: weight must be a non-negative integer", part)
+		}
+		if w > 0 {
+			mix = append(mix, Operation{Name: name, Weight: w, Request: request})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("mix %q has no operation with a positive weight", spec)
+	}
+	return mix, nil
+}
+
+// pick chooses an operation with probability proportional to its weight
+func (m Mix) pick(rnd *rand.Rand) Operation {
+	total := 0
+	for _, op := range m {
+		total += op.Weight
+	}
+	n := rnd.Intn(total)
+	for _, op := range m {
+		if n < op.Weight {
+			return op
+		}
+		n -= op.Weight
+	}
+	return m[len(m)-1]
+}
+
+func operationNames(ops map[string]RequestFunc) []string {
+	names := make([]string, 0, len(ops))
+	for name := range ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ============================================================
+// APIS
+// ============================================================
+
+// Env is what an API needs to prepare a run
+type Env struct {
+	Client  *http.Client
+	BaseURL string
+	Token   string // sent as a Bearer token on writes
+	Prefill int    // records to create first if the server has fewer
+}
+
+// API is a server the generator knows how to drive
+type API interface {
+	// DefaultMix is used when no mix is given
+	DefaultMix() string
+	// Prepare checks that the server is up, seeds it as needed and
+	// returns its operations by name
+	Prepare(ctx context.Context, env Env) (map[string]RequestFunc, error)
+}
+
+// APIs are the servers in this repo, by name
+var APIs = map[string]API{
+	"ginapp":  ginappAPI{},
+	"nethttp": nethttpAPI{},
+}
+
+// newJSONRequest builds a request with an optional JSON body and token
+func newJSONRequest(method, url, token string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// send performs a setup request and decodes a JSON response into v
+func send(ctx context.Context, client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(body))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// ginappAPI drives the books API of ginapp. Reads and updates go to
+// the books present after setup so they do not 404 as creates add more.
+type ginappAPI struct{}
+
+func (ginappAPI) DefaultMix() string {
+	return "read=60,list=5,search=15,create=10,update=10"
+}
+
+func (ginappAPI) Prepare(ctx context.Context, env Env) (map[string]RequestFunc, error) {
+	base := strings.TrimSuffix(env.BaseURL, "/") + "/api/v1/books"
+	title := func(rnd *rand.Rand) string { return fmt.Sprintf("Load test %d", rnd.Int63()) }
+	year := func(rnd *rand.Rand) int { return 1990 + rnd.Intn(35) }
+
+	req, err := newJSONRequest("GET", base+"?fields=id", env.Token, nil)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Data []struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	if err := send(ctx, env.Client, req, &list); err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, max(len(list.Data), env.Prefill))
+	for _, b := range list.Data {
+		ids = append(ids, b.ID)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for len(ids) < env.Prefill {
+		body := map[string]interface{}{"title": title(rnd), "author": "Load Generator", "year": year(rnd)}
+		req, err := newJSONRequest("POST", base, env.Token, body)
+		if err != nil {
+			return nil, err
+		}
+		var created struct {
+			Data struct {
+				ID int `json:"id"`
+			} `json:"data"`
+		}
+		if err := send(ctx, env.Client, req, &created); err != nil {
+			return nil, fmt.Errorf("prefill: %w", err)
+		}
+		ids = append(ids, created.Data.ID)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no books to read; use a prefill above 0")
+	}
+	id := func(rnd *rand.Rand) int { return ids[rnd.Intn(len(ids))] }
+
+	return map[string]RequestFunc{
+		"read": func(rnd *rand.Rand) (*http.Request, error) {
+			return newJSONRequest("GET", fmt.Sprintf("%s/%d", base, id(rnd)), env.Token, nil)
+		},
+		"list": func(rnd *rand.Rand) (*http.Request, error) {
+			return newJSONRequest("GET", base+"?fields=id,title", env.Token, nil)
+		},
+		"search": func(rnd *rand.Rand) (*http.Request, error) {
+			return newJSONRequest("GET", fmt.Sprintf("%s/search?year=%d", base, year(rnd)), env.Token, nil)
+		},
+		"create": func(rnd *rand.Rand) (*http.Request, error) {
+			body := map[string]interface{}{"title": title(rnd), "author": "Load Generator", "year": year(rnd)}
+			return newJSONRequest("POST", base, env.Token, body)
+		},
+		"update": func(rnd *rand.Rand) (*http.Request, error) {
+			body := map[string]interface{}{"year": year(rnd)}
+			return newJSONRequest("PUT", fmt.Sprintf("%s/%d", base, id(rnd)), env.Token, body)
+		},
+	}, nil
+}
+
+// nethttpAPI drives the users API of the net/http server
+type nethttpAPI struct{}
+
+func (nethttpAPI) DefaultMix() string {
+	return "read=80,create=10,query=10"
+}
+
+func (nethttpAPI) Prepare(ctx context.Context, env Env) (map[string]RequestFunc, error) {
+	base := strings.TrimSuffix(env.BaseURL, "/")
+	req, err := newJSONRequest("GET", base+"/api/users", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := send(ctx, env.Client, req, nil); err != nil {
+		return nil, err
+	}
+
+	return map[string]RequestFunc{
+		"read": func(rnd *rand.Rand) (*http.Request, error) {
+			return newJSONRequest("GET", base+"/api/users", "", nil)
+		},
+		"create": func(rnd *rand.Rand) (*http.Request, error) {
+			n := rnd.Int63()
+			body := map[string]string{"name": fmt.Sprintf("user%d", n), "email": fmt.Sprintf("user%d@example.com", n)}
+			return newJSONRequest("POST", base+"/api/users", "", body)
+		},
+		"query": func(rnd *rand.Rand) (*http.Request, error) {
+			query := url.Values{"name": {fmt.Sprintf("user%d", rnd.Intn(1000))}, "age": {strconv.Itoa(rnd.Intn(100))}}
+			return newJSONRequest("GET", base+"/query?"+query.Encode(), "", nil)
+		},
+	}, nil
+}
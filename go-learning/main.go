@@ -11,7 +11,8 @@ import (
 )
 
 func main() {
-	fmt.Println("=== Welcome to Go Learning! ===\n")
+	fmt.Println("=== Welcome to Go Learning! ===")
+	fmt.Println()
 
 	// Run basics examples
 	fmt.Println("--- 1. Basics ---")
@@ -0,0 +1,22 @@
+package nethttp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go-learning/golden"
+)
+
+// TestContract replays testdata/golden against CreateServer's handler
+// with the demo users loaded; rerun with UPDATE_GOLDEN=1 after an
+// intended change
+func TestContract(t *testing.T) {
+	usersMu.Lock()
+	users, nextID = make(map[int]User), 1
+	usersMu.Unlock()
+	if _, err := LoadFixture("demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	golden.Check(t, CreateServer(":0").Handler, filepath.Join("testdata", "golden", "api.json"), golden.DefaultNormalizer)
+}
@@ -0,0 +1,65 @@
+package nethttp
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"go-learning/fixtures"
+)
+
+// ============================================================
+// 9. FIXTURES
+// ============================================================
+// Seed users come from YAML or JSON files instead of code:
+//
+//   users:
+//     - {name: Alice, email: alice@example.com}
+//
+// Each user is checked with the same rules as POST /api/users before any
+// is stored. LoadFixture takes a file path or the name of a set in
+// fixtures/; the server takes it as -seed.
+// ============================================================
+
+//go:embed fixtures/*.yaml
+var fixtureSets embed.FS
+
+// Fixture - the seed data of the users API
+type Fixture struct {
+	Users []CreateUserInput `json:"users"`
+}
+
+// FixtureSets lists the built-in fixture sets
+func FixtureSets() []string {
+	return fixtures.Sets(builtinFixtures())
+}
+
+// builtinFixtures is the fixtures/ directory of the embedded sets
+func builtinFixtures() fs.FS {
+	sets, _ := fs.Sub(fixtureSets, "fixtures")
+	return sets
+}
+
+// LoadFixture reads a fixture file or built-in set and stores its users,
+// returning how many were added. Nothing is stored if any user is invalid.
+func LoadFixture(source string) (int, error) {
+	var f Fixture
+	if err := fixtures.Load(builtinFixtures(), source, &f); err != nil {
+		return 0, err
+	}
+
+	var problems fixtures.Problems
+	for i, u := range f.Users {
+		if err := u.validate(); err != nil {
+			problems.Add(fmt.Sprintf("users[%d]", i), "%v", err)
+		}
+	}
+	if err := problems.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, u := range f.Users {
+		addUser(u)
+	}
+	return len(f.Users), nil
+}
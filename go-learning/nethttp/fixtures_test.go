@@ -0,0 +1,32 @@
+package nethttp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFixture(t *testing.T) {
+	usersMu.Lock()
+	users, nextID = make(map[int]User), 1
+	usersMu.Unlock()
+
+	n, err := LoadFixture("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || users[1].Name != "Alice" || users[2].Email != "bob@example.com" {
+		t.Errorf("Unexpected users after loading demo: %d %+v", n, users)
+	}
+
+	// Invalid users are rejected with the API's message and nothing is stored
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(path, []byte("users:\n  - {name: Carol, email: carol@example.com}\n  - {name: Dave}\n"), 0o644)
+	if _, err := LoadFixture(path); err == nil || !strings.Contains(err.Error(), "users[1]: Name and email are required") {
+		t.Errorf("Expected a validation error for users[1], got %v", err)
+	}
+	if len(users) != 2 {
+		t.Errorf("Expected nothing stored from an invalid fixture, got %d users", len(users))
+	}
+}
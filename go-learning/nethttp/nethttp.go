@@ -1,10 +1,13 @@
 package nethttp
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
@@ -20,6 +23,8 @@ import (
 // 5. JSON APIs
 // 6. HTTP Client
 // 7. Request/Response handling
+// 8. TLS, certificate reloading and client certificates (tls.go)
+// 9. Seed data from fixture files (fixtures.go)
 // ============================================================
 
 // User represents a simple user model for JSON examples
@@ -68,6 +73,14 @@ func RequestInfoHandler(w http.ResponseWriter, r *http.Request) {
   Headers:    %v
 `, r.Method, r.URL.String(), r.URL.Path, r.Host, r.RemoteAddr, r.UserAgent(), r.Header)
 
+	// Over TLS, also show the client certificate (mTLS)
+	if r.TLS != nil {
+		info += fmt.Sprintf("  TLS:        %s\n", tls.VersionName(r.TLS.Version))
+		if cert := VerifiedClientCert(r); cert != nil {
+			info += fmt.Sprintf("  Client:     %s\n", cert.Subject)
+		}
+	}
+
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprint(w, info)
 }
@@ -126,10 +139,41 @@ func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
 		userList = append(userList, u)
 	}
 	usersMu.RUnlock()
+	sort.Slice(userList, func(i, j int) bool { return userList[i].ID < userList[j].ID })
 
 	JSONResponse(w, http.StatusOK, userList)
 }
 
+// CreateUserInput - body of POST /api/users
+type CreateUserInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// validate checks the rules POST /api/users enforces
+func (input CreateUserInput) validate() error {
+	if input.Name == "" || input.Email == "" {
+		return errors.New("Name and email are required")
+	}
+	return nil
+}
+
+// addUser stores a validated user under the next ID
+func addUser(input CreateUserInput) User {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+
+	user := User{
+		ID:        nextID,
+		Name:      input.Name,
+		Email:     input.Email,
+		CreatedAt: time.Now(),
+	}
+	users[nextID] = user
+	nextID++
+	return user
+}
+
 // CreateUserHandler - POST /api/users - creates a new user
 func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -137,32 +181,18 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var input struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}
-
+	var input CreateUserInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		ErrorResponse(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
 		return
 	}
 
-	if input.Name == "" || input.Email == "" {
-		ErrorResponse(w, http.StatusBadRequest, "Name and email are required")
+	if err := input.validate(); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	usersMu.Lock()
-	user := User{
-		ID:        nextID,
-		Name:      input.Name,
-		Email:     input.Email,
-		CreatedAt: time.Now(),
-	}
-	users[nextID] = user
-	nextID++
-	usersMu.Unlock()
-
+	user := addUser(input)
 	JSONResponse(w, http.StatusCreated, user)
 }
 
@@ -316,14 +346,14 @@ func RunNetHTTP() {
 
 	fmt.Println("\n4. To start the server, run:")
 	fmt.Println("   go run cmd/nethttp/main.go")
+	fmt.Println("   go run cmd/nethttp/main.go -dev-tls .certs   (HTTPS with a development CA)")
 
-	// Demo: Add sample users
-	usersMu.Lock()
-	users[nextID] = User{ID: nextID, Name: "Alice", Email: "alice@example.com", CreatedAt: time.Now()}
-	nextID++
-	users[nextID] = User{ID: nextID, Name: "Bob", Email: "bob@example.com", CreatedAt: time.Now()}
-	nextID++
-	usersMu.Unlock()
+	// Demo: Load the sample users from the demo fixture set
+	loaded, err := LoadFixture("demo")
+	if err != nil {
+		fmt.Printf("\n5. Could not load sample users: %v\n", err)
+		return
+	}
 
-	fmt.Printf("\n5. Sample users loaded: %d users\n", len(users))
+	fmt.Printf("\n5. Sample users loaded: %d users\n", loaded)
 }
@@ -0,0 +1,297 @@
+package nethttp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ============================================================
+// 8. TLS
+// ============================================================
+// Shared by cmd/nethttp and cmd/ginapp:
+//
+//   - CertReloader serves a certificate from files and picks up
+//     replacements (e.g. a renewal by cert-manager) without a restart
+//   - EnsureDevCertificates bootstraps a local development CA with a
+//     server certificate for localhost and a client certificate
+//   - NewTLSConfig puts both together and optionally verifies client
+//     certificates (mTLS) against a CA file
+//
+// Client certificates are verified when offered but not required at
+// the TLS layer, so one listener can serve public routes as well as
+// routes that check VerifiedClientCert.
+// ============================================================
+
+// CertReloadInterval - how often certificate files are checked for changes
+var CertReloadInterval = 10 * time.Second
+
+// CertReloader serves the certificate in certFile and keyFile
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // newest modification time of the two files
+}
+
+// NewCertReloader loads the key pair. Call Watch to pick up changes.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the key pair again. On error the previous certificate
+// stays in use.
+func (r *CertReloader) Reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// GetCertificate is a tls.Config.GetCertificate callback
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch reloads the certificate whenever one of the files changes,
+// checking every interval until ctx is done
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime, err := r.filesModTime()
+			r.mu.RLock()
+			changed := err == nil && !modTime.Equal(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			// Both files are usually replaced together; a half-written
+			// pair fails to load and is retried on the next tick
+			if err := r.Reload(); err != nil {
+				fmt.Printf("Certificate reload failed: %v\n", err)
+				continue
+			}
+			fmt.Printf("Reloaded certificate %s\n", r.certFile)
+		}
+	}
+}
+
+func (r *CertReloader) filesModTime() (time.Time, error) {
+	var newest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// NewTLSConfig serves the key pair in certFile and keyFile, reloading it
+// on change once Watch is running. With a clientCAFile, client
+// certificates signed by that CA are verified when offered.
+func NewTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, *CertReloader, error) {
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		data, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, nil, fmt.Errorf("no certificates in %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, reloader, nil
+}
+
+// VerifiedClientCert returns the client certificate of an mTLS request,
+// or nil if none was presented (or the request is not over TLS)
+func VerifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// ============================================================
+// DEVELOPMENT CA
+// ============================================================
+
+// DevCertificates - files written by EnsureDevCertificates
+type DevCertificates struct {
+	CAFile         string // trust this in curl (--cacert) or the browser
+	CertFile       string // server certificate for localhost
+	KeyFile        string
+	ClientCertFile string // client certificate for mTLS routes
+	ClientKeyFile  string
+}
+
+// Lifetimes of development certificates. Leaf certificates are reissued
+// when they are within devRenewBefore of expiring.
+const (
+	devCALifetime   = 10 * 365 * 24 * time.Hour
+	devLeafLifetime = 365 * 24 * time.Hour
+	devRenewBefore  = 30 * 24 * time.Hour
+)
+
+// EnsureDevCertificates creates a development CA in dir, unless one
+// exists, and issues the server and client certificates it is missing.
+// Never use these outside local development: the CA key sits next to
+// the certificates.
+func EnsureDevCertificates(dir string) (DevCertificates, error) {
+	files := DevCertificates{
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		CertFile:       filepath.Join(dir, "server.pem"),
+		KeyFile:        filepath.Join(dir, "server-key.pem"),
+		ClientCertFile: filepath.Join(dir, "client.pem"),
+		ClientKeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+	caKeyFile := filepath.Join(dir, "ca-key.pem")
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return files, err
+	}
+
+	ca, caKey, err := loadKeyPair(files.CAFile, caKeyFile)
+	if err != nil {
+		ca, caKey, err = issueCertificate(&x509.Certificate{
+			Subject:               pkix.Name{CommonName: "go-learning development CA"},
+			NotAfter:              time.Now().Add(devCALifetime),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}, nil, nil, files.CAFile, caKeyFile)
+		if err != nil {
+			return files, fmt.Errorf("creating development CA: %w", err)
+		}
+	}
+
+	leaves := []struct {
+		certFile, keyFile string
+		template          *x509.Certificate
+	}{
+		{files.CertFile, files.KeyFile, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "localhost"},
+			DNSNames:    []string{"localhost"},
+			IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}},
+		{files.ClientCertFile, files.ClientKeyFile, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "dev-admin", Organization: []string{"go-learning"}},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}},
+	}
+	for _, leaf := range leaves {
+		if cert, _, err := loadKeyPair(leaf.certFile, leaf.keyFile); err == nil &&
+			cert.CheckSignatureFrom(ca) == nil && time.Until(cert.NotAfter) > devRenewBefore {
+			continue
+		}
+		leaf.template.NotAfter = time.Now().Add(devLeafLifetime)
+		leaf.template.KeyUsage = x509.KeyUsageDigitalSignature
+		if _, _, err := issueCertificate(leaf.template, ca, caKey, leaf.certFile, leaf.keyFile); err != nil {
+			return files, fmt.Errorf("issuing %s: %w", leaf.certFile, err)
+		}
+	}
+	return files, nil
+}
+
+// issueCertificate creates a key and a certificate signed by parent, or
+// self-signed if parent is nil, and writes both as PEM
+func issueCertificate(template, parent *x509.Certificate, parentKey crypto.Signer, certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour) // tolerate clock skew
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+// loadKeyPair reads a certificate and its private key
+func loadKeyPair(certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("private key cannot sign")
+	}
+	return pair.Leaf, signer, nil
+}
@@ -0,0 +1,86 @@
+package nethttp
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureDevCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certs, err := EnsureDevCertificates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, _, err := loadKeyPair(certs.CertFile, certs.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _, err := loadKeyPair(certs.CAFile, filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("Server certificate not signed by the CA: %v", err)
+	}
+	if err := server.VerifyHostname("localhost"); err != nil {
+		t.Errorf("Server certificate not valid for localhost: %v", err)
+	}
+
+	// A second run keeps valid certificates
+	before, _ := os.ReadFile(certs.CertFile)
+	if _, err := EnsureDevCertificates(dir); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(certs.CertFile); !bytes.Equal(before, after) {
+		t.Error("Valid server certificate was reissued")
+	}
+
+	// A missing client certificate is issued again by the same CA
+	os.Remove(certs.ClientCertFile)
+	if _, err := EnsureDevCertificates(dir); err != nil {
+		t.Fatal(err)
+	}
+	client, _, err := loadKeyPair(certs.ClientCertFile, certs.ClientKeyFile)
+	if err != nil || client.CheckSignatureFrom(ca) != nil {
+		t.Errorf("Client certificate not reissued by the CA: %v", err)
+	}
+}
+
+func TestCertReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certs, err := EnsureDevCertificates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := NewCertReloader(certs.CertFile, certs.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := reloader.GetCertificate(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 5*time.Millisecond)
+
+	// Replace the key pair, as a renewal would
+	os.Remove(certs.CertFile)
+	if _, err := EnsureDevCertificates(dir); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certs.CertFile, future, future)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if current, _ := reloader.GetCertificate(nil); current.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) != 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Certificate was not reloaded")
+}